@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/best-microservice/common/events"
+	productpb "github.com/best-microservice/common/protos/product"
+	"github.com/gin-gonic/gin"
+
+	"github.com/best-microservice/api-gateway/middleware"
+)
+
+// cachedMethods are the transcoded methods whose responses are cached.
+var cachedMethods = []string{
+	productpb.ProductService_GetProduct_FullMethodName,
+	productpb.ProductService_ListProducts_FullMethodName,
+}
+
+// productListTag marks cached product lists, which show any product and are
+// dropped whenever one changes or is added.
+const productListTag = "products"
+
+func productTag(id string) string { return "product:" + id }
+
+// catalogTags names the products a cached catalog response shows.
+func catalogTags(c *gin.Context) []string {
+	if id := c.Param("id"); id != "" {
+		return []string{productTag(id)}
+	}
+	return []string{productListTag}
+}
+
+// catalogLastModified is the latest updated_at of the products in a catalog
+// response, a single product or a page of them. Adding a product changes a
+// page too, and the new product is the latest to change.
+func catalogLastModified(body []byte) time.Time {
+	var res struct {
+		UpdatedAt string `json:"updated_at"`
+		Products  []struct {
+			UpdatedAt string `json:"updated_at"`
+		} `json:"products"`
+	}
+	if json.Unmarshal(body, &res) != nil {
+		return time.Time{}
+	}
+	stamps := []string{res.UpdatedAt}
+	for _, p := range res.Products {
+		stamps = append(stamps, p.UpdatedAt)
+	}
+	var latest time.Time
+	for _, s := range stamps {
+		if t, err := time.Parse(time.RFC3339, s); err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// invalidateCatalog drops the cached responses showing the product of a
+// product event, e.g. after its stock changed.
+func invalidateCatalog(cache *middleware.ResponseCache) events.Handler {
+	return func(ctx context.Context, event events.Event) error {
+		if event.AggregateType != "product" {
+			return nil
+		}
+		n := cache.Invalidate(productTag(event.AggregateID), productListTag)
+		slog.DebugContext(ctx, "invalidated cached responses", "event_type", event.Type,
+			"product_id", event.AggregateID, "responses", n)
+		return nil
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/best-microservice/api-gateway/gql"
+	"github.com/best-microservice/api-gateway/middleware"
+	"github.com/best-microservice/api-gateway/resilience"
+	"github.com/best-microservice/common/config"
+	"github.com/best-microservice/common/mtls"
+	"github.com/best-microservice/common/tracing"
+)
+
+// Config is the gateway configuration. See the config package for the
+// sources and their precedence.
+type Config struct {
+	ListenAddr         string        `yaml:"listen_addr" env:"GATEWAY_LISTEN_ADDR" flag:"listen-addr" usage:"HTTP listen address"`
+	EventsAddr         string        `yaml:"events_addr" env:"GATEWAY_EVENTS_ADDR" flag:"events-addr" usage:"gRPC listen address services deliver events to, to invalidate cached responses; without mTLS only local callers are accepted"`
+	UserServiceAddr    string        `yaml:"user_service_addr" env:"USER_SERVICE_ADDR" flag:"user-service-addr" usage:"user service gRPC address" validate:"required"`
+	ProductServiceAddr string        `yaml:"product_service_addr" env:"PRODUCT_SERVICE_ADDR" flag:"product-service-addr" usage:"product service gRPC address" validate:"required"`
+	OrderServiceAddr   string        `yaml:"order_service_addr" env:"ORDER_SERVICE_ADDR" flag:"order-service-addr" usage:"order service gRPC address" validate:"required"`
+	IdempotencyTTL     time.Duration `yaml:"idempotency_ttl" env:"IDEMPOTENCY_TTL" flag:"idempotency-ttl" usage:"how long Idempotency-Key responses are kept"`
+	ReadinessTimeout   time.Duration `yaml:"readiness_timeout" env:"GATEWAY_READINESS_TIMEOUT" flag:"readiness-timeout" usage:"timeout of the backend health checks behind /readyz"`
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout" env:"GATEWAY_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"time allowed for in-flight requests on shutdown"`
+	RequestTimeout     time.Duration `yaml:"request_timeout" env:"GATEWAY_REQUEST_TIMEOUT" flag:"request-timeout" usage:"deadline of API requests, including all backend calls"`
+	WatchHeartbeat     time.Duration `yaml:"watch_heartbeat" env:"GATEWAY_WATCH_HEARTBEAT" flag:"watch-heartbeat" usage:"interval of keep-alives on order event streams and WebSockets"`
+	// TrustedProxies are the addresses or CIDRs of the load balancers in front
+	// of the gateway. Client IPs are taken from X-Forwarded-For only when the
+	// request comes from one of them, so clients cannot pick their own.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"GATEWAY_TRUSTED_PROXIES" flag:"trusted-proxies" usage:"comma separated proxy IPs or CIDRs allowed to set X-Forwarded-For (empty = none)"`
+	// RouteTimeouts overrides RequestTimeout per route, keyed by method and
+	// route template, e.g. "GET /api/v1/orders/:id/invoice: 15s". A longer
+	// route timeout also lifts the backend timeout of each call attempt.
+	RouteTimeouts map[string]time.Duration `yaml:"route_timeouts"`
+	Backends      Backends                 `yaml:"backends"`
+	GraphQL       gql.Limits               `yaml:"graphql" env:"GRAPHQL_" flag:"graphql-"`
+	Cache         CacheConfig              `yaml:"cache" env:"CACHE_" flag:"cache-"`
+	RateLimit     RateLimitConfig          `yaml:"rate_limit" env:"RATE_LIMIT_" flag:"rate-limit-"`
+	Log           config.Logging           `yaml:"log"`
+	TLS           mtls.Config              `yaml:"tls" env:"GATEWAY_TLS_" flag:"tls-"`
+	Tracing       tracing.Config           `yaml:"tracing"`
+}
+
+// RateLimitConfig sets the per client request budgets of the API. The auth
+// budget applies on top of the default one to requests that check a
+// password or token, or send email.
+type RateLimitConfig struct {
+	Enabled       bool             `yaml:"enabled" env:"ENABLED" flag:"enabled" usage:"rate limit API requests per client"`
+	KeyBy         string           `yaml:"key_by" env:"KEY_BY" flag:"key-by" usage:"how clients are told apart: ip, api_key or user"`
+	APIKeys       []string         `yaml:"api_keys" env:"API_KEYS" flag:"api-keys" usage:"comma separated API keys counted on their own with key_by api_key; other requests count per IP" secret:"true"`
+	Store         string           `yaml:"store" env:"STORE" flag:"store" usage:"where buckets are kept: memory, or redis to share them between gateways"`
+	RedisAddr     string           `yaml:"redis_addr" env:"REDIS_ADDR" flag:"redis-addr" usage:"Redis address for the redis store"`
+	RedisPassword string           `yaml:"redis_password" env:"REDIS_PASSWORD" flag:"redis-password" usage:"Redis password" secret:"true"`
+	RedisDB       int              `yaml:"redis_db" env:"REDIS_DB" flag:"redis-db" usage:"Redis database number"`
+	Default       middleware.Limit `yaml:"default" env:"DEFAULT_" flag:"default-"`
+	Auth          middleware.Limit `yaml:"auth" env:"AUTH_" flag:"auth-"`
+}
+
+func (c RateLimitConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	var errs []error
+	switch c.KeyBy {
+	case "ip":
+	case "api_key":
+		if len(c.APIKeys) == 0 {
+			errs = append(errs, fmt.Errorf("rate_limit.api_keys is required to key by api_key"))
+		}
+	case "user":
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.key_by %q must be ip, api_key or user", c.KeyBy))
+	}
+	switch c.Store {
+	case "memory":
+	case "redis":
+		if c.RedisAddr == "" {
+			errs = append(errs, fmt.Errorf("rate_limit.redis_addr is required for the redis store"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.store %q must be memory or redis", c.Store))
+	}
+	if c.Default.Requests <= 0 || c.Default.Per <= 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.default needs positive requests and per"))
+	}
+	if c.Auth.Requests <= 0 || c.Auth.Per <= 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.auth needs positive requests and per"))
+	}
+	return errors.Join(errs...)
+}
+
+// CacheConfig sizes the in-memory cache of product reads. Entries are
+// dropped when product events arrive, and after ttl at the latest.
+type CacheConfig struct {
+	Enabled    bool          `yaml:"enabled" env:"ENABLED" flag:"enabled" usage:"cache product reads in memory"`
+	MaxEntries int           `yaml:"max_entries" env:"MAX_ENTRIES" flag:"max-entries" usage:"responses kept, least recently used dropped first"`
+	TTL        time.Duration `yaml:"ttl" env:"TTL" flag:"ttl" usage:"longest a response is served from cache, bounding staleness when events are missed"`
+}
+
+func (c CacheConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	var errs []error
+	if c.MaxEntries <= 0 {
+		errs = append(errs, fmt.Errorf("cache.max_entries must be positive"))
+	}
+	if c.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("cache.ttl must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// Backends holds the call policy of each backend service.
+type Backends struct {
+	User    resilience.Policy `yaml:"user" env:"USER_SERVICE_" flag:"user-service-"`
+	Product resilience.Policy `yaml:"product" env:"PRODUCT_SERVICE_" flag:"product-service-"`
+	Order   resilience.Policy `yaml:"order" env:"ORDER_SERVICE_" flag:"order-service-"`
+}
+
+func (b Backends) Validate() error {
+	check := func(name string, p resilience.Policy) error {
+		if err := p.Check(); err != nil {
+			return fmt.Errorf("backends.%s: %w", name, err)
+		}
+		return nil
+	}
+	return errors.Join(check("user", b.User), check("product", b.Product), check("order", b.Order))
+}
+
+func loadConfig(args []string) (Config, error) {
+	cfg := Config{
+		ListenAddr:         ":8080",
+		EventsAddr:         ":50054",
+		UserServiceAddr:    "localhost:50051",
+		ProductServiceAddr: "localhost:50052",
+		OrderServiceAddr:   "localhost:50053",
+		IdempotencyTTL:     24 * time.Hour,
+		ReadinessTimeout:   2 * time.Second,
+		ShutdownTimeout:    5 * time.Second,
+		RequestTimeout:     10 * time.Second,
+		WatchHeartbeat:     15 * time.Second,
+		RouteTimeouts: map[string]time.Duration{
+			"GET /api/v1/orders/:id/invoice": 30 * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			KeyBy:   "ip",
+			Store:   "memory",
+			Default: middleware.Limit{Requests: 100, Per: time.Minute},
+			Auth:    middleware.Limit{Requests: 5, Per: time.Minute},
+		},
+		Backends: Backends{
+			User:    resilience.DefaultPolicy(),
+			Product: resilience.DefaultPolicy(),
+			Order:   resilience.DefaultPolicy(),
+		},
+		GraphQL: gql.DefaultLimits(),
+		Cache:   CacheConfig{Enabled: true, MaxEntries: 10000, TTL: 30 * time.Second},
+		Log:     config.DefaultLogging(),
+		Tracing: tracing.DefaultConfig("api-gateway"),
+	}
+	rest, err := config.Load("api-gateway", &cfg, args)
+	if err == nil && len(rest) > 0 {
+		err = fmt.Errorf("unexpected arguments %q", rest)
+	}
+	return cfg, err
+}
+
+func (c Config) Validate() error {
+	errs := []error{
+		config.ValidateAddr("listen_addr", c.ListenAddr),
+		config.ValidateAddr("events_addr", c.EventsAddr),
+		config.ValidateAddr("user_service_addr", c.UserServiceAddr),
+		config.ValidateAddr("product_service_addr", c.ProductServiceAddr),
+		config.ValidateAddr("order_service_addr", c.OrderServiceAddr),
+	}
+	if c.IdempotencyTTL <= 0 {
+		errs = append(errs, fmt.Errorf("idempotency_ttl must be positive"))
+	}
+	if c.ReadinessTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("readiness_timeout must be positive"))
+	}
+	if c.RequestTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("request_timeout must be positive"))
+	}
+	if c.WatchHeartbeat <= 0 {
+		errs = append(errs, fmt.Errorf("watch_heartbeat must be positive"))
+	}
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("trusted_proxies: %q is not an IP address or CIDR", proxy))
+			}
+		}
+	}
+	if err := c.GraphQL.Check(); err != nil {
+		errs = append(errs, fmt.Errorf("graphql.%w", err))
+	}
+	for route, d := range c.RouteTimeouts {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("route_timeouts[%q] must be positive", route))
+		}
+	}
+	return errors.Join(errs...)
+}
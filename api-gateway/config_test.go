@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*Config)
+		wantErr string
+	}{
+		{"defaults", func(*Config) {}, ""},
+		{"key by api key", func(c *Config) {
+			c.RateLimit.KeyBy = "api_key"
+			c.RateLimit.APIKeys = []string{"k1"}
+		}, ""},
+		{"key by api key without keys", func(c *Config) { c.RateLimit.KeyBy = "api_key" }, "rate_limit.api_keys is required"},
+		{"key by user", func(c *Config) { c.RateLimit.KeyBy = "user" }, ""},
+		{"unknown key", func(c *Config) { c.RateLimit.KeyBy = "session" }, `rate_limit.key_by "session" must be ip, api_key or user`},
+		{"unknown key with rate limiting off", func(c *Config) {
+			c.RateLimit.Enabled = false
+			c.RateLimit.KeyBy = "session"
+		}, ""},
+		{"trusted proxies", func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.1", "::1"} }, ""},
+		{"invalid trusted proxy", func(c *Config) { c.TrustedProxies = []string{"lb.internal"} }, `trusted_proxies: "lb.internal"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadConfig(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(&cfg)
+
+			err = cfg.Validate()
+			if err == nil {
+				err = cfg.RateLimit.Validate()
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Package gql serves the API as GraphQL, so clients can fetch an order with
+// its user and products in one request. Fields are resolved with the
+// gateway's gRPC clients; lookups repeated within a query are batched per
+// request, and queries are rejected up front when they are nested too deep
+// or would select too many fields.
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/best-microservice/common/apierror"
+	orderpb "github.com/best-microservice/common/protos/order"
+	productpb "github.com/best-microservice/common/protos/product"
+	userpb "github.com/best-microservice/common/protos/user"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"google.golang.org/grpc"
+
+	"github.com/best-microservice/api-gateway/middleware"
+	"github.com/best-microservice/api-gateway/problem"
+)
+
+// Server executes GraphQL queries against the backend services.
+type Server struct {
+	users    userpb.UserServiceClient
+	products productpb.ProductServiceClient
+	orders   orderpb.OrderServiceClient
+	limits   Limits
+	gql      graphql.Schema
+}
+
+// New returns a server resolving fields through the given connections.
+func New(userConn, productConn, orderConn grpc.ClientConnInterface, limits Limits) (*Server, error) {
+	s := &Server{
+		users:    userpb.NewUserServiceClient(userConn),
+		products: productpb.NewProductServiceClient(productConn),
+		orders:   orderpb.NewOrderServiceClient(orderConn),
+		limits:   limits,
+	}
+	var err error
+	s.gql, err = s.schema()
+	return s, err
+}
+
+// Request is a GraphQL request, sent as a JSON body or, for GET, as query
+// parameters with variables JSON encoded.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// request is the per request state available to resolvers.
+type request struct {
+	loaders *loaders
+	// userID is the authenticated user, empty for anonymous requests.
+	userID string
+}
+
+type requestKey struct{}
+
+func requestFrom(ctx context.Context) *request {
+	return ctx.Value(requestKey{}).(*request)
+}
+
+// Serve handles a GraphQL request. Errors in the query, including exceeded
+// limits, and failed fields are reported in the errors of a 200 response as
+// GraphQL clients expect; only requests that are not GraphQL at all get a
+// problem response.
+func (s *Server) Serve(c *gin.Context) {
+	var req Request
+	switch c.Request.Method {
+	case http.MethodGet:
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if v := c.Query("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				problem.Abort(c, http.StatusBadRequest, apierror.ReasonMalformedRequest, "variables must be a JSON object")
+				return
+			}
+		}
+	default:
+		if err := c.ShouldBindJSON(&req); err != nil {
+			problem.Abort(c, http.StatusBadRequest, apierror.ReasonMalformedRequest, "the request body must be a JSON GraphQL request")
+			return
+		}
+	}
+	if req.Query == "" {
+		problem.Abort(c, http.StatusBadRequest, apierror.ReasonMalformedRequest, "query is required")
+		return
+	}
+
+	c.JSON(http.StatusOK, s.execute(s.context(c), req))
+}
+
+// context returns the context resolvers run in, with the user of the
+// session as the viewer. The services do not check who is asking; the
+// resolvers only show the viewer's own addresses and orders.
+func (s *Server) context(c *gin.Context) context.Context {
+	return context.WithValue(c.Request.Context(), requestKey{}, &request{
+		loaders: newLoaders(s.users, s.products, s.orders),
+		userID:  c.GetString(middleware.UserIDKey),
+	})
+}
+
+func (s *Server) execute(ctx context.Context, req Request) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if res := graphql.ValidateDocument(&s.gql, doc, nil); !res.IsValid {
+		return &graphql.Result{Errors: res.Errors}
+	}
+	if op := operation(doc, req.OperationName); op != nil {
+		if err := s.limits.check(newMeasure(s.gql, doc, req.Variables), op); err != nil {
+			e := &Error{Message: err.Error(), Code: apierror.ReasonQueryTooComplex}
+			return &graphql.Result{Errors: []gqlerrors.FormattedError{{
+				Message:    e.Message,
+				Locations:  []location.SourceLocation{},
+				Extensions: e.Extensions(),
+			}}}
+		}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.gql,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+}
@@ -0,0 +1,180 @@
+package gql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Limits bounds the work a single query may cause. Introspection fields are
+// not counted; they are answered by the gateway without backend calls.
+type Limits struct {
+	MaxDepth      int `yaml:"max_depth" env:"MAX_DEPTH" flag:"max-depth" usage:"deepest field nesting a query may select"`
+	MaxComplexity int `yaml:"max_complexity" env:"MAX_COMPLEXITY" flag:"max-complexity" usage:"highest estimated field count a query may select"`
+}
+
+// DefaultLimits allow an order with its user, items, products and shipments
+// several times over, but not the whole catalogue with every order.
+func DefaultLimits() Limits {
+	return Limits{MaxDepth: 8, MaxComplexity: 1000}
+}
+
+// Check reports invalid settings.
+func (l Limits) Check() error {
+	if l.MaxDepth <= 0 {
+		return fmt.Errorf("max_depth must be positive")
+	}
+	if l.MaxComplexity <= 0 {
+		return fmt.Errorf("max_complexity must be positive")
+	}
+	return nil
+}
+
+// defaultListSize is the number of elements assumed for list fields without
+// a limit argument, e.g. the items of an order.
+const defaultListSize = 10
+
+// measure computes the depth and the complexity of an operation. Every
+// field costs one; a list field multiplies the cost of its selection by its
+// limit argument, or by defaultListSize. The document must have passed
+// validation, so fragments exist and do not form cycles.
+type measure struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+func newMeasure(schema graphql.Schema, doc *ast.Document, variables map[string]interface{}) *measure {
+	m := &measure{schema: schema, fragments: map[string]*ast.FragmentDefinition{}, variables: variables}
+	for _, def := range doc.Definitions {
+		if frag, ok := def.(*ast.FragmentDefinition); ok {
+			m.fragments[frag.Name.Value] = frag
+		}
+	}
+	return m
+}
+
+// operation returns the operation Execute will run: the one named name, or
+// the only one in the document.
+func operation(doc *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" || (op.Name != nil && op.Name.Value == name) {
+			if found != nil && name == "" {
+				return nil
+			}
+			found = op
+		}
+	}
+	return found
+}
+
+// selection returns the depth and complexity of set, selected on parent.
+func (m *measure) selection(set *ast.SelectionSet, parent *graphql.Object) (depth, cost int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, sel := range set.Selections {
+		var d, c int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			d, c = m.field(sel, parent)
+		case *ast.InlineFragment:
+			d, c = m.selection(sel.SelectionSet, m.condition(sel.TypeCondition, parent))
+		case *ast.FragmentSpread:
+			if frag := m.fragments[sel.Name.Value]; frag != nil {
+				d, c = m.selection(frag.SelectionSet, m.condition(frag.TypeCondition, parent))
+			}
+		}
+		depth = max(depth, d)
+		cost += c
+	}
+	return depth, cost
+}
+
+func (m *measure) field(f *ast.Field, parent *graphql.Object) (depth, cost int) {
+	if parent == nil || strings.HasPrefix(f.Name.Value, "__") {
+		return 0, 0
+	}
+	def, ok := parent.Fields()[f.Name.Value]
+	if !ok {
+		return 0, 0
+	}
+
+	multiplier := 1
+	typ := def.Type
+	if nn, ok := typ.(*graphql.NonNull); ok {
+		typ = nn.OfType
+	}
+	if list, ok := typ.(*graphql.List); ok {
+		multiplier = m.listSize(f)
+		typ = list.OfType
+		if nn, ok := typ.(*graphql.NonNull); ok {
+			typ = nn.OfType
+		}
+	}
+	obj, _ := typ.(*graphql.Object)
+
+	depth, cost = m.selection(f.SelectionSet, obj)
+	return depth + 1, multiplier * (1 + cost)
+}
+
+// listSize is the limit argument of a list field, literal or variable.
+func (m *measure) listSize(f *ast.Field) int {
+	for _, arg := range f.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		var v interface{}
+		switch val := arg.Value.(type) {
+		case *ast.IntValue:
+			v = val.Value
+		case *ast.Variable:
+			v = m.variables[val.Name.Value]
+		}
+		if n, ok := toInt(v); ok && n > 0 {
+			return n
+		}
+	}
+	return defaultListSize
+}
+
+func (m *measure) condition(named *ast.Named, parent *graphql.Object) *graphql.Object {
+	if named == nil {
+		return parent
+	}
+	obj, _ := m.schema.Type(named.Name.Value).(*graphql.Object)
+	return obj
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	case string:
+		var n int
+		_, err := fmt.Sscan(v, &n)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// check reports the first limit the operation exceeds.
+func (l Limits) check(m *measure, op *ast.OperationDefinition) error {
+	depth, cost := m.selection(op.SelectionSet, m.schema.QueryType())
+	if depth > l.MaxDepth {
+		return fmt.Errorf("query depth %d exceeds the maximum of %d", depth, l.MaxDepth)
+	}
+	if cost > l.MaxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the maximum of %d", cost, l.MaxComplexity)
+	}
+	return nil
+}
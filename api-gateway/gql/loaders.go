@@ -0,0 +1,119 @@
+package gql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	orderpb "github.com/best-microservice/common/protos/order"
+	productpb "github.com/best-microservice/common/protos/product"
+	userpb "github.com/best-microservice/common/protos/user"
+	"github.com/graph-gophers/dataloader/v7"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// batchWait is how long a loader collects keys before calling the backend.
+// Resolvers of one level of the query run well within it.
+const batchWait = 2 * time.Millisecond
+
+// loaders deduplicate and batch the lookups of one request, so that the
+// products of all order items in a query cost a single GetProducts call.
+// They cache for the lifetime of the request only.
+type loaders struct {
+	users      *dataloader.Loader[string, *userpb.UserResponse]
+	products   *dataloader.Loader[string, *productpb.ProductResponse]
+	userOrders *dataloader.Loader[ordersPage, []*orderpb.OrderResponse]
+}
+
+func newLoaders(users userpb.UserServiceClient, products productpb.ProductServiceClient, orders orderpb.OrderServiceClient) *loaders {
+	return &loaders{
+		users:      dataloader.NewBatchedLoader(loadUsers(users), dataloader.WithWait[string, *userpb.UserResponse](batchWait)),
+		products:   dataloader.NewBatchedLoader(loadProducts(products), dataloader.WithWait[string, *productpb.ProductResponse](batchWait)),
+		userOrders: dataloader.NewBatchedLoader(loadUserOrders(orders), dataloader.WithWait[ordersPage, []*orderpb.OrderResponse](batchWait)),
+	}
+}
+
+// ordersPage is a page of the orders of a user.
+type ordersPage struct {
+	userID        string
+	limit, offset int32
+}
+
+// loadUserOrders fetches a batch with one GetOrdersByUsers call per distinct
+// page; a query normally asks for the same page of every user.
+func loadUserOrders(client orderpb.OrderServiceClient) dataloader.BatchFunc[ordersPage, []*orderpb.OrderResponse] {
+	return func(ctx context.Context, keys []ordersPage) []*dataloader.Result[[]*orderpb.OrderResponse] {
+		results := make([]*dataloader.Result[[]*orderpb.OrderResponse], len(keys))
+		batches := map[ordersPage][]int{}
+		for i, key := range keys {
+			page := ordersPage{limit: key.limit, offset: key.offset}
+			batches[page] = append(batches[page], i)
+		}
+
+		for page, indexes := range batches {
+			req := &orderpb.GetOrdersByUsersRequest{Limit: page.limit, Offset: page.offset, Expand: orderExpand}
+			for _, i := range indexes {
+				req.UserIds = append(req.UserIds, keys[i].userID)
+			}
+			res, err := client.GetOrdersByUsers(ctx, req)
+			for n, i := range indexes {
+				switch {
+				case err != nil:
+					results[i] = &dataloader.Result[[]*orderpb.OrderResponse]{Error: err}
+				case n < len(res.Users):
+					results[i] = &dataloader.Result[[]*orderpb.OrderResponse]{Data: res.Users[n].Orders}
+				default:
+					results[i] = &dataloader.Result[[]*orderpb.OrderResponse]{}
+				}
+			}
+		}
+		return results
+	}
+}
+
+// loadProducts fetches a batch with one GetProducts call. Products missing
+// from the response resolve to nil.
+func loadProducts(client productpb.ProductServiceClient) dataloader.BatchFunc[string, *productpb.ProductResponse] {
+	return func(ctx context.Context, ids []string) []*dataloader.Result[*productpb.ProductResponse] {
+		results := make([]*dataloader.Result[*productpb.ProductResponse], len(ids))
+		res, err := client.GetProducts(ctx, &productpb.GetProductsRequest{Ids: ids})
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result[*productpb.ProductResponse]{Error: err}
+			}
+			return results
+		}
+
+		byID := make(map[string]*productpb.ProductResponse, len(res.Products))
+		for _, p := range res.Products {
+			byID[p.Id] = p
+		}
+		for i, id := range ids {
+			results[i] = &dataloader.Result[*productpb.ProductResponse]{Data: byID[id]}
+		}
+		return results
+	}
+}
+
+// loadUsers fetches a batch with concurrent GetUser calls, as the user
+// service has no batch lookup. Unknown users resolve to nil.
+func loadUsers(client userpb.UserServiceClient) dataloader.BatchFunc[string, *userpb.UserResponse] {
+	return func(ctx context.Context, ids []string) []*dataloader.Result[*userpb.UserResponse] {
+		results := make([]*dataloader.Result[*userpb.UserResponse], len(ids))
+		var wg sync.WaitGroup
+		for i, id := range ids {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				user, err := client.GetUser(ctx, &userpb.GetUserRequest{Id: id})
+				if status.Code(err) == codes.NotFound {
+					user, err = nil, nil
+				}
+				results[i] = &dataloader.Result[*userpb.UserResponse]{Data: user, Error: err}
+			}()
+		}
+		wg.Wait()
+		return results
+	}
+}
@@ -0,0 +1,97 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	orderpb "github.com/best-microservice/common/protos/order"
+	"google.golang.org/grpc"
+)
+
+// fakeOrders answers GetOrdersByUsers with one order per user, whose ID is
+// the user ID and the page, and records the calls.
+type fakeOrders struct {
+	orderpb.OrderServiceClient
+	calls []*orderpb.GetOrdersByUsersRequest
+	err   error
+}
+
+func (f *fakeOrders) GetOrdersByUsers(_ context.Context, req *orderpb.GetOrdersByUsersRequest, _ ...grpc.CallOption) (*orderpb.GetOrdersByUsersResponse, error) {
+	f.calls = append(f.calls, req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	res := &orderpb.GetOrdersByUsersResponse{}
+	for _, id := range req.UserIds {
+		res.Users = append(res.Users, &orderpb.GetUserOrdersResponse{
+			Orders: []*orderpb.OrderResponse{{Id: fmt.Sprintf("%s@%d", id, req.Offset), UserId: id}},
+			Total:  1,
+		})
+	}
+	return res, nil
+}
+
+func TestLoadUserOrders(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      []ordersPage
+		err       error
+		wantCalls []string // user IDs of each call, sorted
+		wantIDs   []string
+	}{
+		{
+			name:      "one call for the same page of every user",
+			keys:      []ordersPage{{"u1", 10, 0}, {"u2", 10, 0}, {"u3", 10, 0}},
+			wantCalls: []string{"u1,u2,u3"},
+			wantIDs:   []string{"u1@0", "u2@0", "u3@0"},
+		},
+		{
+			name:      "one call per distinct page",
+			keys:      []ordersPage{{"u1", 10, 0}, {"u2", 10, 1}, {"u3", 10, 0}},
+			wantCalls: []string{"u1,u3", "u2"},
+			wantIDs:   []string{"u1@0", "u2@1", "u3@0"},
+		},
+		{
+			name:      "errors fail the whole batch",
+			keys:      []ordersPage{{"u1", 10, 0}, {"u2", 10, 0}},
+			err:       errors.New("unavailable"),
+			wantCalls: []string{"u1,u2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeOrders{err: tt.err}
+			results := loadUserOrders(client)(context.Background(), tt.keys)
+
+			var calls []string
+			for _, call := range client.calls {
+				ids := append([]string(nil), call.UserIds...)
+				sort.Strings(ids)
+				calls = append(calls, strings.Join(ids, ","))
+				if strings.Join(call.Expand, ",") != strings.Join(orderExpand, ",") {
+					t.Errorf("expand = %v, want %v", call.Expand, orderExpand)
+				}
+			}
+			sort.Strings(calls)
+			if strings.Join(calls, " ") != strings.Join(tt.wantCalls, " ") {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+
+			for i, res := range results {
+				if tt.err != nil {
+					if res.Error == nil {
+						t.Errorf("result %d: no error", i)
+					}
+					continue
+				}
+				if len(res.Data) != 1 || res.Data[0].Id != tt.wantIDs[i] {
+					t.Errorf("result %d = %v, want order %s", i, res.Data, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
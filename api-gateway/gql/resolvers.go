@@ -0,0 +1,158 @@
+package gql
+
+import (
+	"github.com/best-microservice/common/apierror"
+	orderpb "github.com/best-microservice/common/protos/order"
+	productpb "github.com/best-microservice/common/protos/product"
+	userpb "github.com/best-microservice/common/protos/user"
+	"github.com/graphql-go/graphql"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/best-microservice/api-gateway/problem"
+)
+
+// Resolvers of fields that may be selected many times in one query, such as
+// the product of an order item, go through the request's loaders and return
+// thunks; graphql-go calls the thunks once all fields of a level have been
+// resolved, by which time the loader has collected the whole batch.
+
+// user resolves the user with the given ID, or null if there is none.
+func (s *Server) user(p graphql.ResolveParams, id string) func() (interface{}, error) {
+	thunk := requestFrom(p.Context).loaders.users.Load(p.Context, id)
+	return func() (interface{}, error) {
+		u, err := thunk()
+		if err != nil || u == nil {
+			return nil, convert(err)
+		}
+		return u, nil
+	}
+}
+
+// product resolves the product with the given ID, or null if there is none.
+func (s *Server) product(p graphql.ResolveParams, id string) func() (interface{}, error) {
+	thunk := requestFrom(p.Context).loaders.products.Load(p.Context, id)
+	return func() (interface{}, error) {
+		product, err := thunk()
+		if err != nil || product == nil {
+			return nil, convert(err)
+		}
+		return product, nil
+	}
+}
+
+func (s *Server) me(p graphql.ResolveParams) (interface{}, error) {
+	id := requestFrom(p.Context).userID
+	if id == "" {
+		return nil, errUnauthenticated
+	}
+	return s.user(p, id), nil
+}
+
+var errUnauthenticated = &Error{Message: "authentication required", Code: apierror.ReasonUnauthenticated}
+
+// checkViewer fails unless the request is authenticated as the user with
+// the given ID, whose private data is about to be resolved.
+func checkViewer(p graphql.ResolveParams, userID string) error {
+	switch requestFrom(p.Context).userID {
+	case "":
+		return errUnauthenticated
+	case userID:
+		return nil
+	}
+	return &Error{Message: "only the user's own data can be read", Code: apierror.ReasonPermissionDenied}
+}
+
+// orderExpand is the expansion of every order fetched. Snapshots are stored
+// with the order and cost no extra lookups; current products and users are
+// resolved through the loaders instead, so they are batched across orders.
+var orderExpand = []string{"items.snapshot"}
+
+// order resolves an order of the viewer, or null if there is none. Orders
+// of other users are null as well, like on the REST API.
+func (s *Server) order(p graphql.ResolveParams) (interface{}, error) {
+	viewer := requestFrom(p.Context).userID
+	if viewer == "" {
+		return nil, errUnauthenticated
+	}
+	res, err := s.orders.GetOrder(p.Context, &orderpb.GetOrderRequest{Id: p.Args["id"].(string), Expand: orderExpand})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, convert(err)
+	}
+	if res.UserId != viewer {
+		return nil, nil
+	}
+	return res, nil
+}
+
+// userOrders resolves a page of the orders of a user, newest first.
+func (s *Server) userOrders(p graphql.ResolveParams, userID string) (interface{}, error) {
+	if err := checkViewer(p, userID); err != nil {
+		return nil, err
+	}
+	limit, offset := page(p.Args)
+	thunk := requestFrom(p.Context).loaders.userOrders.Load(p.Context, ordersPage{userID: userID, limit: limit, offset: offset})
+	return func() (interface{}, error) {
+		orders, err := thunk()
+		if err != nil {
+			return nil, convert(err)
+		}
+		return orders, nil
+	}, nil
+}
+
+func (s *Server) listProducts(p graphql.ResolveParams) (interface{}, error) {
+	limit, offset := page(p.Args)
+	res, err := s.products.ListProducts(p.Context, &productpb.ListProductsRequest{Limit: limit, Offset: offset})
+	if err != nil {
+		return nil, convert(err)
+	}
+	// Later lookups of the same products in this request need no backend call
+	for _, product := range res.Products {
+		requestFrom(p.Context).loaders.products.Prime(p.Context, product.Id, product)
+	}
+	return res.Products, nil
+}
+
+func (s *Server) addresses(p graphql.ResolveParams, userID string) (interface{}, error) {
+	if err := checkViewer(p, userID); err != nil {
+		return nil, err
+	}
+	res, err := s.users.ListAddresses(p.Context, &userpb.ListAddressesRequest{UserId: userID})
+	if err != nil {
+		return nil, convert(err)
+	}
+	return res.Addresses, nil
+}
+
+// Error is a GraphQL error carrying the API's error code, and field errors
+// for invalid arguments, in its extensions.
+type Error struct {
+	Message string
+	Code    string
+	Fields  []problem.FieldError
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Extensions implements gqlerrors.ExtendedError.
+func (e *Error) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"code": e.Code}
+	if len(e.Fields) > 0 {
+		ext["errors"] = e.Fields
+	}
+	return ext
+}
+
+// convert translates a failed backend call the way the REST API does, see
+// problem.Convert. A nil err stays nil.
+func convert(err error) error {
+	if err == nil {
+		return nil
+	}
+	p := problem.Convert(err)
+	return &Error{Message: p.Detail, Code: p.Code, Fields: p.Errors}
+}
@@ -0,0 +1,105 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	orderpb "github.com/best-microservice/common/protos/order"
+	userpb "github.com/best-microservice/common/protos/user"
+	"google.golang.org/grpc"
+)
+
+// fakeUsers knows every user; each has one address.
+type fakeUsers struct {
+	userpb.UserServiceClient
+}
+
+func (fakeUsers) GetUser(_ context.Context, req *userpb.GetUserRequest, _ ...grpc.CallOption) (*userpb.UserResponse, error) {
+	return &userpb.UserResponse{Id: req.Id, Name: "User " + req.Id}, nil
+}
+
+func (fakeUsers) ListAddresses(_ context.Context, req *userpb.ListAddressesRequest, _ ...grpc.CallOption) (*userpb.ListAddressesResponse, error) {
+	return &userpb.ListAddressesResponse{Addresses: []*userpb.Address{{Id: "a1", UserId: req.UserId}}}, nil
+}
+
+// GetOrder answers order "o-<user>" as placed by that user.
+func (f *fakeOrders) GetOrder(_ context.Context, req *orderpb.GetOrderRequest, _ ...grpc.CallOption) (*orderpb.OrderResponse, error) {
+	return &orderpb.OrderResponse{Id: req.Id, UserId: strings.TrimPrefix(req.Id, "o-")}, nil
+}
+
+func TestViewerAccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		viewer   string
+		query    string
+		wantData string
+		wantCode string
+	}{
+		{
+			name:     "own addresses and orders",
+			viewer:   "u1",
+			query:    `{ user(id: "u1") { addresses { id } orders { id } } }`,
+			wantData: `{"user":{"addresses":[{"id":"a1"}],"orders":[{"id":"u1@0"}]}}`,
+		},
+		{
+			name:     "addresses of another user",
+			viewer:   "u1",
+			query:    `{ user(id: "u2") { name addresses { id } } }`,
+			wantData: `{"user":{"addresses":null,"name":"User u2"}}`,
+			wantCode: "PERMISSION_DENIED",
+		},
+		{
+			name:     "orders of another user",
+			viewer:   "u1",
+			query:    `{ orders(userId: "u2") { id } }`,
+			wantData: `{"orders":null}`,
+			wantCode: "PERMISSION_DENIED",
+		},
+		{
+			name:     "orders without a session",
+			query:    `{ orders(userId: "u1") { id } }`,
+			wantData: `{"orders":null}`,
+			wantCode: "UNAUTHENTICATED",
+		},
+		{
+			name:     "own order",
+			viewer:   "u1",
+			query:    `{ order(id: "o-u1") { id } }`,
+			wantData: `{"order":{"id":"o-u1"}}`,
+		},
+		{
+			name:     "order of another user",
+			viewer:   "u1",
+			query:    `{ order(id: "o-u2") { id } }`,
+			wantData: `{"order":null}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{users: fakeUsers{}, orders: &fakeOrders{}, limits: DefaultLimits()}
+			var err error
+			if s.gql, err = s.schema(); err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.WithValue(context.Background(), requestKey{}, &request{
+				loaders: newLoaders(s.users, s.products, s.orders),
+				userID:  tt.viewer,
+			})
+
+			res := s.execute(ctx, Request{Query: tt.query})
+			data, _ := json.Marshal(res.Data)
+			if string(data) != tt.wantData {
+				t.Errorf("data %s, want %s", data, tt.wantData)
+			}
+			var codes []string
+			for _, e := range res.Errors {
+				codes = append(codes, e.Extensions["code"].(string))
+			}
+			if strings.Join(codes, ",") != tt.wantCode {
+				t.Errorf("errors %v, want code %q", res.Errors, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package gql
+
+import (
+	orderpb "github.com/best-microservice/common/protos/order"
+	productpb "github.com/best-microservice/common/protos/product"
+	userpb "github.com/best-microservice/common/protos/user"
+	"github.com/graphql-go/graphql"
+)
+
+// maxPageSize caps the limit argument of paginated fields.
+const maxPageSize = 100
+
+// field returns a field read from the source object with get.
+func field[T any](typ graphql.Output, get func(T) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: typ,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(T)), nil
+		},
+	}
+}
+
+// optional turns the empty strings the services use for unset timestamps
+// into null.
+func optional(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// pageArgs are the arguments of paginated list fields.
+var pageArgs = graphql.FieldConfigArgument{
+	"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20, Description: "at most 100"},
+	"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+}
+
+func page(args map[string]interface{}) (limit, offset int32) {
+	l, _ := args["limit"].(int)
+	o, _ := args["offset"].(int)
+	return int32(min(max(l, 1), maxPageSize)), int32(max(o, 0))
+}
+
+var (
+	nonNullString = graphql.NewNonNull(graphql.String)
+	nonNullID     = graphql.NewNonNull(graphql.ID)
+)
+
+// schema builds the GraphQL schema, resolving fields through the clients
+// of s and the loaders of the request.
+func (s *Server) schema() (graphql.Schema, error) {
+	product := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Product",
+		Fields: graphql.Fields{
+			"id":          field(nonNullID, func(p *productpb.ProductResponse) interface{} { return p.Id }),
+			"name":        field(nonNullString, func(p *productpb.ProductResponse) interface{} { return p.Name }),
+			"description": field(graphql.String, func(p *productpb.ProductResponse) interface{} { return p.Description }),
+			"price":       field(graphql.Float, func(p *productpb.ProductResponse) interface{} { return p.Price }),
+			"weight":      field(graphql.Float, func(p *productpb.ProductResponse) interface{} { return p.Weight }),
+			"stock":       field(graphql.Int, func(p *productpb.ProductResponse) interface{} { return p.Stock }),
+			"createdAt":   field(graphql.String, func(p *productpb.ProductResponse) interface{} { return optional(p.CreatedAt) }),
+			"updatedAt":   field(graphql.String, func(p *productpb.ProductResponse) interface{} { return optional(p.UpdatedAt) }),
+		},
+	})
+
+	address := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Address",
+		Description: "An entry of a user's address book.",
+		Fields: graphql.Fields{
+			"id":         field(nonNullID, func(a *userpb.Address) interface{} { return a.Id }),
+			"label":      field(graphql.String, func(a *userpb.Address) interface{} { return a.Label }),
+			"recipient":  field(graphql.String, func(a *userpb.Address) interface{} { return a.Recipient }),
+			"line1":      field(graphql.String, func(a *userpb.Address) interface{} { return a.Line1 }),
+			"line2":      field(graphql.String, func(a *userpb.Address) interface{} { return a.Line2 }),
+			"city":       field(graphql.String, func(a *userpb.Address) interface{} { return a.City }),
+			"state":      field(graphql.String, func(a *userpb.Address) interface{} { return a.State }),
+			"postalCode": field(graphql.String, func(a *userpb.Address) interface{} { return a.PostalCode }),
+			"country":    field(graphql.String, func(a *userpb.Address) interface{} { return a.Country }),
+			"phone":      field(graphql.String, func(a *userpb.Address) interface{} { return a.Phone }),
+			"isDefault":  field(graphql.Boolean, func(a *userpb.Address) interface{} { return a.IsDefault }),
+			"createdAt":  field(graphql.String, func(a *userpb.Address) interface{} { return optional(a.CreatedAt) }),
+		},
+	})
+
+	orderAddress := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "OrderAddress",
+		Description: "An address as it was when the order was placed.",
+		Fields: graphql.Fields{
+			"recipient":  field(graphql.String, func(a *orderpb.Address) interface{} { return a.Recipient }),
+			"line1":      field(graphql.String, func(a *orderpb.Address) interface{} { return a.Line1 }),
+			"line2":      field(graphql.String, func(a *orderpb.Address) interface{} { return a.Line2 }),
+			"city":       field(graphql.String, func(a *orderpb.Address) interface{} { return a.City }),
+			"state":      field(graphql.String, func(a *orderpb.Address) interface{} { return a.State }),
+			"postalCode": field(graphql.String, func(a *orderpb.Address) interface{} { return a.PostalCode }),
+			"country":    field(graphql.String, func(a *orderpb.Address) interface{} { return a.Country }),
+			"phone":      field(graphql.String, func(a *orderpb.Address) interface{} { return a.Phone }),
+		},
+	})
+
+	productSnapshot := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "ProductSnapshot",
+		Description: "A product as it was when the order was placed.",
+		Fields: graphql.Fields{
+			"name":        field(nonNullString, func(p *orderpb.ProductSnapshot) interface{} { return p.Name }),
+			"description": field(graphql.String, func(p *orderpb.ProductSnapshot) interface{} { return p.Description }),
+		},
+	})
+
+	orderItem := graphql.NewObject(graphql.ObjectConfig{
+		Name: "OrderItem",
+		Fields: graphql.Fields{
+			"productId": field(nonNullID, func(i *orderpb.OrderItem) interface{} { return i.ProductId }),
+			"product": {
+				Type:        product,
+				Description: "The product as it is now; null if it no longer exists.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return s.product(p, p.Source.(*orderpb.OrderItem).ProductId), nil
+				},
+			},
+			"snapshot": {
+				Type:        productSnapshot,
+				Description: "The product as it was when the order was placed; null for orders placed before snapshots were kept.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if snap := p.Source.(*orderpb.OrderItem).Snapshot; snap != nil {
+						return snap, nil
+					}
+					return nil, nil
+				},
+			},
+			"quantity": field(graphql.Int, func(i *orderpb.OrderItem) interface{} { return i.Quantity }),
+			"price":    field(graphql.Float, func(i *orderpb.OrderItem) interface{} { return i.Price }),
+			"weight":   field(graphql.Float, func(i *orderpb.OrderItem) interface{} { return i.Weight }),
+		},
+	})
+
+	shipmentEvent := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ShipmentEvent",
+		Fields: graphql.Fields{
+			"status":     field(nonNullString, func(e *orderpb.ShipmentEvent) interface{} { return e.Status }),
+			"note":       field(graphql.String, func(e *orderpb.ShipmentEvent) interface{} { return e.Note }),
+			"occurredAt": field(graphql.String, func(e *orderpb.ShipmentEvent) interface{} { return optional(e.OccurredAt) }),
+		},
+	})
+
+	shipment := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Shipment",
+		Fields: graphql.Fields{
+			"id":             field(nonNullID, func(sh *orderpb.Shipment) interface{} { return sh.Id }),
+			"carrier":        field(graphql.String, func(sh *orderpb.Shipment) interface{} { return sh.Carrier }),
+			"trackingNumber": field(graphql.String, func(sh *orderpb.Shipment) interface{} { return sh.TrackingNumber }),
+			"status":         field(nonNullString, func(sh *orderpb.Shipment) interface{} { return sh.Status }),
+			"shippedAt":      field(graphql.String, func(sh *orderpb.Shipment) interface{} { return optional(sh.ShippedAt) }),
+			"deliveredAt":    field(graphql.String, func(sh *orderpb.Shipment) interface{} { return optional(sh.DeliveredAt) }),
+			"createdAt":      field(graphql.String, func(sh *orderpb.Shipment) interface{} { return optional(sh.CreatedAt) }),
+			"events":         field(graphql.NewList(shipmentEvent), func(sh *orderpb.Shipment) interface{} { return sh.Events }),
+		},
+	})
+
+	// User and Order refer to each other, so their fields are thunks.
+	var user, order *graphql.Object
+	user = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: (graphql.FieldsThunk)(func() graphql.Fields {
+			return graphql.Fields{
+				"id":            field(nonNullID, func(u *userpb.UserResponse) interface{} { return u.Id }),
+				"name":          field(nonNullString, func(u *userpb.UserResponse) interface{} { return u.Name }),
+				"email":         field(nonNullString, func(u *userpb.UserResponse) interface{} { return u.Email }),
+				"emailVerified": field(graphql.Boolean, func(u *userpb.UserResponse) interface{} { return u.EmailVerified }),
+				"mfaEnabled":    field(graphql.Boolean, func(u *userpb.UserResponse) interface{} { return u.MfaEnabled }),
+				"createdAt":     field(graphql.String, func(u *userpb.UserResponse) interface{} { return optional(u.CreatedAt) }),
+				"addresses": {
+					Type:        graphql.NewList(address),
+					Description: "Only the user can read their addresses.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return s.addresses(p, p.Source.(*userpb.UserResponse).Id)
+					},
+				},
+				"orders": {
+					Type:        graphql.NewList(order),
+					Description: "Only the user can read their orders.",
+					Args:        pageArgs,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return s.userOrders(p, p.Source.(*userpb.UserResponse).Id)
+					},
+				},
+			}
+		}),
+	})
+	order = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Order",
+		Fields: (graphql.FieldsThunk)(func() graphql.Fields {
+			return graphql.Fields{
+				"id":     field(nonNullID, func(o *orderpb.OrderResponse) interface{} { return o.Id }),
+				"userId": field(nonNullID, func(o *orderpb.OrderResponse) interface{} { return o.UserId }),
+				"user": {
+					Type:        user,
+					Description: "The user who placed the order; null if the account no longer exists.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return s.user(p, p.Source.(*orderpb.OrderResponse).UserId), nil
+					},
+				},
+				"status":           field(nonNullString, func(o *orderpb.OrderResponse) interface{} { return o.Status }),
+				"items":            field(graphql.NewList(orderItem), func(o *orderpb.OrderResponse) interface{} { return o.Items }),
+				"subtotal":         field(graphql.Float, func(o *orderpb.OrderResponse) interface{} { return o.Subtotal }),
+				"shippingCost":     field(graphql.Float, func(o *orderpb.OrderResponse) interface{} { return o.ShippingCost }),
+				"total":            field(graphql.Float, func(o *orderpb.OrderResponse) interface{} { return o.Total }),
+				"shippingMethodId": field(graphql.ID, func(o *orderpb.OrderResponse) interface{} { return optional(o.ShippingMethodId) }),
+				"shippingAddress":  field(orderAddress, func(o *orderpb.OrderResponse) interface{} { return o.ShippingAddress }),
+				"billingAddress":   field(orderAddress, func(o *orderpb.OrderResponse) interface{} { return o.BillingAddress }),
+				"shipments":        field(graphql.NewList(shipment), func(o *orderpb.OrderResponse) interface{} { return o.Shipments }),
+				"createdAt":        field(graphql.String, func(o *orderpb.OrderResponse) interface{} { return optional(o.CreatedAt) }),
+			}
+		}),
+	})
+
+	idArgs := graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: nonNullID}}
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": {
+				Type:        user,
+				Description: "The authenticated user.",
+				Resolve:     s.me,
+			},
+			"user": {
+				Type: user,
+				Args: idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return s.user(p, p.Args["id"].(string)), nil
+				},
+			},
+			"product": {
+				Type: product,
+				Args: idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return s.product(p, p.Args["id"].(string)), nil
+				},
+			},
+			"products": {
+				Type:    graphql.NewList(product),
+				Args:    pageArgs,
+				Resolve: s.listProducts,
+			},
+			"order": {
+				Type:        order,
+				Description: "An order of the authenticated user; null for orders of other users.",
+				Args:        idArgs,
+				Resolve:     s.order,
+			},
+			"orders": {
+				Type:        graphql.NewList(order),
+				Description: "The orders of the authenticated user, who must be userId.",
+				Args: graphql.FieldConfigArgument{
+					"userId": &graphql.ArgumentConfig{Type: nonNullID},
+					"limit":  pageArgs["limit"],
+					"offset": pageArgs["offset"],
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return s.userOrders(p, p.Args["userId"].(string))
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
@@ -0,0 +1,18 @@
+package handlers
+
+// Request bodies of the hand-written routes. The OpenAPI document is
+// generated from these types, so binding rules and json names here are the
+// contract; responses are proto messages written by transcode.Write.
+
+type CreateOrderRequest struct {
+	UserID            string             `json:"user_id" binding:"required"`
+	Items             []OrderItemRequest `json:"items" binding:"required,min=1"`
+	ShippingMethodID  string             `json:"shipping_method_id"`
+	ShippingAddressID string             `json:"shipping_address_id" binding:"required_with=ShippingMethodID"`
+	BillingAddressID  string             `json:"billing_address_id"`
+}
+
+type OrderItemRequest struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,gt=0"`
+}
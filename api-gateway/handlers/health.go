@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Dependency is a backend the gateway needs in order to serve traffic.
+type Dependency struct {
+	Name string
+	// Service is the grpc.health.v1 service name to check; "" checks the
+	// server as a whole.
+	Service string
+	Conn    *grpc.ClientConn
+}
+
+type HealthHandler struct {
+	deps    []Dependency
+	timeout time.Duration
+}
+
+func NewHealthHandler(timeout time.Duration, deps ...Dependency) *HealthHandler {
+	return &HealthHandler{deps: deps, timeout: timeout}
+}
+
+// Livez reports that the gateway process is up. It never looks at the
+// backends, so an outage there doesn't get the gateway restarted.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz checks every backend concurrently and reports 503 unless all of
+// them are SERVING, with the result of each check.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	defer cancel()
+
+	results := make([]gin.H, len(h.deps))
+	ready := true
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, dep := range h.deps {
+		wg.Add(1)
+		go func(i int, dep Dependency) {
+			defer wg.Done()
+			result, ok := checkDependency(ctx, dep)
+			mu.Lock()
+			results[i] = result
+			ready = ready && ok
+			mu.Unlock()
+		}(i, dep)
+	}
+	wg.Wait()
+
+	code, overall := http.StatusOK, "ok"
+	if !ready {
+		code, overall = http.StatusServiceUnavailable, "unavailable"
+	}
+	c.JSON(code, gin.H{"status": overall, "dependencies": results})
+}
+
+func checkDependency(ctx context.Context, dep Dependency) (gin.H, bool) {
+	start := time.Now()
+	resp, err := healthpb.NewHealthClient(dep.Conn).Check(ctx, &healthpb.HealthCheckRequest{Service: dep.Service})
+	result := gin.H{
+		"name":       dep.Name,
+		"latency_ms": time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		st, _ := status.FromError(err)
+		result["status"] = "UNREACHABLE"
+		result["error"] = st.Message()
+		return result, false
+	}
+	result["status"] = resp.Status.String()
+	return result, resp.Status == healthpb.HealthCheckResponse_SERVING
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthBackend serves grpc.health.v1 reporting status for service and
+// returns a connection to it.
+func healthBackend(t *testing.T, service string, status healthpb.HealthCheckResponse_ServingStatus) *grpc.ClientConn {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	hs.SetServingStatus(service, status)
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return dial(t, lis.Addr().String())
+}
+
+func dial(t *testing.T, addr string) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestReadyz(t *testing.T) {
+	serving := healthBackend(t, "user.UserService", healthpb.HealthCheckResponse_SERVING)
+	notServing := healthBackend(t, "order.OrderService", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	// a port nothing listens on
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis.Close()
+	down := dial(t, lis.Addr().String())
+
+	tests := []struct {
+		name       string
+		deps       []Dependency
+		wantCode   int
+		wantStatus []string
+	}{
+		{
+			name:       "all serving",
+			deps:       []Dependency{{Name: "user", Service: "user.UserService", Conn: serving}},
+			wantCode:   http.StatusOK,
+			wantStatus: []string{"SERVING"},
+		},
+		{
+			name: "one not serving",
+			deps: []Dependency{
+				{Name: "user", Service: "user.UserService", Conn: serving},
+				{Name: "order", Service: "order.OrderService", Conn: notServing},
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: []string{"SERVING", "NOT_SERVING"},
+		},
+		{
+			name:       "unknown service",
+			deps:       []Dependency{{Name: "product", Service: "product.ProductService", Conn: serving}},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: []string{"UNREACHABLE"},
+		},
+		{
+			name:       "unreachable",
+			deps:       []Dependency{{Name: "user", Service: "user.UserService", Conn: down}},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: []string{"UNREACHABLE"},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			NewHealthHandler(2*time.Second, tt.deps...).Readyz(c)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			var body struct {
+				Dependencies []struct{ Name, Status string }
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Dependencies) != len(tt.deps) {
+				t.Fatalf("dependencies = %+v", body.Dependencies)
+			}
+			for i, dep := range body.Dependencies {
+				if dep.Name != tt.deps[i].Name || dep.Status != tt.wantStatus[i] {
+					t.Errorf("dependency %d = %+v, want %s %s", i, dep, tt.deps[i].Name, tt.wantStatus[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLivez(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/livez", nil)
+
+	// the backend is never asked
+	NewHealthHandler(time.Second, Dependency{Name: "user"}).Livez(c)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d", w.Code)
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/best-microservice/api-gateway/problem"
+	orderpb "github.com/best-microservice/common/protos/order"
+	"github.com/gin-gonic/gin"
+)
+
+// GetInvoice returns the invoice of a paid order as PDF, or as HTML when
+// requested through ?format=html or an Accept: text/html header.
+func (h *OrderHandler) GetInvoice(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		format = "pdf"
+		if c.NegotiateFormat(gin.MIMEHTML, "application/pdf") == gin.MIMEHTML {
+			format = "html"
+		}
+	}
+
+	res, err := h.client.GetInvoice(c.Request.Context(), &orderpb.GetInvoiceRequest{
+		OrderId: c.Param("id"),
+		Format:  format,
+	})
+	if err != nil {
+		problem.FromGRPC(c, err)
+		return
+	}
+
+	c.Header("X-Invoice-Number", res.Number)
+	if format == "pdf" {
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", res.Number+".pdf"))
+	}
+	c.Data(http.StatusOK, res.ContentType, res.Content)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	orderpb "github.com/best-microservice/common/protos/order"
+
+	"github.com/best-microservice/api-gateway/openapi"
+)
+
+// Operations describes the routes served by OrderHandler, relative to the
+// API base path, for the OpenAPI document. All other routes are transcoded
+// from the proto annotations and described from them.
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{Method: http.MethodPost, Path: "/orders", ID: "createOrder", Tag: "Order", Summary: "Place an order",
+			Params:  []openapi.Param{AuthorizationParam},
+			Request: CreateOrderRequest{}, Status: http.StatusCreated, Response: &orderpb.OrderResponse{}},
+		{Method: http.MethodGet, Path: "/orders/:id/invoice", ID: "getInvoice", Tag: "Order", Summary: "Download the invoice of a paid order",
+			Params: []openapi.Param{AuthorizationParam, {Name: "format", In: "query", Type: "string", Description: "pdf or html; defaults to the Accept header, then pdf"}},
+			Status: http.StatusOK, ResponseTypes: []string{"application/pdf", "text/html"}},
+		{Method: http.MethodGet, Path: "/orders/:id/events", ID: "watchOrderEvents", Tag: "Order",
+			Summary: "Stream the status changes of an order as Server-Sent Events",
+			Params: []openapi.Param{AuthorizationParam, lastEventIDParam, {Name: LastEventIDHeader, In: "header", Type: "integer",
+				Description: "Sent by EventSource on reconnect; takes precedence over last_event_id"}},
+			Status: http.StatusOK, ResponseTypes: []string{"text/event-stream"}},
+		{Method: http.MethodGet, Path: "/orders/:id/ws", ID: "watchOrderSocket", Tag: "Order",
+			Summary: "Stream the status changes of an order over a WebSocket",
+			Params:  []openapi.Param{AuthorizationParam, lastEventIDParam}, Status: http.StatusSwitchingProtocols},
+	}
+}
+
+// AuthorizationParam identifies the user of routes that act for them.
+var AuthorizationParam = openapi.Param{Name: "Authorization", In: "header", Type: "string", Required: true,
+	Description: "Bearer followed by the session token returned at login"}
+
+// lastEventIDParam resumes an order watch after the given event.
+var lastEventIDParam = openapi.Param{Name: "last_event_id", In: "query", Type: "integer",
+	Description: "ID of the last event received; only later events are sent"}
@@ -3,36 +3,51 @@ package handlers
 import (
 	"context"
 	"net/http"
-	"strconv"
+	"time"
 
+	"github.com/best-microservice/api-gateway/middleware"
+	"github.com/best-microservice/api-gateway/problem"
+	"github.com/best-microservice/api-gateway/transcode"
+	"github.com/best-microservice/common/apierror"
 	orderpb "github.com/best-microservice/common/protos/order"
+	userpb "github.com/best-microservice/common/protos/user"
 	"github.com/gin-gonic/gin"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/status"
 )
 
 type OrderHandler struct {
-	client orderpb.OrderServiceClient
+	client     orderpb.OrderServiceClient
+	userClient userpb.UserServiceClient
+	// heartbeat is the interval of keep-alives on order watch streams.
+	heartbeat time.Duration
 }
 
-func NewOrderHandler(conn *grpc.ClientConn) *OrderHandler {
+func NewOrderHandler(conn *grpc.ClientConn, userConn *grpc.ClientConn, heartbeat time.Duration) *OrderHandler {
 	return &OrderHandler{
-		client: orderpb.NewOrderServiceClient(conn),
+		client:     orderpb.NewOrderServiceClient(conn),
+		userClient: userpb.NewUserServiceClient(userConn),
+		heartbeat:  heartbeat,
 	}
 }
 
+// CreateOrder places an order for the signed in user. Unlike the
+// transcoded routes it takes the IDs of addresses in the user's address
+// book and snapshots them into the order.
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
-	var req struct {
-		UserID string `json:"user_id" binding:"required"`
-		Items  []struct {
-			ProductID string  `json:"product_id" binding:"required"`
-			Quantity  int     `json:"quantity" binding:"required,gt=0"`
-			Price     float64 `json:"price" binding:"required,gt=0"`
-		} `json:"items" binding:"required,min=1"`
-	}
+	var req CreateOrderRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Validation(c, err)
+		return
+	}
+	userID := c.GetString(middleware.UserIDKey)
+	if userID == "" {
+		c.Header("WWW-Authenticate", "Bearer")
+		problem.Abort(c, http.StatusUnauthorized, apierror.ReasonUnauthenticated, "authentication required")
+		return
+	}
+	if req.UserID != userID {
+		problem.Abort(c, http.StatusForbidden, apierror.ReasonPermissionDenied, "orders can only be placed for the signed in user")
 		return
 	}
 
@@ -42,113 +57,83 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		items[i] = &orderpb.OrderItem{
 			ProductId: item.ProductID,
 			Quantity:  int32(item.Quantity),
-			Price:     float32(item.Price),
 		}
 	}
 
 	grpcReq := &orderpb.CreateOrderRequest{
-		UserId: req.UserID,
-		Items:  items,
+		UserId:           req.UserID,
+		Items:            items,
+		ShippingMethodId: req.ShippingMethodID,
 	}
 
-	res, err := h.client.CreateOrder(context.Background(), grpcReq)
-	if err != nil {
-		st, _ := status.FromError(err)
-		c.JSON(convertGRPCStatusToHTTP(st.Code()), gin.H{"error": st.Message()})
-		return
+	// Snapshot the selected addresses from the user's address book
+	if req.ShippingAddressID != "" {
+		addr, err := h.snapshotAddress(c.Request.Context(), req.UserID, req.ShippingAddressID)
+		if err != nil {
+			problem.FromGRPC(c, err)
+			return
+		}
+		grpcReq.ShippingAddress = addr
 	}
-
-	// Convert response items
-	responseItems := make([]gin.H, len(res.Items))
-	for i, item := range res.Items {
-		responseItems[i] = gin.H{
-			"product_id": item.ProductId,
-			"quantity":   item.Quantity,
-			"price":      item.Price,
+	if req.BillingAddressID != "" {
+		addr, err := h.snapshotAddress(c.Request.Context(), req.UserID, req.BillingAddressID)
+		if err != nil {
+			problem.FromGRPC(c, err)
+			return
 		}
+		grpcReq.BillingAddress = addr
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"id":         res.Id,
-		"user_id":    res.UserId,
-		"items":      responseItems,
-		"total":      res.Total,
-		"status":     res.Status,
-		"created_at": res.CreatedAt,
-	})
-}
-
-func (h *OrderHandler) GetOrder(c *gin.Context) {
-	id := c.Param("id")
-
-	res, err := h.client.GetOrder(context.Background(), &orderpb.GetOrderRequest{Id: id})
+	res, err := h.client.CreateOrder(c.Request.Context(), grpcReq)
 	if err != nil {
-		st, _ := status.FromError(err)
-		c.JSON(convertGRPCStatusToHTTP(st.Code()), gin.H{"error": st.Message()})
+		problem.FromGRPC(c, err)
 		return
 	}
 
-	// Convert response items
-	items := make([]gin.H, len(res.Items))
-	for i, item := range res.Items {
-		items[i] = gin.H{
-			"product_id": item.ProductId,
-			"quantity":   item.Quantity,
-			"price":      item.Price,
-		}
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"id":         res.Id,
-		"user_id":    res.UserId,
-		"items":      items,
-		"total":      res.Total,
-		"status":     res.Status,
-		"created_at": res.CreatedAt,
-	})
+	transcode.Write(c, http.StatusCreated, res)
 }
 
-func (h *OrderHandler) GetUserOrders(c *gin.Context) {
-	userID := c.Param("id")
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
-
-	res, err := h.client.GetUserOrders(context.Background(), &orderpb.GetUserOrdersRequest{
+// snapshotAddress loads an address owned by the user and converts it into the
+// order's address snapshot.
+func (h *OrderHandler) snapshotAddress(ctx context.Context, userID, addressID string) (*orderpb.Address, error) {
+	a, err := h.userClient.GetAddress(ctx, &userpb.GetAddressRequest{
 		UserId: userID,
-		Limit:  int32(limit),
-		Offset: int32(offset),
+		Id:     addressID,
 	})
 	if err != nil {
-		st, _ := status.FromError(err)
-		c.JSON(convertGRPCStatusToHTTP(st.Code()), gin.H{"error": st.Message()})
-		return
+		return nil, err
 	}
 
-	orders := make([]gin.H, len(res.Orders))
-	for i, order := range res.Orders {
-		items := make([]gin.H, len(order.Items))
-		for j, item := range order.Items {
-			items[j] = gin.H{
-				"product_id": item.ProductId,
-				"quantity":   item.Quantity,
-				"price":      item.Price,
-			}
-		}
+	return &orderpb.Address{
+		Recipient:  a.Recipient,
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		State:      a.State,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+		Phone:      a.Phone,
+	}, nil
+}
 
-		orders[i] = gin.H{
-			"id":         order.Id,
-			"user_id":    order.UserId,
-			"items":      items,
-			"total":      order.Total,
-			"status":     order.Status,
-			"created_at": order.CreatedAt,
-		}
+// RequireOwner lets a request for the order in the id path parameter
+// through only if it was placed by the signed in user. Orders of other
+// users are answered as not found, like orders that do not exist.
+func (h *OrderHandler) RequireOwner(c *gin.Context) {
+	userID := c.GetString(middleware.UserIDKey)
+	if userID == "" {
+		c.Header("WWW-Authenticate", "Bearer")
+		problem.Abort(c, http.StatusUnauthorized, apierror.ReasonUnauthenticated, "authentication required")
+		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"orders": orders,
-		"total":  res.Total,
-		"limit":  limit,
-		"offset": offset,
-	})
+	order, err := h.client.GetOrder(c.Request.Context(), &orderpb.GetOrderRequest{Id: c.Param("id")})
+	if err != nil {
+		problem.FromGRPC(c, err)
+		return
+	}
+	if order.UserId != userID {
+		problem.Abort(c, http.StatusNotFound, apierror.ReasonOrderNotFound, "order not found")
+		return
+	}
+	c.Next()
 }
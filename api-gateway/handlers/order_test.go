@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	orderpb "github.com/best-microservice/common/protos/order"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/best-microservice/api-gateway/middleware"
+)
+
+// fakeOrders holds orders by ID and records the orders placed.
+type fakeOrders struct {
+	orderpb.OrderServiceClient
+	orders  map[string]*orderpb.OrderResponse
+	created *orderpb.CreateOrderRequest
+}
+
+func (f *fakeOrders) GetOrder(_ context.Context, req *orderpb.GetOrderRequest, _ ...grpc.CallOption) (*orderpb.OrderResponse, error) {
+	o, ok := f.orders[req.Id]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "order not found")
+	}
+	return o, nil
+}
+
+func (f *fakeOrders) CreateOrder(_ context.Context, req *orderpb.CreateOrderRequest, _ ...grpc.CallOption) (*orderpb.OrderResponse, error) {
+	f.created = req
+	return &orderpb.OrderResponse{Id: "o2", UserId: req.UserId}, nil
+}
+
+func TestRequireOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		auth       string
+		order      string
+		wantStatus int
+	}{
+		{"own order", "Bearer valid", "o1", http.StatusNoContent},
+		{"order of another user", "Bearer valid", "o9", http.StatusNotFound},
+		{"unknown order", "Bearer valid", "nope", http.StatusNotFound},
+		{"anonymous", "", "o1", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &OrderHandler{client: &fakeOrders{orders: map[string]*orderpb.OrderResponse{
+				"o1": {Id: "o1", UserId: "u1"},
+				"o9": {Id: "o9", UserId: "u9"},
+			}}}
+			engine := gin.New()
+			engine.GET("/orders/:id", middleware.Authenticate(validSession), h.RequireOwner, func(c *gin.Context) {
+				c.Status(http.StatusNoContent)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/orders/"+tt.order, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestCreateOrderForSessionUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		auth       string
+		userID     string
+		wantStatus int
+	}{
+		{"own order", "Bearer valid", "u1", http.StatusCreated},
+		{"for another user", "Bearer valid", "u2", http.StatusForbidden},
+		{"anonymous", "", "u1", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders := &fakeOrders{}
+			h := &OrderHandler{client: orders}
+			engine := gin.New()
+			engine.POST("/orders", middleware.Authenticate(validSession), h.CreateOrder)
+
+			body := `{"user_id":"` + tt.userID + `","items":[{"product_id":"p1","quantity":1}]}`
+			req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if placed := orders.created != nil; placed != (tt.wantStatus == http.StatusCreated) {
+				t.Errorf("order placed: %v", placed)
+			}
+		})
+	}
+}
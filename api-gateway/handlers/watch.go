@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/best-microservice/common/apierror"
+	"github.com/best-microservice/common/logging"
+	orderpb "github.com/best-microservice/common/protos/order"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/best-microservice/api-gateway/middleware"
+	"github.com/best-microservice/api-gateway/problem"
+	"github.com/best-microservice/api-gateway/transcode"
+)
+
+// Watches push the status changes of an order, streamed by the order
+// service's WatchOrder, to the user who placed it. Each event carries its ID;
+// clients that reconnect pass the last one they saw, as the Last-Event-ID
+// header or the last_event_id query parameter, and receive only the events
+// after it. The stream ends once the order is delivered or cancelled.
+
+// LastEventIDHeader is sent by EventSource clients when they reconnect.
+const LastEventIDHeader = "Last-Event-ID"
+
+// watchEvent is an event of a watch stream: status for a status change, end
+// once the order is finished and error when the watch failed.
+type watchEvent struct {
+	Event string          `json:"event"`
+	ID    int64           `json:"id,omitempty"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// WatchOrderEvents streams the status changes of an order as Server-Sent
+// Events. Comments are sent as heartbeats so proxies keep idle streams open.
+func (h *OrderHandler) WatchOrderEvents(c *gin.Context) {
+	events, ok := h.watch(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	// Keeps nginx from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, open := <-events:
+			if !open {
+				return
+			}
+			if e.ID > 0 {
+				fmt.Fprintf(c.Writer, "id: %d\n", e.ID)
+			}
+			fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", e.Event, e.Data)
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+// upgrader accepts WebSocket connections from pages of the API's own
+// origin only, so other sites cannot use a visitor's cookies.
+var upgrader = websocket.Upgrader{}
+
+// WatchOrderSocket streams the status changes of an order over a WebSocket,
+// as JSON text messages holding the event name and data. Pings are sent as
+// heartbeats; a client that stops answering them is disconnected.
+func (h *OrderHandler) WatchOrderSocket(c *gin.Context) {
+	if !websocket.IsWebSocketUpgrade(c.Request) {
+		problem.Abort(c, http.StatusBadRequest, apierror.ReasonMalformedRequest, "a WebSocket upgrade is required")
+		return
+	}
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	c.Request = c.Request.WithContext(ctx)
+
+	events, ok := h.watch(c)
+	if !ok {
+		return
+	}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has answered the request
+		return
+	}
+	defer conn.Close()
+
+	// Clients send nothing but control frames; reading processes them and
+	// notices when the client is gone.
+	conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+	})
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, open := <-events:
+			if !open {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(h.heartbeat))
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+			switch e.Event {
+			case "end":
+				closeSocket(conn, websocket.CloseNormalClosure, "order finished", h.heartbeat)
+				return
+			case "error":
+				closeSocket(conn, websocket.CloseInternalServerErr, "watch failed", h.heartbeat)
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.heartbeat)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func closeSocket(conn *websocket.Conn, code int, reason string, timeout time.Duration) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(timeout))
+}
+
+// watch starts watching the order of the request for its user. Until the
+// order service accepts the watch, failures are answered with a problem
+// and ok is false; later ones become an error event. The returned channel
+// is closed after the last event, or when the request is done.
+func (h *OrderHandler) watch(c *gin.Context) (events <-chan watchEvent, ok bool) {
+	userID := c.GetString(middleware.UserIDKey)
+	if userID == "" {
+		problem.Abort(c, http.StatusUnauthorized, apierror.ReasonUnauthenticated, "authentication required")
+		return nil, false
+	}
+
+	lastEventID := c.GetHeader(LastEventIDHeader)
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var afterID int64
+	if lastEventID != "" {
+		var err error
+		afterID, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || afterID < 0 {
+			p := problem.New(http.StatusBadRequest, apierror.ReasonInvalidArgument, "invalid last event ID")
+			p.Errors = []problem.FieldError{{Field: "last_event_id", Message: "must be a non-negative integer"}}
+			problem.Write(c, p)
+			return nil, false
+		}
+	}
+
+	ctx := c.Request.Context()
+	stream, err := h.client.WatchOrder(ctx, &orderpb.WatchOrderRequest{
+		OrderId:      c.Param("id"),
+		UserId:       userID,
+		AfterEventId: afterID,
+	})
+	if err != nil {
+		problem.FromGRPC(c, err)
+		return nil, false
+	}
+	// The service sends headers once it has accepted the watch; a watch it
+	// refused ends without any, and Recv returns the error.
+	if md, _ := stream.Header(); md == nil {
+		_, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			err = status.Error(codes.Internal, "watch ended before it started")
+		}
+		problem.FromGRPC(c, err)
+		return nil, false
+	}
+
+	// The gin context is reused once the handler returns, before the
+	// goroutine may have finished
+	instance, requestID := c.Request.URL.Path, logging.RequestID(ctx)
+	fail := func(err error) watchEvent {
+		p := problem.Convert(err)
+		p.Instance, p.RequestID = instance, requestID
+		data, _ := json.Marshal(p)
+		return watchEvent{Event: "error", Data: data}
+	}
+
+	out := make(chan watchEvent)
+	go func() {
+		defer close(out)
+		send := func(e watchEvent) bool {
+			select {
+			case out <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for {
+			msg, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				send(watchEvent{Event: "end", Data: json.RawMessage("{}")})
+				return
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					send(fail(err))
+				}
+				return
+			}
+			data, err := transcode.Marshal(msg)
+			if err != nil {
+				send(fail(status.Error(codes.Internal, err.Error())))
+				return
+			}
+			if !send(watchEvent{Event: "status", ID: msg.Id, Data: data}) {
+				return
+			}
+		}
+	}()
+	return out, true
+}
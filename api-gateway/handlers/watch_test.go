@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	orderpb "github.com/best-microservice/common/protos/order"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/best-microservice/api-gateway/middleware"
+)
+
+// fakeWatches answers WatchOrder with a stream of the given events, or, if
+// refused is set, with a stream the service ended without headers.
+type fakeWatches struct {
+	orderpb.OrderServiceClient
+	events  []*orderpb.OrderStatusEvent
+	refused error
+	req     *orderpb.WatchOrderRequest
+}
+
+func (f *fakeWatches) WatchOrder(_ context.Context, req *orderpb.WatchOrderRequest, _ ...grpc.CallOption) (grpc.ServerStreamingClient[orderpb.OrderStatusEvent], error) {
+	f.req = req
+	return &fakeWatchStream{events: f.events, refused: f.refused}, nil
+}
+
+type fakeWatchStream struct {
+	grpc.ClientStream
+	events  []*orderpb.OrderStatusEvent
+	refused error
+}
+
+func (s *fakeWatchStream) Header() (metadata.MD, error) {
+	if s.refused != nil {
+		return nil, s.refused
+	}
+	return metadata.MD{}, nil
+}
+
+func (s *fakeWatchStream) Recv() (*orderpb.OrderStatusEvent, error) {
+	if s.refused != nil {
+		return nil, s.refused
+	}
+	if len(s.events) == 0 {
+		return nil, io.EOF
+	}
+	e := s.events[0]
+	s.events = s.events[1:]
+	return e, nil
+}
+
+// validSession accepts the token "valid" as a session of user u1.
+func validSession(_ context.Context, token string) (string, error) {
+	if token != "valid" {
+		return "", status.Error(codes.Unauthenticated, "invalid session")
+	}
+	return "u1", nil
+}
+
+func TestWatchOrderEvents(t *testing.T) {
+	events := []*orderpb.OrderStatusEvent{
+		{Id: 3, OrderId: "o1", Status: "paid", PreviousStatus: "pending"},
+		{Id: 4, OrderId: "o1", Status: "shipped", PreviousStatus: "paid"},
+	}
+	tests := []struct {
+		name        string
+		auth        string
+		lastEventID string
+		refused     error
+		wantStatus  int
+		wantBody    []string
+		wantAfterID int64
+	}{
+		{
+			name:       "status events then end",
+			auth:       "Bearer valid",
+			wantStatus: http.StatusOK,
+			wantBody: []string{
+				"id: 3\nevent: status\ndata: ", `"status":"paid"`,
+				"id: 4\nevent: status\ndata: ", `"status":"shipped"`,
+				"event: end\ndata: {}\n\n",
+			},
+		},
+		{
+			name:        "resumes after the last event",
+			auth:        "Bearer valid",
+			lastEventID: "2",
+			wantStatus:  http.StatusOK,
+			wantBody:    []string{"event: end"},
+			wantAfterID: 2,
+		},
+		{name: "anonymous", wantStatus: http.StatusUnauthorized},
+		{name: "expired session", auth: "Bearer expired", wantStatus: http.StatusUnauthorized},
+		{
+			name:       "order of another user",
+			auth:       "Bearer valid",
+			refused:    status.Error(codes.NotFound, "order not found"),
+			wantStatus: http.StatusNotFound,
+		},
+		{name: "invalid last event ID", auth: "Bearer valid", lastEventID: "-1", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			client := &fakeWatches{events: events, refused: tt.refused}
+			h := &OrderHandler{client: client, heartbeat: time.Minute}
+			router := gin.New()
+			router.GET("/orders/:id/events", middleware.Authenticate(validSession), h.WatchOrderEvents)
+
+			req := httptest.NewRequest(http.MethodGet, "/orders/o1/events", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			if tt.lastEventID != "" {
+				req.Header.Set(LastEventIDHeader, tt.lastEventID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("Content-Type %q, want text/event-stream", ct)
+			}
+			body := w.Body.String()
+			rest := body
+			for _, want := range tt.wantBody {
+				i := strings.Index(rest, want)
+				if i < 0 {
+					t.Fatalf("body does not contain %q in order:\n%s", want, body)
+				}
+				rest = rest[i+len(want):]
+			}
+			if client.req.UserId != "u1" || client.req.OrderId != "o1" || client.req.AfterEventId != tt.wantAfterID {
+				t.Errorf("WatchOrder(%v), want user u1, order o1, after %d", client.req, tt.wantAfterID)
+			}
+		})
+	}
+}
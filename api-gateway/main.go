@@ -2,82 +2,214 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"time"
 
+	"github.com/best-microservice/common/apierror"
+	"github.com/best-microservice/common/events"
+	"github.com/best-microservice/common/logging"
+	"github.com/best-microservice/common/metrics"
+	"github.com/best-microservice/common/mtls"
+	eventspb "github.com/best-microservice/common/protos/events"
+	userpb "github.com/best-microservice/common/protos/user"
+	"github.com/best-microservice/common/tracing"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/best-microservice/api-gateway/gql"
 	"github.com/best-microservice/api-gateway/handlers"
+	"github.com/best-microservice/api-gateway/middleware"
+	"github.com/best-microservice/api-gateway/problem"
+	"github.com/best-microservice/api-gateway/resilience"
 )
 
 func main() {
+	// Configuration: defaults, YAML file, environment, flags
+	cfg, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
 
-	if err := godotenv.Load("../.env"); err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+	// Structured logging
+	logging.Setup("api-gateway", cfg.Log.Level, cfg.Log.Format)
+
+	// Tracing
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
 	}
-	// Initialize Gin router
-	router := gin.Default()
+	defer shutdownTracing(context.Background())
 
-	// Setup gRPC connections
-	userConn, err := grpc.Dial(os.Getenv("USER_SERVICE_ADDR"),
-		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	// Initialize Gin router; errors, including panics and unknown routes,
+	// are answered with problem+json
+	problem.UseJSONFieldNames()
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("invalid trusted proxies: %v", err)
+	}
+	router.Use(gin.CustomRecovery(func(c *gin.Context, _ any) {
+		problem.Abort(c, http.StatusInternalServerError, apierror.ReasonInternal, "internal error")
+	}), middleware.RequestID(), middleware.Logger(), otelgin.Middleware("api-gateway"), middleware.Metrics())
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(func(c *gin.Context) {
+		problem.Abort(c, http.StatusNotFound, apierror.ReasonRouteNotFound, "no route matches "+c.Request.URL.Path)
+	})
+	router.NoMethod(func(c *gin.Context) {
+		problem.Abort(c, http.StatusMethodNotAllowed, apierror.ReasonRouteNotFound, c.Request.Method+" is not allowed on "+c.Request.URL.Path)
+	})
+
+	// TLS client certificate identifying the gateway to the services
+	creds, err := mtls.New(cfg.TLS)
+	if err != nil {
+		log.Fatalf("failed to load TLS certificates: %v", err)
+	}
+	credsCtx, stopCreds := context.WithCancel(context.Background())
+	defer stopCreds()
+	go creds.Run(credsCtx)
+
+	// Setup gRPC connections; calls carry the trace context and request ID
+	dialOpts := []grpc.DialOption{
+		tracing.DialOption(),
+		grpc.WithChainUnaryInterceptor(logging.UnaryClientInterceptor(), metrics.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(logging.StreamClientInterceptor()),
+	}
+	// Deadlines, retries and circuit breaking come first in each chain, so
+	// every attempt is logged and measured on its own.
+	dial := func(addr, backend string, policy resilience.Policy) (*grpc.ClientConn, error) {
+		opts := append([]grpc.DialOption{
+			grpc.WithChainUnaryInterceptor(resilience.UnaryClientInterceptor(backend, policy)),
+			creds.DialOption(backend),
+		}, dialOpts...)
+		return grpc.Dial(addr, opts...)
+	}
+	userConn, err := dial(cfg.UserServiceAddr, "user-service", cfg.Backends.User)
 	if err != nil {
 		log.Fatalf("did not connect to user service: %v", err)
 	}
 	defer userConn.Close()
 
-	productConn, err := grpc.Dial(os.Getenv("PRODUCT_SERVICE_ADDR"),
-		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	productConn, err := dial(cfg.ProductServiceAddr, "product-service", cfg.Backends.Product)
 	if err != nil {
 		log.Fatalf("did not connect to product service: %v", err)
 	}
 	defer productConn.Close()
 
-	orderConn, err := grpc.Dial(os.Getenv("ORDER_SERVICE_ADDR"),
-		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	orderConn, err := dial(cfg.OrderServiceAddr, "order-service", cfg.Backends.Order)
 	if err != nil {
 		log.Fatalf("did not connect to order service: %v", err)
 	}
 	defer orderConn.Close()
 
-	// Initialize handlers
-	userHandler := handlers.NewUserHandler(userConn)
-	productHandler := handlers.NewProductHandler(productConn)
-	orderHandler := handlers.NewOrderHandler(orderConn)
+	// Hand-written handlers; everything else is transcoded from the protos
+	orderHandler := handlers.NewOrderHandler(orderConn, userConn, cfg.WatchHeartbeat)
+
+	// Idempotency keys for POST requests, auth excluded
+	idempotencyStore := middleware.NewMemoryIdempotencyStore()
+	stopCleanup := make(chan struct{})
+	defer close(stopCleanup)
+	go idempotencyStore.Cleanup(time.Minute, stopCleanup)
+
+	// Session tokens issued at login identify the user of a request
+	authenticate := middleware.Authenticate(middleware.ValidateSessions(userpb.NewUserServiceClient(userConn)))
+
+	// Per client rate limits, tighter on requests that check a password or
+	// token, or send email
+	apiLimit, authLimit := rateLimiters(cfg.RateLimit, authenticate, stopCleanup)
 
 	// Routes
-	api := router.Group("/api/v1")
-	{
-		// User routes
-		api.POST("/users", userHandler.CreateUser)
-		api.GET("/users/:id", userHandler.GetUser)
-		api.POST("/auth", userHandler.Authenticate)
-
-		// Product routes
-		api.POST("/products", productHandler.CreateProduct)
-		api.GET("/products/:id", productHandler.GetProduct)
-		api.GET("/products", productHandler.ListProducts)
-
-		// Order routes
-		api.POST("/orders", orderHandler.CreateOrder)
-		api.GET("/orders/:id", orderHandler.GetOrder)
-		api.GET("/users/:id/orders", orderHandler.GetUserOrders)
-	}
-
-	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+	api := router.Group(apiBasePath, apiLimit...)
+	api.Use(middleware.Timeout(cfg.RequestTimeout, cfg.RouteTimeouts, streamingRoutes...))
+	api.Use(middleware.Idempotency(idempotencyStore, cfg.IdempotencyTTL, apiBasePath+"/auth"))
+	methodMiddleware := map[string][]gin.HandlerFunc{
+		userpb.UserService_AuthenticateUser_FullMethodName:        authLimit,
+		userpb.UserService_VerifyEmail_FullMethodName:             authLimit,
+		userpb.UserService_ResendVerificationEmail_FullMethodName: authLimit,
+		userpb.UserService_RequestPasswordReset_FullMethodName:    authLimit,
+		userpb.UserService_ResetPassword_FullMethodName:           authLimit,
+		userpb.UserService_ChangePassword_FullMethodName:          authLimit,
+		userpb.UserService_CompleteMfaLogin_FullMethodName:        authLimit,
+		userpb.UserService_EnrollTotp_FullMethodName:              authLimit,
+		userpb.UserService_ConfirmTotp_FullMethodName:             authLimit,
+		userpb.UserService_DisableTotp_FullMethodName:             authLimit,
+	}
+	if cfg.Cache.Enabled {
+		catalogCache := middleware.NewResponseCache(cfg.Cache.MaxEntries, cfg.Cache.TTL)
+		for _, method := range cachedMethods {
+			methodMiddleware[method] = []gin.HandlerFunc{middleware.CacheResponses(catalogCache, catalogTags, catalogLastModified)}
+		}
+
+		// The product service delivers its events here like to any other
+		// subscriber; they drop the cached responses they make stale
+		broker := events.NewBroker()
+		broker.Subscribe("*", invalidateCatalog(catalogCache))
+		eventServer := grpc.NewServer(
+			creds.ServerOption(),
+			tracing.ServerOption(),
+			grpc.ChainUnaryInterceptor(logging.UnaryServerInterceptor(), creds.UnaryServerInterceptor()),
+		)
+		eventspb.RegisterEventServiceServer(eventServer, events.NewServer(broker, nil))
+		lis, err := net.Listen("tcp", cfg.EventsAddr)
+		if err != nil {
+			log.Fatalf("failed to listen for events: %v", err)
+		}
+		go func() {
+			if err := eventServer.Serve(lis); err != nil {
+				log.Fatalf("failed to serve events: %v", err)
+			}
+		}()
+		defer eventServer.GracefulStop()
+	}
+	err = registerAPIRoutes(api, backends{user: userConn, product: productConn, order: orderConn}, orderHandler, methodMiddleware, authenticate)
+	if err != nil {
+		log.Fatalf("failed to register API routes: %v", err)
+	}
+
+	// GraphQL, for clients that want related resources in one round-trip
+	graphQL, err := gql.New(userConn, productConn, orderConn, cfg.GraphQL)
+	if err != nil {
+		log.Fatalf("failed to build GraphQL schema: %v", err)
+	}
+	graphQLRoutes := router.Group("/graphql", apiLimit...)
+	graphQLRoutes.Use(middleware.Timeout(cfg.RequestTimeout, cfg.RouteTimeouts), authenticate)
+	graphQLRoutes.GET("", graphQL.Serve)
+	graphQLRoutes.POST("", graphQL.Serve)
+
+	// API documentation
+	doc, err := apiDocument()
+	if err != nil {
+		log.Fatalf("failed to generate OpenAPI document: %v", err)
+	}
+	registerDocs(router, doc)
+
+	// Health checks: liveness of the gateway itself, readiness of the backends
+	healthHandler := handlers.NewHealthHandler(cfg.ReadinessTimeout,
+		handlers.Dependency{Name: "user-service", Service: "user.UserService", Conn: userConn},
+		handlers.Dependency{Name: "product-service", Service: "product.ProductService", Conn: productConn},
+		handlers.Dependency{Name: "order-service", Service: "order.OrderService", Conn: orderConn},
+	)
+	router.GET("/livez", healthHandler.Livez)
+	router.GET("/readyz", healthHandler.Readyz)
+	// Kept for existing probes; equivalent to /livez
+	router.GET("/health", healthHandler.Livez)
+
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	// Start server
 	srv := &http.Server{
-		Addr:    ":8080",
+		Addr:    cfg.ListenAddr,
 		Handler: router,
 	}
 
@@ -91,12 +223,48 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
-	log.Println("Shutting down server...")
+	slog.Info("shutting down api gateway")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal("Server forced to shutdown:", err)
 	}
-	log.Println("Server exiting")
+	slog.Info("api gateway stopped")
+}
+
+// rateLimiters returns the rate limiting middleware of the API and of the
+// auth route, or none when rate limiting is disabled. Keyed by user, the API
+// middleware authenticates requests before counting them; anonymous ones are
+// counted per IP.
+func rateLimiters(cfg RateLimitConfig, authenticate gin.HandlerFunc, stop <-chan struct{}) (api, auth []gin.HandlerFunc) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var store middleware.RateLimitStore
+	switch cfg.Store {
+	case "redis":
+		client := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr, Password: cfg.RedisPassword, DB: cfg.RedisDB})
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			slog.Warn("rate limit store unavailable, requests are not limited until it is back", "addr", cfg.RedisAddr, "error", err)
+		}
+		store = middleware.NewRedisRateLimitStore(client)
+	default:
+		memory := middleware.NewMemoryRateLimitStore()
+		go memory.Cleanup(time.Minute, stop)
+		store = memory
+	}
+
+	key := middleware.KeyByIP
+	switch cfg.KeyBy {
+	case "api_key":
+		key = middleware.KeyByAPIKey(cfg.APIKeys)
+	case "user":
+		key = middleware.KeyByUser(middleware.KeyByIP)
+		api = append(api, authenticate)
+	}
+
+	return append(api, middleware.RateLimit(store, "api", cfg.Default, key)),
+		[]gin.HandlerFunc{middleware.RateLimit(store, "auth", cfg.Auth, key)}
 }
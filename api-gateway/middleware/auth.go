@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/best-microservice/api-gateway/problem"
+	"github.com/best-microservice/common/apierror"
+	userpb "github.com/best-microservice/common/protos/user"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SessionValidator resolves a session token to the ID of its user. Tokens
+// that are not valid fail with a gRPC Unauthenticated status.
+type SessionValidator func(ctx context.Context, token string) (userID string, err error)
+
+// ValidateSessions asks the user service, which issued the token at login.
+func ValidateSessions(client userpb.UserServiceClient) SessionValidator {
+	return func(ctx context.Context, token string) (string, error) {
+		res, err := client.ValidateSession(ctx, &userpb.ValidateSessionRequest{Token: token})
+		if err != nil {
+			return "", err
+		}
+		return res.UserId, nil
+	}
+}
+
+// Authenticate stores the user of the session token sent as
+// "Authorization: Bearer <token>" under UserIDKey. Requests without one pass
+// anonymously, for the handlers that need a user to refuse; a token that is
+// malformed or not valid is answered with 401. A request already
+// authenticated, e.g. for the rate limiter, is not validated again.
+func Authenticate(validate SessionValidator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := c.GetHeader("Authorization")
+		if _, done := c.Get(UserIDKey); done || auth == "" {
+			c.Next()
+			return
+		}
+		scheme, token, _ := strings.Cut(auth, " ")
+		token = strings.TrimSpace(token)
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			c.Header("WWW-Authenticate", "Bearer")
+			problem.Abort(c, http.StatusUnauthorized, apierror.ReasonUnauthenticated, "the Authorization header must hold a Bearer session token")
+			return
+		}
+
+		userID, err := validate(c.Request.Context(), token)
+		if err != nil {
+			if status.Code(err) == codes.Unauthenticated {
+				c.Header("WWW-Authenticate", "Bearer")
+			}
+			problem.FromGRPC(c, err)
+			return
+		}
+		c.Set(UserIDKey, userID)
+		c.Next()
+	}
+}
+
+// RequireUser lets a request through only if Authenticate found a session
+// of the user named by the path parameter param, so users can act only on
+// their own resources.
+func RequireUser(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString(UserIDKey)
+		if userID == "" {
+			c.Header("WWW-Authenticate", "Bearer")
+			problem.Abort(c, http.StatusUnauthorized, apierror.ReasonUnauthenticated, "authentication required")
+			return
+		}
+		if userID != c.Param(param) {
+			problem.Abort(c, http.StatusForbidden, apierror.ReasonPermissionDenied, "the session belongs to another user")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validate := func(_ context.Context, token string) (string, error) {
+		switch token {
+		case "valid":
+			return "u1", nil
+		case "down":
+			return "", status.Error(codes.Unavailable, "connection refused")
+		}
+		return "", status.Error(codes.Unauthenticated, "invalid session")
+	}
+	tests := []struct {
+		name          string
+		auth          string
+		wantStatus    int
+		wantUser      string
+		wantChallenge bool
+	}{
+		{"valid session", "Bearer valid", http.StatusNoContent, "u1", false},
+		{"scheme is case insensitive", "bearer valid", http.StatusNoContent, "u1", false},
+		{"anonymous", "", http.StatusNoContent, "", false},
+		{"expired session", "Bearer expired", http.StatusUnauthorized, "", true},
+		{"other scheme", "Basic dTE6cGFzc3dvcmQ=", http.StatusUnauthorized, "", true},
+		{"no token", "Bearer ", http.StatusUnauthorized, "", true},
+		{"user service down", "Bearer down", http.StatusServiceUnavailable, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			var user string
+			engine.GET("/", Authenticate(validate), func(c *gin.Context) {
+				user = c.GetString(UserIDKey)
+				c.Status(http.StatusNoContent)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if user != tt.wantUser {
+				t.Errorf("user %q, want %q", user, tt.wantUser)
+			}
+			if got := w.Header().Get("WWW-Authenticate") != ""; got != tt.wantChallenge {
+				t.Errorf("WWW-Authenticate sent: %v, want %v", got, tt.wantChallenge)
+			}
+		})
+	}
+}
+
+func TestRequireUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		session    string
+		path       string
+		wantStatus int
+	}{
+		{"own resource", "u1", "/users/u1/password", http.StatusNoContent},
+		{"another user's resource", "u1", "/users/u2/password", http.StatusForbidden},
+		{"anonymous", "", "/users/u1/password", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			session := func(c *gin.Context) {
+				if tt.session != "" {
+					c.Set(UserIDKey, tt.session)
+				}
+			}
+			engine.PUT("/users/:user_id/password", session, RequireUser("user_id"), func(c *gin.Context) {
+				c.Status(http.StatusNoContent)
+			})
+
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodPut, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
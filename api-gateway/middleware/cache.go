@@ -0,0 +1,253 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/best-microservice/common/metrics"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Name:      "cache_requests_total",
+	Help:      "Cacheable requests handled by the gateway, by route and whether they were served from the cache.",
+}, []string{"route", "result"})
+
+// CachedResponse is a successful response kept by a ResponseCache.
+type CachedResponse struct {
+	ContentType string
+	Body        []byte
+	ETag        string
+	// LastModified is when the resources the response shows last changed,
+	// or zero if that is not known.
+	LastModified time.Time
+	// Tags name the resources the response shows, see Invalidate.
+	Tags []string
+}
+
+type cacheEntry struct {
+	key       string
+	response  *CachedResponse
+	expiresAt time.Time
+}
+
+// ResponseCache keeps up to a maximum number of responses in memory, each
+// for at most ttl, dropping the least recently used first. The ttl bounds
+// how long a response may be served after the resources it shows changed
+// when the change was not invalidated, e.g. because the event was missed.
+type ResponseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	// recent holds the entries, most recently used first.
+	recent  *list.List
+	entries map[string]*list.Element
+	tagged  map[string]map[*list.Element]struct{}
+}
+
+func NewResponseCache(maxEntries int, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		recent:     list.New(),
+		entries:    make(map[string]*list.Element),
+		tagged:     make(map[string]map[*list.Element]struct{}),
+	}
+}
+
+// Get returns the response stored for key unless it expired.
+func (rc *ResponseCache) Get(key string) (*CachedResponse, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	el, ok := rc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		rc.remove(el)
+		return nil, false
+	}
+	rc.recent.MoveToFront(el)
+	return entry.response, true
+}
+
+// Set stores res for key, replacing any response stored before.
+func (rc *ResponseCache) Set(key string, res *CachedResponse) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if el, ok := rc.entries[key]; ok {
+		rc.remove(el)
+	}
+	el := rc.recent.PushFront(&cacheEntry{key: key, response: res, expiresAt: time.Now().Add(rc.ttl)})
+	rc.entries[key] = el
+	for _, tag := range res.Tags {
+		if rc.tagged[tag] == nil {
+			rc.tagged[tag] = make(map[*list.Element]struct{})
+		}
+		rc.tagged[tag][el] = struct{}{}
+	}
+	for rc.recent.Len() > rc.maxEntries {
+		rc.remove(rc.recent.Back())
+	}
+}
+
+// Invalidate drops the responses carrying any of tags and returns how many
+// were dropped.
+func (rc *ResponseCache) Invalidate(tags ...string) int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	n := 0
+	for _, tag := range tags {
+		for el := range rc.tagged[tag] {
+			rc.remove(el)
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of stored responses, including expired ones not
+// looked up since.
+func (rc *ResponseCache) Len() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.recent.Len()
+}
+
+func (rc *ResponseCache) remove(el *list.Element) {
+	entry := el.Value.(*cacheEntry)
+	rc.recent.Remove(el)
+	delete(rc.entries, entry.key)
+	for _, tag := range entry.response.Tags {
+		delete(rc.tagged[tag], el)
+		if len(rc.tagged[tag]) == 0 {
+			delete(rc.tagged, tag)
+		}
+	}
+}
+
+// CacheResponses serves GET requests from cache, keyed by path and query
+// string; tags names the resources a request's response shows. Only 200
+// responses are stored. Responses carry an ETag, and a Last-Modified when
+// lastModified finds in the body when its resources last changed; requests
+// whose If-None-Match or If-Modified-Since matches get 304 Not Modified.
+// Clients are asked to revalidate on every use, so they never hold a
+// response longer than the gateway does.
+func CacheResponses(cache *ResponseCache, tags func(c *gin.Context) []string, lastModified func(body []byte) time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		key := c.Request.URL.Path + "?" + c.Request.URL.Query().Encode()
+
+		if res, ok := cache.Get(key); ok {
+			cacheRequests.WithLabelValues(c.FullPath(), "hit").Inc()
+			c.Header("X-Cache", "HIT")
+			writeCached(c, res)
+			c.Abort()
+			return
+		}
+		cacheRequests.WithLabelValues(c.FullPath(), "miss").Inc()
+
+		// The validators depend on the whole body, so it is held back until
+		// the handler is done
+		w := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.status != http.StatusOK {
+			c.Writer.WriteHeader(w.status)
+			c.Writer.Write(w.body.Bytes())
+			return
+		}
+		sum := sha256.Sum256(w.body.Bytes())
+		res := &CachedResponse{
+			ContentType:  c.Writer.Header().Get("Content-Type"),
+			Body:         w.body.Bytes(),
+			ETag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+			LastModified: lastModified(w.body.Bytes()).UTC().Truncate(time.Second),
+			Tags:         tags(c),
+		}
+		cache.Set(key, res)
+		c.Header("X-Cache", "MISS")
+		writeCached(c, res)
+	}
+}
+
+func writeCached(c *gin.Context, res *CachedResponse) {
+	c.Header("ETag", res.ETag)
+	if !res.LastModified.IsZero() {
+		c.Header("Last-Modified", res.LastModified.Format(http.TimeFormat))
+	}
+	c.Header("Cache-Control", "no-cache")
+	if notModified(c.Request, res) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Data(http.StatusOK, res.ContentType, res.Body)
+}
+
+// notModified evaluates the conditional headers of r against res. As in RFC
+// 9110, If-Modified-Since is ignored when If-None-Match is present, which
+// compares weakly.
+func notModified(r *http.Request, res *CachedResponse) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == res.ETag {
+				return true
+			}
+		}
+		return false
+	}
+	if res.LastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !res.LastModified.After(since)
+}
+
+// bufferedWriter holds back the status and body written by handlers.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int { return w.status }
+
+func (w *bufferedWriter) Size() int { return w.body.Len() }
+
+func (w *bufferedWriter) Written() bool { return w.body.Len() > 0 }
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCacheResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	calls := 0
+	updated := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	lastModified := func(body []byte) time.Time {
+		var res struct {
+			UpdatedAt time.Time `json:"updated_at"`
+		}
+		json.Unmarshal(body, &res)
+		return res.UpdatedAt
+	}
+	engine.GET("/products/:id", CacheResponses(NewResponseCache(10, time.Minute), func(*gin.Context) []string { return nil }, lastModified),
+		func(c *gin.Context) {
+			calls++
+			c.JSON(http.StatusOK, gin.H{"id": c.Param("id"), "updated_at": updated.Add(300 * time.Millisecond)})
+		})
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/products/p1", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("first request: status %d, ETag %q, X-Cache %q", first.Code, etag, first.Header().Get("X-Cache"))
+	}
+	if lm := first.Header().Get("Last-Modified"); lm != "Fri, 02 Jan 2026 03:04:05 GMT" {
+		t.Errorf("Last-Modified %q, want the updated_at of the product", lm)
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{"unconditional", "", "", http.StatusOK},
+		{"matching ETag", "If-None-Match", etag, http.StatusNotModified},
+		{"weak match", "If-None-Match", `"other", W/` + etag, http.StatusNotModified},
+		{"any", "If-None-Match", "*", http.StatusNotModified},
+		{"stale ETag", "If-None-Match", `"other"`, http.StatusOK},
+		{"modified since", "If-Modified-Since", updated.Add(-time.Second).Format(http.TimeFormat), http.StatusOK},
+		{"not modified since", "If-Modified-Since", updated.Format(http.TimeFormat), http.StatusNotModified},
+		{"not modified since later", "If-Modified-Since", updated.Add(time.Hour).Format(http.TimeFormat), http.StatusNotModified},
+		{"invalid date", "If-Modified-Since", "yesterday", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.header, tt.value)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Header().Get("X-Cache") != "HIT" || w.Header().Get("ETag") != etag {
+				t.Errorf("X-Cache %q, ETag %q; want a hit with %q", w.Header().Get("X-Cache"), w.Header().Get("ETag"), etag)
+			}
+			if tt.wantStatus == http.StatusNotModified && w.Body.Len() > 0 {
+				t.Errorf("304 with a body: %s", w.Body)
+			}
+		})
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want once", calls)
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since
+	req := httptest.NewRequest(http.MethodGet, "/products/p1", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	req.Header.Set("If-Modified-Since", updated.Format(http.TimeFormat))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("stale ETag with a current date: status %d, want 200", w.Code)
+	}
+}
+
+func TestCacheResponsesWithoutLastModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/products", CacheResponses(NewResponseCache(10, time.Minute),
+		func(*gin.Context) []string { return nil }, func([]byte) time.Time { return time.Time{} }),
+		func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"products": []string{}}) })
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.Header.Set("If-Modified-Since", time.Now().UTC().Format(http.TimeFormat))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Header().Get("Last-Modified") != "" {
+			t.Errorf("request %d: status %d, Last-Modified %q; want 200 without one", i, w.Code, w.Header().Get("Last-Modified"))
+		}
+	}
+}
@@ -0,0 +1,254 @@
+// Package middleware contains the gateway's Gin middleware.
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/best-microservice/api-gateway/problem"
+	"github.com/best-microservice/common/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader is the request header carrying the client's key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyState is the result of claiming an idempotency key.
+type IdempotencyState int
+
+const (
+	// IdempotencyNew means the key was unused and is now held for the caller.
+	IdempotencyNew IdempotencyState = iota
+	// IdempotencyInFlight means another request with the key is still running.
+	IdempotencyInFlight
+	// IdempotencyCompleted means a response was stored and must be replayed.
+	IdempotencyCompleted
+	// IdempotencyMismatch means the key was used for a different request.
+	IdempotencyMismatch
+)
+
+// StoredResponse is the first response produced for an idempotency key.
+type StoredResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// IdempotencyStore keeps idempotency records until they expire.
+type IdempotencyStore interface {
+	// Begin claims key for a request with the given fingerprint. When the
+	// state is IdempotencyCompleted the stored response is returned as well.
+	Begin(key, fingerprint string, ttl time.Duration) (IdempotencyState, *StoredResponse)
+	// Complete stores the response of the request holding key.
+	Complete(key string, res *StoredResponse)
+	// Release forgets key so the request can be retried.
+	Release(key string)
+}
+
+type idempotencyRecord struct {
+	fingerprint string
+	response    *StoredResponse
+	expiresAt   time.Time
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore for a single gateway instance.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*idempotencyRecord
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]*idempotencyRecord)}
+}
+
+func (s *MemoryIdempotencyStore) Begin(key, fingerprint string, ttl time.Duration) (IdempotencyState, *StoredResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	rec, ok := s.records[key]
+	if !ok || now.After(rec.expiresAt) {
+		s.records[key] = &idempotencyRecord{fingerprint: fingerprint, expiresAt: now.Add(ttl)}
+		return IdempotencyNew, nil
+	}
+
+	switch {
+	case rec.fingerprint != fingerprint:
+		return IdempotencyMismatch, nil
+	case rec.response == nil:
+		return IdempotencyInFlight, nil
+	default:
+		return IdempotencyCompleted, rec.response
+	}
+}
+
+func (s *MemoryIdempotencyStore) Complete(key string, res *StoredResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok {
+		rec.response = res
+	}
+}
+
+func (s *MemoryIdempotencyStore) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+}
+
+// Cleanup removes expired records every interval until stop is closed.
+func (s *MemoryIdempotencyStore) Cleanup(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for key, rec := range s.records {
+				if now.After(rec.expiresAt) {
+					delete(s.records, key)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry. The first response for a key is stored for ttl and replayed to
+// duplicates; a duplicate arriving while the first request is still running
+// gets 409, and reusing a key for a different request gets 422. Keys are
+// scoped per user: by the Authorization header when present, otherwise by
+// client IP, which is only read from X-Forwarded-For behind trusted proxies.
+// Server errors are not stored so the client can retry them.
+// Requests whose path starts with one of the excluded prefixes are skipped.
+func Idempotency(store IdempotencyStore, ttl time.Duration, exclude ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" || isExcluded(c.Request.URL.Path, exclude) {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			problem.Abort(c, http.StatusBadRequest, apierror.ReasonInvalidIdempotency, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			problem.Abort(c, http.StatusBadRequest, apierror.ReasonMalformedRequest, "failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scopedKey := idempotencyScope(c) + ":" + key
+		fingerprint := hash([]byte(c.Request.Method+" "+c.Request.URL.Path+"\n"), body)
+
+		state, stored := store.Begin(scopedKey, fingerprint, ttl)
+		switch state {
+		case IdempotencyInFlight:
+			problem.Abort(c, http.StatusConflict, apierror.ReasonIdempotencyInFlight, "a request with this Idempotency-Key is already in progress")
+			return
+		case IdempotencyMismatch:
+			problem.Abort(c, http.StatusUnprocessableEntity, apierror.ReasonIdempotencyMismatch, "Idempotency-Key was already used for a different request")
+			return
+		case IdempotencyCompleted:
+			for name, values := range stored.Header {
+				c.Writer.Header()[name] = values
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.Status, stored.Header.Get("Content-Type"), stored.Body)
+			c.Abort()
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+
+		defer func() {
+			if r := recover(); r != nil {
+				store.Release(scopedKey)
+				panic(r)
+			}
+		}()
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			store.Release(scopedKey)
+			return
+		}
+		store.Complete(scopedKey, &StoredResponse{
+			Status: status,
+			Header: replayedHeader(c.Writer.Header()),
+			Body:   rec.body.Bytes(),
+		})
+	}
+}
+
+// replayedHeaders describe the stored body. Everything else, like the
+// request ID, rate limit or CORS headers, belongs to the duplicate request and
+// is set by the middleware before this one.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// replayedHeader copies the replayedHeaders of a response.
+func replayedHeader(h http.Header) http.Header {
+	stored := http.Header{}
+	for _, name := range replayedHeaders {
+		if values := h.Values(name); len(values) > 0 {
+			stored[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	return stored
+}
+
+// responseRecorder copies the response body while it is written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+func idempotencyScope(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); auth != "" {
+		return "auth:" + hash([]byte(auth))
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func isExcluded(path string, exclude []string) bool {
+	for _, prefix := range exclude {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hash(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotencyReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if err := router.SetTrustedProxies(nil); err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	router.Use(func(c *gin.Context) {
+		c.Header("X-Request-ID", c.GetHeader("X-Request-ID"))
+		c.Next()
+	}, Idempotency(NewMemoryIdempotencyStore(), time.Hour))
+	router.POST("/orders", func(c *gin.Context) {
+		calls++
+		c.Header("Location", "/orders/1")
+		c.Header("ETag", `"v1"`)
+		c.Header("Set-Cookie", "session=secret")
+		c.Header("X-Internal", "handler")
+		c.JSON(http.StatusCreated, gin.H{"id": "1"})
+	})
+
+	send := func(key, body, requestID, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set(IdempotencyKeyHeader, key)
+		req.Header.Set("X-Request-ID", requestID)
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("k1", `{"a":1}`, "first", "")
+	if first.Code != http.StatusCreated || calls != 1 {
+		t.Fatalf("first request: status %d, %d calls", first.Code, calls)
+	}
+
+	tests := []struct {
+		name         string
+		key          string
+		body         string
+		forwardedFor string
+		wantStatus   int
+		wantReplayed bool
+		wantCalls    int
+	}{
+		{"duplicate is replayed", "k1", `{"a":1}`, "", http.StatusCreated, true, 1},
+		{"forged X-Forwarded-For keeps the scope", "k1", `{"a":1}`, "203.0.113.9", http.StatusCreated, true, 1},
+		{"different body", "k1", `{"a":2}`, "", http.StatusUnprocessableEntity, false, 1},
+		{"new key", "k2", `{"a":1}`, "", http.StatusCreated, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(tt.key, tt.body, "retry", tt.forwardedFor)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != tt.wantReplayed {
+				t.Fatalf("replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+			if !tt.wantReplayed {
+				return
+			}
+			if w.Body.String() != first.Body.String() {
+				t.Errorf("body = %s, want %s", w.Body, first.Body)
+			}
+			want := map[string][]string{
+				"Content-Type": {"application/json; charset=utf-8"},
+				"Location":     {"/orders/1"},
+				"Etag":         {`"v1"`},
+				"X-Request-Id": {"retry"},
+				"Set-Cookie":   nil,
+				"X-Internal":   nil,
+			}
+			for name, values := range want {
+				if got := w.Header().Values(name); strings.Join(got, ",") != strings.Join(values, ",") {
+					t.Errorf("%s = %q, want %q", name, got, values)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/best-microservice/common/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestID accepts the X-Request-ID header of the client, or generates a new
+// ID when it is missing or malformed. The ID is echoed in the response and
+// stored in the request context, from where it is attached to log lines and
+// forwarded to the backend services as gRPC metadata.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(logging.HeaderName)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Header(logging.HeaderName, id)
+		c.Next()
+	}
+}
+
+// Logger writes one structured access log line per request. Sensitive query
+// parameters such as tokens are redacted; bodies are never logged.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if q := c.Request.URL.Query(); len(q) > 0 {
+			attrs = append(attrs, slog.String("query", redactQuery(q)))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.LogAttrs(c.Request.Context(), level, "http request", attrs...)
+	}
+}
+
+func redactQuery(q url.Values) string {
+	for key := range q {
+		if logging.IsSensitive(key) {
+			q[key] = []string{logging.Redacted}
+		}
+	}
+	return q.Encode()
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/best-microservice/common/logging"
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoggerRedactsQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, "api-gateway", "info", "json"))
+	defer slog.SetDefault(previous)
+
+	engine := gin.New()
+	engine.Use(RequestID(), Logger())
+	engine.GET("/auth/verify", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/verify?token=s3cret&api_key=k1&lang=en", nil)
+	req.Header.Set(logging.HeaderName, "req-1")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	query, _ := url.ParseQuery(line["query"].(string))
+	if query.Get("token") != logging.Redacted || query.Get("api_key") != logging.Redacted || query.Get("lang") != "en" {
+		t.Errorf("query %s, want token and api_key redacted", line["query"])
+	}
+	if strings.Contains(buf.String(), "s3cret") || strings.Contains(buf.String(), "k1") {
+		t.Errorf("access log contains a secret: %s", buf.String())
+	}
+	if line["request_id"] != "req-1" || line["route"] != "/auth/verify" {
+		t.Errorf("access log %v, want the request ID and route", line)
+	}
+}
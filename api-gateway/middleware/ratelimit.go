@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/best-microservice/api-gateway/problem"
+	"github.com/best-microservice/common/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader is the request header identifying API clients.
+const APIKeyHeader = "X-API-Key"
+
+// UserIDKey is the gin context key under which authentication stores the
+// ID of the calling user.
+const UserIDKey = "user_id"
+
+// Limit is a token bucket: it holds up to Requests tokens and refills at
+// Requests per Per, so clients may burst up to Requests at once.
+type Limit struct {
+	Requests int           `yaml:"requests" env:"REQUESTS" flag:"requests" usage:"requests allowed per period, also the burst size"`
+	Per      time.Duration `yaml:"per" env:"PER" flag:"per" usage:"rate limit period"`
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d;w=%d", l.Requests, int(l.Per.Seconds()))
+}
+
+// interval is the time it takes to refill one token.
+func (l Limit) interval() time.Duration {
+	return l.Per / time.Duration(l.Requests)
+}
+
+// RateLimitResult is the state of a bucket after taking a token from it.
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token, when not allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps token buckets. Implementations must be safe for
+// concurrent use; shared stores let several gateways enforce one limit.
+type RateLimitStore interface {
+	// Take removes a token from the bucket key, created full if missing.
+	Take(ctx context.Context, key string, limit Limit) (RateLimitResult, error)
+}
+
+// bucketState computes the outcome of taking a token from a bucket holding
+// tokens at last, refilled up to now. It is shared by the stores so that
+// they behave identically.
+func bucketState(tokens float64, last, now time.Time, limit Limit) (float64, RateLimitResult) {
+	capacity := float64(limit.Requests)
+	interval := limit.interval()
+	if elapsed := now.Sub(last); elapsed > 0 {
+		tokens = math.Min(capacity, tokens+float64(elapsed)/float64(interval))
+	}
+
+	var res RateLimitResult
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - tokens) * float64(interval))
+	}
+	res.Remaining = int(tokens)
+	res.Reset = time.Duration((capacity - tokens) * float64(interval))
+	return tokens, res
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	per     time.Duration
+}
+
+// MemoryRateLimitStore is a RateLimitStore for a single gateway instance.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*bucket)}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit Limit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updated: now, per: limit.Per}
+		s.buckets[key] = b
+	}
+	tokens, res := bucketState(b.tokens, b.updated, now, limit)
+	b.tokens, b.updated, b.per = tokens, now, limit.Per
+	return res, nil
+}
+
+// Cleanup removes buckets that have refilled completely every interval
+// until stop is closed; a missing bucket is equivalent to a full one.
+func (s *MemoryRateLimitStore) Cleanup(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for key, b := range s.buckets {
+				if now.Sub(b.updated) > b.per {
+					delete(s.buckets, key)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// RateLimitKey identifies the client a request is counted against.
+type RateLimitKey func(c *gin.Context) string
+
+// KeyByIP counts requests per client IP.
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByAPIKey counts requests per API key, and per IP for requests without
+// one of the given keys. Unknown keys are not trusted, or clients could get
+// a fresh budget by sending a new key with every request.
+func KeyByAPIKey(keys []string) RateLimitKey {
+	known := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		known[hash([]byte(key))] = true
+	}
+	return func(c *gin.Context) string {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			if h := hash([]byte(key)); known[h] {
+				return "key:" + h
+			}
+		}
+		return KeyByIP(c)
+	}
+}
+
+// KeyByUser counts requests per authenticated user, falling back to byKey
+// for anonymous requests. Authenticate must run before it to set UserIDKey.
+func KeyByUser(byKey RateLimitKey) RateLimitKey {
+	return func(c *gin.Context) string {
+		if id := c.GetString(UserIDKey); id != "" {
+			return "user:" + id
+		}
+		return byKey(c)
+	}
+}
+
+// RateLimit allows each client limit requests to the routes it is installed
+// on. name separates the buckets of different route groups, so a client has
+// its own budget per group. Responses carry RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers; rejected
+// requests get 429 with Retry-After. If the store fails the request is let
+// through, since an outage of the store should not take the API down.
+func RateLimit(store RateLimitStore, name string, limit Limit, key RateLimitKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := store.Take(c.Request.Context(), "ratelimit:"+name+":"+key(c), limit)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "rate limit store unavailable", "group", name, "error", err)
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		h.Set("RateLimit-Policy", limit.String())
+		if !res.Allowed {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			problem.Abort(c, http.StatusTooManyRequests, apierror.ReasonRateLimited, "rate limit exceeded")
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// bucketSteps take tokens from a 3 per second bucket (one every 333ms).
+var bucketSteps = []struct {
+	name          string
+	after         time.Duration
+	wantAllowed   bool
+	wantRemaining int
+	wantRetry     time.Duration
+}{
+	{"full bucket", 0, true, 2, 0},
+	{"burst", 0, true, 1, 0},
+	{"last token", 0, true, 0, 0},
+	{"empty", 0, false, 0, 333333 * time.Microsecond},
+	{"partly refilled", 100 * time.Millisecond, false, 0, 233333 * time.Microsecond},
+	{"one token back", 300 * time.Millisecond, true, 0, 0},
+	{"refill stops at capacity", time.Hour, true, 2, 0},
+}
+
+var bucketLimit = Limit{Requests: 3, Per: time.Second}
+
+func TestBucketState(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tokens, last := float64(bucketLimit.Requests), now
+	for _, step := range bucketSteps {
+		now = now.Add(step.after)
+		var res RateLimitResult
+		tokens, res = bucketState(tokens, last, now, bucketLimit)
+		last = now
+
+		if res.Allowed != step.wantAllowed || res.Remaining != step.wantRemaining {
+			t.Fatalf("%s: allowed %v, remaining %d; want %v, %d", step.name, res.Allowed, res.Remaining, step.wantAllowed, step.wantRemaining)
+		}
+		if diff := res.RetryAfter - step.wantRetry; diff < -time.Microsecond || diff > time.Microsecond {
+			t.Errorf("%s: retry after %v, want %v", step.name, res.RetryAfter, step.wantRetry)
+		}
+		if want := time.Duration((3 - tokens) * float64(time.Second/3)); res.Reset != want {
+			t.Errorf("%s: reset %v, want %v", step.name, res.Reset, want)
+		}
+	}
+}
+
+// TestRedisRateLimitStore runs the same steps through the Lua script, which
+// must agree with bucketState.
+func TestRedisRateLimitStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store := NewRedisRateLimitStore(client)
+
+	now := time.Unix(1700000000, 0)
+	for _, step := range bucketSteps {
+		now = now.Add(step.after)
+		server.SetTime(now)
+
+		res, err := store.Take(context.Background(), "test", bucketLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != step.wantAllowed || res.Remaining != step.wantRemaining {
+			t.Fatalf("%s: allowed %v, remaining %d; want %v, %d", step.name, res.Allowed, res.Remaining, step.wantAllowed, step.wantRemaining)
+		}
+		if diff := res.RetryAfter - step.wantRetry; diff < -time.Microsecond || diff > time.Microsecond {
+			t.Errorf("%s: retry after %v, want %v", step.name, res.RetryAfter, step.wantRetry)
+		}
+		if ttl := server.TTL("test"); ttl <= 0 || ttl > res.Reset+time.Second+time.Millisecond {
+			t.Errorf("%s: bucket expires in %v, want soon after it refilled (%v)", step.name, ttl, res.Reset)
+		}
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	byKey := KeyByAPIKey([]string{"known-key"})
+	tests := []struct {
+		name         string
+		key          RateLimitKey
+		apiKey       string
+		forwardedFor string
+		userID       string
+		want         string
+	}{
+		{"ip", KeyByIP, "", "", "", "ip:192.0.2.1"},
+		{"forwarded for from an untrusted peer", KeyByIP, "", "203.0.113.9", "", "ip:192.0.2.1"},
+		{"known api key", byKey, "known-key", "", "", "key:" + hash([]byte("known-key"))},
+		{"unknown api key counts per ip", byKey, "made-up", "", "", "ip:192.0.2.1"},
+		{"no api key", byKey, "", "", "", "ip:192.0.2.1"},
+		{"user", KeyByUser(byKey), "known-key", "", "u1", "user:u1"},
+		{"anonymous user", KeyByUser(byKey), "made-up", "", "", "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			if err := engine.SetTrustedProxies(nil); err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			engine.GET("/", func(c *gin.Context) {
+				if tt.userID != "" {
+					c.Set(UserIDKey, tt.userID)
+				}
+				got = tt.key(c)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			engine.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RateLimit(NewMemoryRateLimitStore(), "api", Limit{Requests: 2, Per: time.Minute}, KeyByIP))
+	engine.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	tests := []struct {
+		wantStatus    int
+		wantRemaining string
+		wantRetry     string
+	}{
+		{http.StatusNoContent, "1", ""},
+		{http.StatusNoContent, "0", ""},
+		{http.StatusTooManyRequests, "0", "30"},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Fatalf("request %d: status %d, want %d", i, w.Code, tt.wantStatus)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+			t.Errorf("request %d: RateLimit-Remaining %q, want %q", i, got, tt.wantRemaining)
+		}
+		if got := w.Header().Get("Retry-After"); got != tt.wantRetry {
+			t.Errorf("request %d: Retry-After %q, want %q", i, got, tt.wantRetry)
+		}
+		if got := w.Header().Get("RateLimit-Policy"); got != "2;w=60" {
+			t.Errorf("request %d: RateLimit-Policy %q", i, got)
+		}
+	}
+}
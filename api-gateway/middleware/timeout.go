@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/best-microservice/api-gateway/resilience"
+)
+
+// Timeout puts a deadline on the request context, which handlers pass on to
+// the backend calls. Routes listed in overrides, keyed by method and route
+// template ("GET /api/v1/orders/:id/invoice"), use their own timeout instead
+// of def, which also bounds each backend call attempt when it is longer than
+// the backend's timeout; routes listed in exclude, such as streams, get no
+// deadline. The deadline only ever shortens one the client already set.
+func Timeout(def time.Duration, overrides map[string]time.Duration, exclude ...string) gin.HandlerFunc {
+	excluded := make(map[string]bool, len(exclude))
+	for _, route := range exclude {
+		excluded[route] = true
+	}
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		if excluded[route] {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		timeout, ok := overrides[route]
+		if ok {
+			ctx = resilience.WithAttemptTimeout(ctx, timeout)
+		} else {
+			timeout = def
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
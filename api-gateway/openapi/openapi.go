@@ -0,0 +1,351 @@
+// Package openapi generates an OpenAPI 3 document from a list of operations
+// and the Go types of their request and response bodies. Schemas of plain
+// structs follow their json tags, with gin binding rules turned into the
+// matching validation keywords; generated proto messages are described from
+// their descriptors the way protojson writes them.
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Operation describes one route of the API.
+type Operation struct {
+	Method  string // GET, POST, ...
+	Path    string // gin route, e.g. /api/v1/users/:id
+	ID      string // operationId, e.g. getUser
+	Summary string
+	Tag     string
+	Params  []Param
+	// Request is a value of the request body type, or nil. RequestOmit
+	// lists fields of a proto message request that are not read from the
+	// body, such as those bound by the path.
+	Request     interface{}
+	RequestOmit []string
+	// Status is the success status and Response a value of its body type;
+	// a nil Response means no body.
+	Status   int
+	Response interface{}
+	// ResponseTypes lists the media types of a non-JSON response body,
+	// which is then documented as binary.
+	ResponseTypes []string
+}
+
+// Param is a query or header parameter. Path parameters are derived from
+// the route.
+type Param struct {
+	Name        string
+	In          string // query or header
+	Type        string // string, integer, ...
+	Description string
+	Required    bool
+	// Repeated parameters may be given several times, e.g. ?a=1&a=2.
+	Repeated bool
+}
+
+// Info is the document metadata.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a base URL the operation paths are relative to.
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Document is the generated OpenAPI document, ready to be marshalled.
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Servers    []Server                        `json:"servers,omitempty"`
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components components                      `json:"components"`
+}
+
+type components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []parameter         `json:"parameters,omitempty"`
+	RequestBody *requestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]response `json:"responses"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of the OpenAPI schema object the generator uses.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+}
+
+var pathParam = regexp.MustCompile(`:([A-Za-z_]+)`)
+
+// OpenAPIPath converts a gin route to an OpenAPI path: /users/:id becomes
+// /users/{id}.
+func OpenAPIPath(route string) string {
+	return pathParam.ReplaceAllString(route, "{$1}")
+}
+
+// Generate builds the document. errorBody is a value of the type every
+// failed request returns, documented as the default response with
+// errorContentType.
+func Generate(info Info, ops []Operation, errorBody interface{}, errorContentType string) (*Document, error) {
+	g := &generator{schemas: map[string]*Schema{}}
+	doc := &Document{
+		OpenAPI:    "3.0.3",
+		Info:       info,
+		Paths:      map[string]map[string]operation{},
+		Components: components{Schemas: g.schemas},
+	}
+	errorSchema := g.schemaOf(errorBody)
+
+	for _, op := range ops {
+		path := OpenAPIPath(op.Path)
+		method := strings.ToLower(op.Method)
+		if _, dup := doc.Paths[path][method]; dup {
+			return nil, fmt.Errorf("openapi: duplicate operation %s %s", op.Method, op.Path)
+		}
+
+		o := operation{
+			OperationID: op.ID,
+			Summary:     op.Summary,
+			Responses: map[string]response{
+				"default": {
+					Description: "Error",
+					Content:     map[string]mediaType{errorContentType: {Schema: errorSchema}},
+				},
+			},
+		}
+		if op.Tag != "" {
+			o.Tags = []string{op.Tag}
+		}
+		for _, m := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+			o.Parameters = append(o.Parameters, parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+		for _, p := range op.Params {
+			schema := &Schema{Type: p.Type}
+			if p.Repeated {
+				schema = &Schema{Type: "array", Items: schema}
+			}
+			o.Parameters = append(o.Parameters, parameter{Name: p.Name, In: p.In, Description: p.Description, Required: p.Required, Schema: schema})
+		}
+		if op.Request != nil {
+			var body *Schema
+			if pm, ok := op.Request.(proto.Message); ok && len(op.RequestOmit) > 0 {
+				body = g.messageObject(pm.ProtoReflect().Descriptor(), op.RequestOmit)
+			} else {
+				body = g.schemaOf(op.Request)
+			}
+			o.RequestBody = &requestBody{
+				Required: true,
+				Content:  map[string]mediaType{"application/json": {Schema: body}},
+			}
+		}
+
+		res := response{Description: "Success"}
+		switch {
+		case len(op.ResponseTypes) > 0:
+			res.Content = map[string]mediaType{}
+			for _, ct := range op.ResponseTypes {
+				res.Content[ct] = mediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+			}
+		case op.Response != nil:
+			res.Content = map[string]mediaType{"application/json": {Schema: g.schemaOf(op.Response)}}
+		}
+		o.Responses[strconv.Itoa(op.Status)] = res
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]operation{}
+		}
+		doc.Paths[path][method] = o
+	}
+	return doc, nil
+}
+
+type generator struct {
+	schemas map[string]*Schema
+}
+
+// schemaOf returns the schema of the type of v.
+func (g *generator) schemaOf(v interface{}) *Schema {
+	if pm, ok := v.(proto.Message); ok {
+		return g.message(pm.ProtoReflect().Descriptor())
+	}
+	return g.schema(reflect.TypeOf(v))
+}
+
+// schema returns the schema of t; named structs are added to the
+// components and referenced.
+func (g *generator) schema(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schema(t.Elem())
+		if s.Ref != "" {
+			// Siblings of $ref are ignored in OpenAPI 3.0
+			return &Schema{AllOf: []*Schema{s}, Nullable: true}
+		}
+		s.Nullable = true
+		return s
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int32, reflect.Int16, reflect.Int8, reflect.Uint, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if isProto(t) {
+			return g.message(protoDescriptor(t))
+		}
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = &Schema{} // placeholder for recursive types
+			g.schemas[name] = g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := g.schema(f.Type)
+		if applyBinding(prop, f.Tag.Get("binding"), f.Type) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+// applyBinding adds the validation keywords of a gin binding tag to s and
+// reports whether the field is required.
+func applyBinding(s *Schema, tag string, t reflect.Type) bool {
+	if tag == "" {
+		return false
+	}
+	if s.Ref != "" {
+		return strings.Contains(","+tag+",", ",required,")
+	}
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		n, numErr := strconv.ParseFloat(param, 64)
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			s.Format = "email"
+		case "oneof":
+			s.Enum = strings.Fields(param)
+		case "gt", "gte":
+			if numErr == nil {
+				s.Minimum, s.ExclusiveMinimum = &n, name == "gt"
+			}
+		case "lt", "lte":
+			if numErr == nil {
+				s.Maximum, s.ExclusiveMaximum = &n, name == "lt"
+			}
+		case "min", "max", "len":
+			if numErr != nil {
+				continue
+			}
+			size := int(n)
+			switch {
+			case t.Kind() == reflect.Slice && name != "max":
+				s.MinItems = &size
+			case t.Kind() == reflect.String:
+				if name != "max" {
+					s.MinLength = &size
+				}
+				if name != "min" {
+					s.MaxLength = &size
+				}
+			case name == "min":
+				s.Minimum = &n
+			case name == "max":
+				s.Maximum = &n
+			}
+		}
+	}
+	return required
+}
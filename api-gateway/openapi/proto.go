@@ -0,0 +1,97 @@
+package openapi
+
+import (
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var protoMessage = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+// isProto reports whether t is the struct of a generated proto message.
+func isProto(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && reflect.PointerTo(t).Implements(protoMessage)
+}
+
+func protoDescriptor(t reflect.Type) protoreflect.MessageDescriptor {
+	return reflect.New(t).Interface().(proto.Message).ProtoReflect().Descriptor()
+}
+
+// message returns a reference to the schema of a proto message, described
+// the way protojson writes it with proto field names. Messages are named by
+// their full name, e.g. order.Address, as packages reuse message names.
+func (g *generator) message(md protoreflect.MessageDescriptor) *Schema {
+	name := string(md.FullName())
+	if _, ok := g.schemas[name]; !ok {
+		g.schemas[name] = &Schema{} // placeholder for recursive types
+		g.schemas[name] = g.messageObject(md, nil)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// messageObject describes the fields of md except omit.
+func (g *generator) messageObject(md protoreflect.MessageDescriptor, omit []string) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	fields := md.Fields()
+next:
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		for _, o := range omit {
+			if string(fd.Name()) == o {
+				continue next
+			}
+		}
+		s.Properties[string(fd.Name())] = g.protoField(fd)
+	}
+	return s
+}
+
+func (g *generator) protoField(fd protoreflect.FieldDescriptor) *Schema {
+	switch {
+	case fd.IsMap():
+		return &Schema{Type: "object", AdditionalProperties: g.protoValue(fd.MapValue())}
+	case fd.IsList():
+		return &Schema{Type: "array", Items: g.protoValue(fd)}
+	case fd.Message() != nil:
+		// Unset message fields are written as null
+		return &Schema{AllOf: []*Schema{g.protoValue(fd)}, Nullable: true}
+	default:
+		return g.protoValue(fd)
+	}
+}
+
+func (g *generator) protoValue(fd protoreflect.FieldDescriptor) *Schema {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return &Schema{Type: "string"}
+	case protoreflect.BoolKind:
+		return &Schema{Type: "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return &Schema{Type: "integer", Format: "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &Schema{Type: "integer", Format: "int64"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// protojson writes 64-bit integers as strings
+		return &Schema{Type: "string", Format: "int64"}
+	case protoreflect.FloatKind:
+		return &Schema{Type: "number", Format: "float"}
+	case protoreflect.DoubleKind:
+		return &Schema{Type: "number", Format: "double"}
+	case protoreflect.BytesKind:
+		return &Schema{Type: "string", Format: "byte"}
+	case protoreflect.EnumKind:
+		s := &Schema{Type: "string"}
+		values := fd.Enum().Values()
+		for i := 0; i < values.Len(); i++ {
+			s.Enum = append(s.Enum, string(values.Get(i).Name()))
+		}
+		return s
+	default:
+		if fd.Message().FullName() == "google.protobuf.Timestamp" {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		return g.message(fd.Message())
+	}
+}
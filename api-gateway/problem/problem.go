@@ -0,0 +1,156 @@
+// Package problem writes errors as RFC 7807 problem details
+// (application/problem+json). Every problem carries a stable "code", the
+// ErrorInfo reason from the services or one of the gateway's own reasons,
+// which clients should match on instead of the human readable detail.
+package problem
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/best-microservice/common/apierror"
+	"github.com/best-microservice/common/logging"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ContentType is the media type of problem responses.
+const ContentType = "application/problem+json"
+
+// TypeBase prefixes the code in the problem type, giving each code its own
+// URI reference, e.g. /problems/user-not-found.
+const TypeBase = "/problems/"
+
+// Problem is an RFC 7807 problem details object with the code, request_id
+// and errors extension members.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes an invalid request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// New returns a problem with the given HTTP status, reason code and detail.
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   TypeBase + strings.ToLower(strings.ReplaceAll(code, "_", "-")),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Write sends p and aborts the handler chain.
+func Write(c *gin.Context, p *Problem) {
+	p.Instance = c.Request.URL.Path
+	p.RequestID = logging.RequestID(c.Request.Context())
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
+
+// Abort is shorthand for Write(c, New(status, code, detail)).
+func Abort(c *gin.Context, status int, code, detail string) {
+	Write(c, New(status, code, detail))
+}
+
+// FromGRPC writes the problem for a failed backend call, see Convert. A
+// retry hint in the status is sent as Retry-After.
+func FromGRPC(c *gin.Context, err error) {
+	st := status.Convert(err)
+	for _, d := range st.Details() {
+		if d, ok := d.(*errdetails.RetryInfo); ok {
+			if delay := d.RetryDelay.AsDuration(); delay > 0 {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			}
+		}
+	}
+	Write(c, Convert(err))
+}
+
+// Convert translates the status of a failed backend call. Reasons and field
+// violations are taken from the status details; statuses without an
+// ErrorInfo did not come from a service handler (transport failures,
+// deadlines, open circuits), so their messages are replaced by a generic
+// detail rather than exposing connection internals.
+func Convert(err error) *Problem {
+	st := status.Convert(err)
+	httpStatus := HTTPStatus(st.Code())
+
+	info := apierror.ErrorInfo(st)
+	var p *Problem
+	if info != nil {
+		p = New(httpStatus, info.Reason, st.Message())
+	} else {
+		p = New(httpStatus, apierror.CodeReason(st.Code()), genericDetail(st))
+	}
+
+	for _, d := range st.Details() {
+		if d, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range d.FieldViolations {
+				p.Errors = append(p.Errors, FieldError{Field: v.Field, Message: v.Description})
+			}
+		}
+	}
+	return p
+}
+
+func genericDetail(st *status.Status) string {
+	switch st.Code() {
+	case codes.Unavailable:
+		return "the service is temporarily unavailable, try again later"
+	case codes.DeadlineExceeded:
+		return "the request timed out"
+	case codes.Canceled:
+		return "the request was cancelled"
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		return "internal error"
+	default:
+		return st.Message()
+	}
+}
+
+// HTTPStatus maps a gRPC code to the HTTP status used for it, following the
+// mapping of google.rpc.Code.
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // client closed request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
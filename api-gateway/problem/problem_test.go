@@ -0,0 +1,149 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/best-microservice/common/apierror"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantDetail string
+		wantErrors []FieldError
+	}{
+		{
+			name:       "service error keeps reason and message",
+			err:        apierror.NotFound(apierror.ReasonUserNotFound, "user", "u1"),
+			wantStatus: http.StatusNotFound,
+			wantCode:   apierror.ReasonUserNotFound,
+			wantDetail: "user not found",
+		},
+		{
+			name:       "field violations",
+			err:        apierror.Invalid("email", "must be a valid address"),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apierror.ReasonInvalidArgument,
+			wantDetail: "email must be a valid address",
+			wantErrors: []FieldError{{Field: "email", Message: "must be a valid address"}},
+		},
+		{
+			name:       "transport failure hides the message",
+			err:        status.Error(codes.Unavailable, "connection refused 10.0.0.3:50051"),
+			wantStatus: http.StatusServiceUnavailable,
+			wantCode:   "UNAVAILABLE",
+			wantDetail: "the service is temporarily unavailable, try again later",
+		},
+		{
+			name:       "deadline",
+			err:        status.Error(codes.DeadlineExceeded, "context deadline exceeded"),
+			wantStatus: http.StatusGatewayTimeout,
+			wantCode:   "DEADLINE_EXCEEDED",
+			wantDetail: "the request timed out",
+		},
+		{
+			name:       "plain status keeps its message",
+			err:        status.Error(codes.PermissionDenied, "client not allowed"),
+			wantStatus: http.StatusForbidden,
+			wantCode:   "PERMISSION_DENIED",
+			wantDetail: "client not allowed",
+		},
+		{
+			name:       "non-status error",
+			err:        errors.New("pq: relation users does not exist"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "UNKNOWN",
+			wantDetail: "internal error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Convert(tt.err)
+			if p.Status != tt.wantStatus || p.Code != tt.wantCode || p.Detail != tt.wantDetail {
+				t.Fatalf("got %d %s %q, want %d %s %q", p.Status, p.Code, p.Detail, tt.wantStatus, tt.wantCode, tt.wantDetail)
+			}
+			if p.Title != http.StatusText(tt.wantStatus) {
+				t.Errorf("title = %q", p.Title)
+			}
+			if len(p.Errors) != len(tt.wantErrors) {
+				t.Fatalf("errors = %v, want %v", p.Errors, tt.wantErrors)
+			}
+			for i := range p.Errors {
+				if p.Errors[i] != tt.wantErrors[i] {
+					t.Errorf("errors[%d] = %v, want %v", i, p.Errors[i], tt.wantErrors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewType(t *testing.T) {
+	if got := New(http.StatusNotFound, apierror.ReasonUserNotFound, "").Type; got != "/problems/user-not-found" {
+		t.Errorf("type = %q", got)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := map[codes.Code]int{
+		codes.OK:                 http.StatusOK,
+		codes.Canceled:           499,
+		codes.InvalidArgument:    http.StatusBadRequest,
+		codes.FailedPrecondition: http.StatusBadRequest,
+		codes.OutOfRange:         http.StatusBadRequest,
+		codes.Unauthenticated:    http.StatusUnauthorized,
+		codes.PermissionDenied:   http.StatusForbidden,
+		codes.NotFound:           http.StatusNotFound,
+		codes.AlreadyExists:      http.StatusConflict,
+		codes.Aborted:            http.StatusConflict,
+		codes.ResourceExhausted:  http.StatusTooManyRequests,
+		codes.Unimplemented:      http.StatusNotImplemented,
+		codes.Unavailable:        http.StatusServiceUnavailable,
+		codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+		codes.Internal:           http.StatusInternalServerError,
+		codes.Unknown:            http.StatusInternalServerError,
+		codes.DataLoss:           http.StatusInternalServerError,
+	}
+	for code, want := range tests {
+		if got := HTTPStatus(code); got != want {
+			t.Errorf("HTTPStatus(%s) = %d, want %d", code, got, want)
+		}
+	}
+}
+
+func TestFromGRPC(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/products/p1", nil)
+
+	FromGRPC(c, apierror.Unavailable("stock is being updated", 1500*time.Millisecond))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("content type = %q", got)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Code != apierror.ReasonUnavailable || p.Detail != "stock is being updated" || p.Instance != "/api/v1/products/p1" {
+		t.Errorf("problem = %+v", p)
+	}
+}
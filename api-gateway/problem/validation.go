@@ -0,0 +1,118 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/best-microservice/common/apierror"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// UseJSONFieldNames makes binding errors name fields by their JSON name
+// (shipping_address_id) instead of the Go one (ShippingAddressID). Call it
+// once at startup.
+func UseJSONFieldNames() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+}
+
+// Validation reports a request body that failed to bind: one field error
+// per failed validation rule, or a malformed request if the body isn't
+// valid JSON of the expected shape.
+func Validation(c *gin.Context, err error) {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		p := New(http.StatusBadRequest, apierror.ReasonInvalidArgument, "the request has invalid fields")
+		for _, fe := range verrs {
+			p.Errors = append(p.Errors, FieldError{Field: fieldPath(fe), Message: ruleMessage(fe)})
+		}
+		Write(c, p)
+		return
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		p := New(http.StatusBadRequest, apierror.ReasonMalformedRequest, "the request body has fields of the wrong type")
+		p.Errors = []FieldError{{Field: typeErr.Field, Message: "must be a " + typeErr.Type.String()}}
+		Write(c, p)
+		return
+	}
+	Abort(c, http.StatusBadRequest, apierror.ReasonMalformedRequest, "the request body is not valid JSON")
+}
+
+// fieldPath drops the struct name from the validator namespace:
+// "req.items[0].quantity" becomes "items[0].quantity".
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.IndexByte(ns, '.'); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_with":
+		return "is required with " + snakeCase(fe.Param())
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "lte":
+		return "must be at most " + fe.Param()
+	case "len":
+		return "must be exactly " + fe.Param() + " characters long"
+	case "min":
+		if fe.Kind() == reflect.Slice {
+			return "must contain at least " + fe.Param() + " items"
+		}
+		return "must be at least " + fe.Param() + " characters long"
+	case "max":
+		if fe.Kind() == reflect.Slice {
+			return "must contain at most " + fe.Param() + " items"
+		}
+		return "must be at most " + fe.Param() + " characters long"
+	case "email":
+		return "must be a valid email address"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	default:
+		return fmt.Sprintf("failed the %s check", fe.Tag())
+	}
+}
+
+// snakeCase turns a Go field name used as a rule parameter into the JSON
+// spelling: ShippingMethodID becomes shipping_method_id.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) && unicode.IsLower(rune(name[i-1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
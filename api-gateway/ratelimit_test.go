@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/best-microservice/api-gateway/middleware"
+)
+
+func TestRateLimitByUser(t *testing.T) {
+	validations := 0
+	authenticate := middleware.Authenticate(func(_ context.Context, token string) (string, error) {
+		validations++
+		if token == "bad" {
+			return "", status.Error(codes.Unauthenticated, "invalid session")
+		}
+		return token, nil
+	})
+	stop := make(chan struct{})
+	defer close(stop)
+	cfg := RateLimitConfig{
+		Enabled: true,
+		KeyBy:   "user",
+		Store:   "memory",
+		Default: middleware.Limit{Requests: 1, Per: time.Minute},
+		Auth:    middleware.Limit{Requests: 1, Per: time.Minute},
+	}
+	apiLimit, _ := rateLimiters(cfg, authenticate, stop)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group(apiBasePath, apiLimit...)
+	// routes acting for a user authenticate again
+	api.GET("/me", authenticate, func(c *gin.Context) { c.String(http.StatusOK, c.GetString(middleware.UserIDKey)) })
+
+	get := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, apiBasePath+"/me", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	steps := []struct {
+		token string
+		want  int
+	}{
+		{"alice", http.StatusOK},
+		{"alice", http.StatusTooManyRequests},
+		{"bob", http.StatusOK},
+		{"", http.StatusOK},
+		{"", http.StatusTooManyRequests},
+		{"bad", http.StatusUnauthorized},
+	}
+	for i, step := range steps {
+		if got := get(step.token); got != step.want {
+			t.Errorf("request %d with %q = %d, want %d", i, step.token, got, step.want)
+		}
+	}
+	if validations != 4 {
+		t.Errorf("sessions validated %d times, want once per request with a token", validations)
+	}
+}
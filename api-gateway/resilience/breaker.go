@@ -0,0 +1,120 @@
+package resilience
+
+import (
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case stateOpen:
+		return "open"
+	case stateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// breaker is a consecutive-failure circuit breaker. While open, calls are
+// rejected without reaching the backend; after the open timeout one probe
+// call is let through and its outcome closes or re-opens the circuit.
+type breaker struct {
+	name   string
+	policy BreakerPolicy
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(name string, policy BreakerPolicy) *breaker {
+	b := &breaker{name: name, policy: policy, now: time.Now}
+	circuitState.WithLabelValues(name).Set(float64(stateClosed))
+	return b
+}
+
+// allow reports whether a call may proceed.
+func (b *breaker) allow() bool {
+	if b.policy.FailureThreshold == 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if b.now().Sub(b.openedAt) < b.policy.OpenTimeout {
+			return false
+		}
+		b.setState(stateHalfOpen)
+		fallthrough
+	case stateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// record feeds the outcome of an allowed call back into the breaker.
+func (b *breaker) record(success bool) {
+	if b.policy.FailureThreshold == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == stateHalfOpen {
+		b.probing = false
+		if success {
+			b.failures = 0
+			b.setState(stateClosed)
+		} else {
+			b.open()
+		}
+		return
+	}
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == stateClosed && b.failures >= b.policy.FailureThreshold {
+		b.open()
+	}
+}
+
+// release ends an allowed call whose outcome says nothing about the
+// backend, such as one cancelled by the client.
+func (b *breaker) release() {
+	if b.policy.FailureThreshold == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *breaker) open() {
+	b.openedAt = b.now()
+	b.setState(stateOpen)
+	circuitOpened.WithLabelValues(b.name).Inc()
+}
+
+func (b *breaker) setState(s breakerState) {
+	b.state = s
+	circuitState.WithLabelValues(b.name).Set(float64(s))
+}
@@ -0,0 +1,126 @@
+package resilience
+
+import (
+	"testing"
+	"time"
+)
+
+// breakerStep is one call through the breaker: after waiting, the call asks
+// allow and, when allowed, reports outcome.
+type breakerStep struct {
+	wait      time.Duration
+	wantAllow bool
+	outcome   string // "success", "failure" or "release"
+	wantState breakerState
+}
+
+func TestBreaker(t *testing.T) {
+	policy := BreakerPolicy{FailureThreshold: 3, OpenTimeout: 10 * time.Second}
+	tests := []struct {
+		name   string
+		policy BreakerPolicy
+		steps  []breakerStep
+	}{
+		{
+			name:   "opens after consecutive failures",
+			policy: policy,
+			steps: []breakerStep{
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateOpen},
+				{wait: 9 * time.Second, wantAllow: false, wantState: stateOpen},
+			},
+		},
+		{
+			name:   "success resets the failure count",
+			policy: policy,
+			steps: []breakerStep{
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "success", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+			},
+		},
+		{
+			name:   "successful probe closes the circuit",
+			policy: policy,
+			steps: []breakerStep{
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateOpen},
+				{wait: 10 * time.Second, wantAllow: true, outcome: "success", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+			},
+		},
+		{
+			name:   "failed probe re-opens the circuit",
+			policy: policy,
+			steps: []breakerStep{
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateOpen},
+				{wait: 10 * time.Second, wantAllow: true, outcome: "failure", wantState: stateOpen},
+				{wait: 5 * time.Second, wantAllow: false, wantState: stateOpen},
+				{wait: 5 * time.Second, wantAllow: true, outcome: "success", wantState: stateClosed},
+			},
+		},
+		{
+			name:   "one probe at a time",
+			policy: policy,
+			steps: []breakerStep{
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateOpen},
+				// The probe is still running
+				{wait: 10 * time.Second, wantAllow: true, wantState: stateHalfOpen},
+				{wantAllow: false, wantState: stateHalfOpen},
+			},
+		},
+		{
+			name:   "released probe lets the next call probe",
+			policy: policy,
+			steps: []breakerStep{
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateOpen},
+				{wait: 10 * time.Second, wantAllow: true, outcome: "release", wantState: stateHalfOpen},
+				{wantAllow: true, outcome: "success", wantState: stateClosed},
+			},
+		},
+		{
+			name:   "threshold 0 disables the breaker",
+			policy: BreakerPolicy{},
+			steps: []breakerStep{
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+				{wantAllow: true, outcome: "failure", wantState: stateClosed},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			b := newBreaker("test", tt.policy)
+			b.now = func() time.Time { return now }
+
+			for i, step := range tt.steps {
+				now = now.Add(step.wait)
+				if allowed := b.allow(); allowed != step.wantAllow {
+					t.Fatalf("step %d: allow() = %v, want %v", i, allowed, step.wantAllow)
+				}
+				switch step.outcome {
+				case "success":
+					b.record(true)
+				case "failure":
+					b.record(false)
+				case "release":
+					b.release()
+				}
+				if b.state != step.wantState {
+					t.Fatalf("step %d: state %s, want %s", i, b.state, step.wantState)
+				}
+			}
+		})
+	}
+}
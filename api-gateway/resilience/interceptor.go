@@ -0,0 +1,125 @@
+package resilience
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/best-microservice/common/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	circuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Name:      "circuit_breaker_state",
+		Help:      "State of the circuit breaker per backend: 0 closed, 1 open, 2 half-open.",
+	}, []string{"backend"})
+
+	circuitOpened = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "circuit_breaker_opened_total",
+		Help:      "Times the circuit breaker of a backend opened.",
+	}, []string{"backend"})
+
+	retries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "grpc_client_retries_total",
+		Help:      "gRPC calls retried by the gateway, by backend and method.",
+	}, []string{"backend", "grpc_method"})
+)
+
+// UnaryClientInterceptor applies policy to the calls on the connection to
+// backend. Install it first in the chain so that the logging and metrics
+// interceptors see every attempt. Calls rejected by an open circuit fail
+// with codes.Unavailable without reaching the backend.
+func UnaryClientInterceptor(backend string, policy Policy) grpc.UnaryClientInterceptor {
+	cb := newBreaker(backend, policy.Breaker)
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		attempts := 1
+		if policy.Retry.retryable(method) {
+			attempts = policy.Retry.MaxAttempts
+		}
+
+		var err error
+		for n := 1; ; n++ {
+			if !cb.allow() {
+				return status.Errorf(codes.Unavailable, "%s is unavailable (circuit open)", backend)
+			}
+
+			attemptCtx, cancel := context.WithTimeout(ctx, attemptTimeout(ctx, policy.Timeout))
+			err = invoker(attemptCtx, method, req, reply, cc, opts...)
+			cancel()
+
+			code := status.Code(err)
+			switch {
+			case ctx.Err() != nil && code != codes.OK:
+				// The caller gave up; that says nothing about the backend.
+				cb.release()
+				return err
+			case backendFailure(code):
+				cb.record(false)
+			default:
+				cb.record(true)
+			}
+
+			if n >= attempts || !retryableCode(code) {
+				return err
+			}
+
+			wait := policy.Retry.backoff(n, rand.Int64N)
+			slog.WarnContext(ctx, "retrying gRPC call", "backend", backend, "method", method,
+				"attempt", n, "code", code.String(), "backoff", wait)
+			retries.WithLabelValues(backend, method).Inc()
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+type attemptTimeoutKey struct{}
+
+// WithAttemptTimeout lets every attempt of the calls made with ctx run for
+// d when that is longer than the timeout of the backend's policy, for
+// requests known to be slow such as rendering an invoice.
+func WithAttemptTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, attemptTimeoutKey{}, d)
+}
+
+func attemptTimeout(ctx context.Context, def time.Duration) time.Duration {
+	if d, ok := ctx.Value(attemptTimeoutKey{}).(time.Duration); ok && d > def {
+		return d
+	}
+	return def
+}
+
+// backendFailure reports whether code indicates that the backend itself is
+// unhealthy, as opposed to rejecting a bad request.
+func backendFailure(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// retryableCode reports whether another attempt may succeed.
+func retryableCode(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package resilience
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestAttemptTimeout(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.Timeout = time.Second
+
+	tests := []struct {
+		name     string
+		ctx      func() (context.Context, context.CancelFunc)
+		wantLeft time.Duration
+	}{
+		{
+			name: "policy timeout",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Minute)
+			},
+			wantLeft: time.Second,
+		},
+		{
+			name: "longer route timeout",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(WithAttemptTimeout(context.Background(), 30*time.Second), 30*time.Second)
+			},
+			wantLeft: 30 * time.Second,
+		},
+		{
+			name: "shorter route timeout keeps the policy",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(WithAttemptTimeout(context.Background(), 100*time.Millisecond), time.Minute)
+			},
+			wantLeft: time.Second,
+		},
+		{
+			name: "the request deadline still applies",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(WithAttemptTimeout(context.Background(), 30*time.Second), 5*time.Second)
+			},
+			wantLeft: 5 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var left time.Duration
+			invoker := func(ctx context.Context, _ string, _, _ interface{}, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+				deadline, _ := ctx.Deadline()
+				left = time.Until(deadline)
+				return nil
+			}
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			interceptor := UnaryClientInterceptor("order", policy)
+			if err := interceptor(ctx, "/order.OrderService/GetInvoice", nil, nil, nil, invoker); err != nil {
+				t.Fatal(err)
+			}
+			if left > tt.wantLeft || left < tt.wantLeft-time.Second/2 {
+				t.Errorf("attempt deadline in %v, want %v", left, tt.wantLeft)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Package resilience protects the gateway from slow or failing backends:
+// every gRPC call gets a deadline, idempotent calls are retried with
+// jittered exponential backoff, and a circuit breaker per backend fails
+// fast while the backend is down.
+package resilience
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+)
+
+// Policy configures the calls made to one backend service.
+type Policy struct {
+	// Timeout bounds every attempt, unless the request allows longer ones
+	// with WithAttemptTimeout. It never extends the deadline of the
+	// incoming request, only shortens it.
+	Timeout time.Duration `yaml:"timeout" env:"TIMEOUT" flag:"timeout" usage:"deadline of a single call attempt"`
+	Retry   RetryPolicy   `yaml:"retry" env:"RETRY_" flag:"retry-"`
+	Breaker BreakerPolicy `yaml:"breaker" env:"BREAKER_" flag:"breaker-"`
+}
+
+// RetryPolicy decides which calls are retried and how long to wait between
+// attempts. Only methods listed in Methods are retried, since a retried call
+// may reach the backend twice.
+type RetryPolicy struct {
+	MaxAttempts    int           `yaml:"max_attempts" env:"MAX_ATTEMPTS" flag:"max-attempts" usage:"attempts per call including the first, 1 disables retries"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env:"INITIAL_BACKOFF" flag:"initial-backoff" usage:"backoff before the first retry"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env:"MAX_BACKOFF" flag:"max-backoff" usage:"upper bound of the backoff between retries"`
+	Methods        []string      `yaml:"methods" env:"METHODS" flag:"methods" usage:"comma separated idempotent method names or patterns, e.g. Get*,List*"`
+}
+
+// BreakerPolicy configures the circuit breaker of a backend. It opens after
+// FailureThreshold consecutive failures and lets a single probe call through
+// once OpenTimeout has passed.
+type BreakerPolicy struct {
+	FailureThreshold int           `yaml:"failure_threshold" env:"FAILURE_THRESHOLD" flag:"failure-threshold" usage:"consecutive failures that open the circuit, 0 disables the breaker"`
+	OpenTimeout      time.Duration `yaml:"open_timeout" env:"OPEN_TIMEOUT" flag:"open-timeout" usage:"how long the circuit stays open before a probe call"`
+}
+
+// DefaultPolicy retries reads up to three times and opens the circuit after
+// five consecutive failures.
+func DefaultPolicy() Policy {
+	return Policy{
+		Timeout: 3 * time.Second,
+		Retry: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: 50 * time.Millisecond,
+			MaxBackoff:     time.Second,
+			Methods:        []string{"Get*", "List*"},
+		},
+		Breaker: BreakerPolicy{
+			FailureThreshold: 5,
+			OpenTimeout:      10 * time.Second,
+		},
+	}
+}
+
+// Check reports invalid settings. It is not named Validate because the
+// same policy type appears once per backend and the caller adds the prefix.
+func (p Policy) Check() error {
+	var errs []error
+	if p.Timeout <= 0 {
+		errs = append(errs, errors.New("timeout must be positive"))
+	}
+	if p.Retry.MaxAttempts < 1 {
+		errs = append(errs, errors.New("retry.max_attempts must be at least 1"))
+	}
+	if p.Retry.MaxAttempts > 1 && (p.Retry.InitialBackoff <= 0 || p.Retry.MaxBackoff < p.Retry.InitialBackoff) {
+		errs = append(errs, errors.New("retry.initial_backoff must be positive and not above retry.max_backoff"))
+	}
+	for _, m := range p.Retry.Methods {
+		if _, err := path.Match(m, ""); err != nil {
+			errs = append(errs, fmt.Errorf("retry.methods: invalid pattern %q", m))
+		}
+	}
+	if p.Breaker.FailureThreshold < 0 {
+		errs = append(errs, errors.New("breaker.failure_threshold must not be negative"))
+	}
+	if p.Breaker.FailureThreshold > 0 && p.Breaker.OpenTimeout <= 0 {
+		errs = append(errs, errors.New("breaker.open_timeout must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// retryable reports whether fullMethod, e.g. "/product.ProductService/GetProduct",
+// matches one of the idempotent method patterns.
+func (r RetryPolicy) retryable(fullMethod string) bool {
+	name := path.Base(fullMethod)
+	for _, pattern := range r.Methods {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before retry n (starting at 1): a random
+// duration up to the exponentially growing cap ("full jitter").
+func (r RetryPolicy) backoff(n int, random func(int64) int64) time.Duration {
+	ceiling := r.InitialBackoff
+	for i := 1; i < n && ceiling < r.MaxBackoff; i++ {
+		ceiling *= 2
+	}
+	if ceiling > r.MaxBackoff {
+		ceiling = r.MaxBackoff
+	}
+	return time.Duration(random(int64(ceiling)) + 1)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	orderpb "github.com/best-microservice/common/protos/order"
+	productpb "github.com/best-microservice/common/protos/product"
+	userpb "github.com/best-microservice/common/protos/user"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/best-microservice/api-gateway/handlers"
+	"github.com/best-microservice/api-gateway/middleware"
+	"github.com/best-microservice/api-gateway/openapi"
+	"github.com/best-microservice/api-gateway/problem"
+	"github.com/best-microservice/api-gateway/transcode"
+)
+
+// apiBasePath prefixes every route of the REST API.
+const apiBasePath = "/api/v1"
+
+// streamingRoutes hold their response open for as long as the client
+// listens, so the request timeout does not apply to them.
+var streamingRoutes = []string{
+	"GET " + apiBasePath + "/orders/:id/events",
+	"GET " + apiBasePath + "/orders/:id/ws",
+}
+
+// backends are the connections of the services behind the API.
+type backends struct {
+	user, product, order grpc.ClientConnInterface
+}
+
+// transcodedStatus lists the transcoded methods that do not answer 200 OK.
+var transcodedStatus = map[string]int{
+	userpb.UserService_CreateUser_FullMethodName:              http.StatusCreated,
+	userpb.UserService_ResendVerificationEmail_FullMethodName: http.StatusAccepted,
+	userpb.UserService_RequestPasswordReset_FullMethodName:    http.StatusAccepted,
+	userpb.UserService_ResetPassword_FullMethodName:           http.StatusNoContent,
+	userpb.UserService_ChangePassword_FullMethodName:          http.StatusNoContent,
+	userpb.UserService_DisableTotp_FullMethodName:             http.StatusNoContent,
+	userpb.UserService_AddAddress_FullMethodName:              http.StatusCreated,
+	userpb.UserService_DeleteAddress_FullMethodName:           http.StatusNoContent,
+	productpb.ProductService_CreateProduct_FullMethodName:     http.StatusCreated,
+	orderpb.OrderService_CreateShipment_FullMethodName:        http.StatusCreated,
+}
+
+// ownedMethods lists the transcoded methods that act on the resources of
+// a user, by the path parameter naming them. Only a session of that user
+// may call them.
+var ownedMethods = map[string]string{
+	userpb.UserService_ChangePassword_FullMethodName:  "user_id",
+	userpb.UserService_EnrollTotp_FullMethodName:      "user_id",
+	userpb.UserService_ConfirmTotp_FullMethodName:     "user_id",
+	userpb.UserService_DisableTotp_FullMethodName:     "user_id",
+	userpb.UserService_AddAddress_FullMethodName:      "user_id",
+	userpb.UserService_ListAddresses_FullMethodName:   "user_id",
+	userpb.UserService_DeleteAddress_FullMethodName:   "user_id",
+	orderpb.OrderService_GetUserOrders_FullMethodName: "user_id",
+}
+
+// orderMethods lists the transcoded methods on the order in their id path
+// parameter, which only the user who placed it may call.
+var orderMethods = []string{
+	orderpb.OrderService_GetOrder_FullMethodName,
+}
+
+// transcodedRoutes returns the routes derived from the HTTP annotations of
+// the services' protos, with the connection serving each.
+func transcodedRoutes(conns backends) ([]transcode.Route, []grpc.ClientConnInterface, error) {
+	var (
+		routes []transcode.Route
+		served []grpc.ClientConnInterface
+	)
+	for _, s := range []struct {
+		conn grpc.ClientConnInterface
+		desc protoreflect.ServiceDescriptor
+	}{
+		{conns.user, userpb.File_user_proto.Services().ByName("UserService")},
+		{conns.product, productpb.File_product_proto.Services().ByName("ProductService")},
+		{conns.order, orderpb.File_order_proto.Services().ByName("OrderService")},
+	} {
+		rs, err := transcode.Routes(s.desc, apiBasePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, r := range rs {
+			if status, ok := transcodedStatus[r.FullMethod]; ok {
+				r.Status = status
+			}
+			routes = append(routes, r)
+			served = append(served, s.conn)
+		}
+	}
+	return routes, served, nil
+}
+
+// registerAPIRoutes adds the REST API to its router group: the transcoded
+// routes, plus the hand-written ones of handlers.Operations. methodMiddleware
+// lists handlers run before the transcoded method they are keyed by, such as
+// the rate limit of the auth route. authenticate runs before the routes that
+// act for the signed in user, followed by the owner check of ownedMethods.
+func registerAPIRoutes(api gin.IRoutes, conns backends, orderHandler *handlers.OrderHandler, methodMiddleware map[string][]gin.HandlerFunc, authenticate gin.HandlerFunc) error {
+	routes, served, err := transcodedRoutes(conns)
+	if err != nil {
+		return err
+	}
+	for i, r := range routes {
+		chain := append([]gin.HandlerFunc(nil), methodMiddleware[r.FullMethod]...)
+		if param, ok := ownedMethods[r.FullMethod]; ok {
+			chain = append(chain, authenticate, middleware.RequireUser(param))
+		}
+		if slices.Contains(orderMethods, r.FullMethod) {
+			chain = append(chain, authenticate, orderHandler.RequireOwner)
+		}
+		chain = append(chain, r.Handler(served[i]))
+		api.Handle(r.Method, r.Path, chain...)
+	}
+
+	// Order placement aggregates the user and order services
+	api.POST("/orders", authenticate, orderHandler.CreateOrder)
+	api.GET("/orders/:id/invoice", authenticate, orderHandler.RequireOwner, orderHandler.GetInvoice)
+	// Status changes are pushed rather than polled
+	api.GET("/orders/:id/events", authenticate, orderHandler.WatchOrderEvents)
+	api.GET("/orders/:id/ws", authenticate, orderHandler.WatchOrderSocket)
+	return nil
+}
+
+// apiDocument generates the OpenAPI document of the REST API. POST routes
+// other than auth accept an Idempotency-Key, cached routes conditional
+// requests.
+func apiDocument() (*openapi.Document, error) {
+	routes, _, err := transcodedRoutes(backends{})
+	if err != nil {
+		return nil, err
+	}
+	var ops []openapi.Operation
+	for _, r := range routes {
+		op := r.Operation()
+		if _, ok := ownedMethods[r.FullMethod]; ok || slices.Contains(orderMethods, r.FullMethod) {
+			op.Params = append(op.Params, handlers.AuthorizationParam)
+		}
+		if slices.Contains(cachedMethods, r.FullMethod) {
+			op.Params = append(op.Params,
+				openapi.Param{Name: "If-None-Match", In: "header", Type: "string",
+					Description: "ETag of a response held by the client; 304 Not Modified if it is still current"},
+				openapi.Param{Name: "If-Modified-Since", In: "header", Type: "string",
+					Description: "Last-Modified of a response held by the client; 304 Not Modified if no product in it changed since. Ignored with If-None-Match"})
+		}
+		ops = append(ops, op)
+	}
+	ops = append(ops, handlers.Operations()...)
+
+	for i := range ops {
+		if ops[i].Method == http.MethodPost && !strings.HasPrefix(ops[i].Path, "/auth") {
+			ops[i].Params = append(ops[i].Params, openapi.Param{
+				Name:        middleware.IdempotencyKeyHeader,
+				In:          "header",
+				Type:        "string",
+				Description: "Replays the stored response when a request is retried with the same key",
+			})
+		}
+	}
+
+	doc, err := openapi.Generate(openapi.Info{
+		Title:       "best-microservice API",
+		Version:     "1.0.0",
+		Description: "REST API of the best-microservice gateway. Errors are RFC 7807 problem details.",
+	}, ops, problem.Problem{}, problem.ContentType)
+	if err != nil {
+		return nil, err
+	}
+	doc.Servers = []openapi.Server{{URL: apiBasePath}}
+	return doc, nil
+}
+
+// registerDocs serves the OpenAPI document at /openapi.json and a Swagger
+// UI rendering it at /docs.
+func registerDocs(router gin.IRoutes, doc *openapi.Document) {
+	router.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	})
+	router.GET("/docs", gin.WrapH(openapi.UIHandler(doc.Info.Title, "/openapi.json")))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/best-microservice/api-gateway/handlers"
+	"github.com/best-microservice/api-gateway/middleware"
+	"github.com/best-microservice/api-gateway/openapi"
+)
+
+// apiRouter registers the REST API on handlers whose connections are never
+// used; grpc.NewClient does not connect until the first call.
+func apiRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	conn, err := grpc.NewClient("passthrough:///unused", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	err = registerAPIRoutes(router.Group(apiBasePath), backends{user: conn, product: conn, order: conn},
+		handlers.NewOrderHandler(conn, conn, time.Second), nil, middleware.Authenticate(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func TestRoutesMatchOpenAPIDocument(t *testing.T) {
+	doc, err := apiDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented := map[string]bool{}
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	routed := map[string]bool{}
+	for _, r := range apiRouter(t).Routes() {
+		routed[r.Method+" "+openapi.OpenAPIPath(strings.TrimPrefix(r.Path, apiBasePath))] = true
+	}
+
+	for route := range routed {
+		if !documented[route] {
+			t.Errorf("route %s is not in the OpenAPI document; annotate the method or add it to handlers.Operations", route)
+		}
+	}
+	for op := range documented {
+		if !routed[op] {
+			t.Errorf("OpenAPI operation %s has no route", op)
+		}
+	}
+}
+
+func TestOpenAPIDocumentReferences(t *testing.T) {
+	doc, err := apiDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range regexp.MustCompile(`"\$ref":"#/components/schemas/(\w+)"`).FindAllSubmatch(data, -1) {
+		if _, ok := doc.Components.Schemas[string(m[1])]; !ok {
+			t.Errorf("schema %s is referenced but not defined", m[1])
+		}
+	}
+
+	ids := map[string]bool{}
+	for path, ops := range doc.Paths {
+		for method, op := range ops {
+			if op.OperationID == "" || ids[op.OperationID] {
+				t.Errorf("%s %s: missing or duplicate operationId %q", method, path, op.OperationID)
+			}
+			ids[op.OperationID] = true
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/best-microservice/common/apierror"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/best-microservice/api-gateway/problem"
+)
+
+var (
+	// Unknown body fields are ignored, as they were by the hand-written
+	// handlers.
+	unmarshal = protojson.UnmarshalOptions{DiscardUnknown: true}
+	// Zero values are written too, so clients always see every field.
+	marshal = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+)
+
+// Handler returns the gin handler calling the route's method on conn.
+func (r Route) Handler(conn grpc.ClientConnInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		in := r.input.New().Interface()
+		if p := r.decode(c, in); p != nil {
+			problem.Write(c, p)
+			return
+		}
+
+		out := r.output.New().Interface()
+		if err := conn.Invoke(c.Request.Context(), r.FullMethod, in, out); err != nil {
+			problem.FromGRPC(c, err)
+			return
+		}
+		Write(c, r.Status, out)
+	}
+}
+
+// Write sends msg as JSON with proto field names. A 204 No Content status
+// sends no body.
+func Write(c *gin.Context, status int, msg proto.Message) {
+	if status == http.StatusNoContent {
+		c.Status(status)
+		return
+	}
+	data, err := Marshal(msg)
+	if err != nil {
+		problem.Abort(c, http.StatusInternalServerError, apierror.ReasonInternal, "internal error")
+		return
+	}
+	c.Data(status, "application/json; charset=utf-8", data)
+}
+
+// Marshal encodes msg as JSON the way responses are written, for handlers
+// sending messages other than through Write.
+func Marshal(msg proto.Message) ([]byte, error) {
+	data, err := marshal.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	// protojson varies its whitespace on purpose; responses should be
+	// stable byte for byte
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err == nil {
+		data = buf.Bytes()
+	}
+	return data, nil
+}
+
+// decode fills in from the body, the query string and the path, in that
+// order, so path variables cannot be overridden by the body.
+func (r Route) decode(c *gin.Context, in proto.Message) *problem.Problem {
+	msg := in.ProtoReflect()
+
+	if r.body != "" {
+		data, err := c.GetRawData()
+		if err != nil {
+			return problem.New(http.StatusBadRequest, apierror.ReasonMalformedRequest, "the request body could not be read")
+		}
+		if len(data) > 0 {
+			target := in
+			if r.body != "*" {
+				fd := msg.Descriptor().Fields().ByName(protoreflect.Name(r.body))
+				target = msg.Mutable(fd).Message().Interface()
+			}
+			if err := unmarshal.Unmarshal(data, target); err != nil {
+				return problem.New(http.StatusBadRequest, apierror.ReasonMalformedRequest,
+					"the request body is not valid JSON of the expected shape: "+protoErrorText(err))
+			}
+		}
+	}
+
+	if r.body != "*" {
+		var errs []problem.FieldError
+		for key, values := range c.Request.URL.Query() {
+			fd := queryField(msg.Descriptor(), key)
+			if fd == nil || r.isPathField(fd) {
+				continue
+			}
+			if err := setField(msg, fd, values); err != nil {
+				errs = append(errs, problem.FieldError{Field: key, Message: err.Error()})
+			}
+		}
+		if len(errs) > 0 {
+			p := problem.New(http.StatusBadRequest, apierror.ReasonInvalidArgument, "the request has invalid query parameters")
+			p.Errors = errs
+			return p
+		}
+	}
+
+	for _, v := range r.vars {
+		msg.Set(v.field, protoreflect.ValueOfString(c.Param(v.param)))
+	}
+	return nil
+}
+
+func (r Route) isPathField(fd protoreflect.FieldDescriptor) bool {
+	for _, v := range r.vars {
+		if v.field == fd {
+			return true
+		}
+	}
+	return false
+}
+
+// queryField finds the scalar field a query parameter sets, by proto or
+// JSON name.
+func queryField(md protoreflect.MessageDescriptor, key string) protoreflect.FieldDescriptor {
+	fd := md.Fields().ByName(protoreflect.Name(key))
+	if fd == nil {
+		fd = md.Fields().ByJSONName(key)
+	}
+	if fd == nil || fd.Message() != nil || fd.IsMap() {
+		return nil
+	}
+	return fd
+}
+
+func setField(msg protoreflect.Message, fd protoreflect.FieldDescriptor, values []string) error {
+	if !fd.IsList() {
+		v, err := parseScalar(fd, values[len(values)-1])
+		if err != nil {
+			return err
+		}
+		msg.Set(fd, v)
+		return nil
+	}
+	list := msg.Mutable(fd).List()
+	for _, s := range values {
+		v, err := parseScalar(fd, s)
+		if err != nil {
+			return err
+		}
+		list.Append(v)
+	}
+	return nil
+}
+
+func parseScalar(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be true or false")
+		}
+		return protoreflect.ValueOfBool(b), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be an integer")
+		}
+		return protoreflect.ValueOfInt32(int32(n)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be an integer")
+		}
+		return protoreflect.ValueOfInt64(n), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a non-negative integer")
+		}
+		return protoreflect.ValueOfUint32(uint32(n)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a non-negative integer")
+		}
+		return protoreflect.ValueOfUint64(n), nil
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a number")
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("must be a number")
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		if n, err := strconv.ParseInt(s, 10, 32); err == nil && fd.Enum().Values().ByNumber(protoreflect.EnumNumber(n)) != nil {
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+		}
+		return protoreflect.Value{}, fmt.Errorf("is not a valid %s", fd.Enum().Name())
+	case protoreflect.BytesKind:
+		b, err := base64.URLEncoding.DecodeString(s)
+		if err != nil {
+			if b, err = base64.StdEncoding.DecodeString(s); err != nil {
+				return protoreflect.Value{}, fmt.Errorf("must be base64")
+			}
+		}
+		return protoreflect.ValueOfBytes(b), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("cannot be set from the query string")
+}
+
+// protoErrorText strips the "proto: " prefix of protojson errors.
+func protoErrorText(err error) string {
+	return strings.TrimSpace(strings.TrimPrefix(err.Error(), "proto:"))
+}
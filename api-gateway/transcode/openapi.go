@@ -0,0 +1,74 @@
+package transcode
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/best-microservice/api-gateway/openapi"
+)
+
+// Operation describes the route for the OpenAPI document: the body is the
+// request message, or its body field, without the path variables; other
+// scalar fields of GET and DELETE requests are query parameters.
+func (r Route) Operation() openapi.Operation {
+	service := r.desc.Parent().(protoreflect.ServiceDescriptor)
+	op := openapi.Operation{
+		Method: r.Method,
+		Path:   r.Path,
+		ID:     lowerFirst(string(r.desc.Name())),
+		Tag:    strings.TrimSuffix(string(service.Name()), "Service"),
+		Status: r.Status,
+	}
+
+	in := r.input.Zero().Interface()
+	switch r.body {
+	case "*":
+		op.Request = in
+		for _, v := range r.vars {
+			op.RequestOmit = append(op.RequestOmit, string(v.field.Name()))
+		}
+	case "":
+		fields := r.input.Descriptor().Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if r.isPathField(fd) || fd.Message() != nil || fd.IsMap() {
+				continue
+			}
+			op.Params = append(op.Params, openapi.Param{Name: string(fd.Name()), In: "query", Type: queryType(fd), Repeated: fd.IsList()})
+		}
+	default:
+		fd := r.input.Descriptor().Fields().ByName(protoreflect.Name(r.body))
+		op.Request = in.ProtoReflect().Get(fd).Message().Interface()
+	}
+
+	if r.Status != http.StatusNoContent {
+		op.Response = r.output.Zero().Interface()
+	}
+	return op
+}
+
+func queryType(fd protoreflect.FieldDescriptor) string {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return "boolean"
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return "integer"
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+func lowerFirst(s string) string {
+	for i, c := range s {
+		return string(unicode.ToLower(c)) + s[i+len(string(c)):]
+	}
+	return s
+}
@@ -0,0 +1,167 @@
+// Package transcode serves gRPC methods annotated with google.api.http rules
+// as REST routes. Path variables, query parameters and the JSON body fill
+// the request message; the response message is written as JSON with the
+// proto field names, so every route names its fields the way the .proto
+// files do.
+package transcode
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Route is an HTTP binding of a gRPC method.
+type Route struct {
+	Method     string // HTTP method
+	Path       string // gin route relative to the base path, e.g. /orders/:id
+	FullMethod string // gRPC method, e.g. /order.OrderService/GetOrder
+	// Status is the status of successful responses; 204 No Content drops
+	// the response body.
+	Status int
+
+	desc   protoreflect.MethodDescriptor
+	input  protoreflect.MessageType
+	output protoreflect.MessageType
+	body   string // "", "*" or the request field holding the body
+	vars   []pathVar
+}
+
+// pathVar binds a gin path parameter to a request field.
+type pathVar struct {
+	param string
+	field protoreflect.FieldDescriptor
+}
+
+// Routes returns the routes of the annotated methods of a service. Every
+// path must begin with base, which is stripped so the routes can be added
+// to a router group. The generated Go types of the messages must be linked
+// into the binary.
+func Routes(service protoreflect.ServiceDescriptor, base string) ([]Route, error) {
+	var routes []Route
+	methods := service.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if !ok || rule == nil {
+			continue
+		}
+		for _, r := range append([]*annotations.HttpRule{rule}, rule.AdditionalBindings...) {
+			route, err := newRoute(md, r, base)
+			if err != nil {
+				return nil, fmt.Errorf("transcode: %s: %w", md.FullName(), err)
+			}
+			routes = append(routes, route)
+		}
+	}
+	return routes, nil
+}
+
+func newRoute(md protoreflect.MethodDescriptor, rule *annotations.HttpRule, base string) (Route, error) {
+	var method, template string
+	switch p := rule.Pattern.(type) {
+	case *annotations.HttpRule_Get:
+		method, template = http.MethodGet, p.Get
+	case *annotations.HttpRule_Post:
+		method, template = http.MethodPost, p.Post
+	case *annotations.HttpRule_Put:
+		method, template = http.MethodPut, p.Put
+	case *annotations.HttpRule_Patch:
+		method, template = http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Delete:
+		method, template = http.MethodDelete, p.Delete
+	default:
+		return Route{}, fmt.Errorf("unsupported http rule %v", rule)
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return Route{}, fmt.Errorf("streaming methods cannot be transcoded")
+	}
+
+	input, err := protoregistry.GlobalTypes.FindMessageByName(md.Input().FullName())
+	if err != nil {
+		return Route{}, err
+	}
+	output, err := protoregistry.GlobalTypes.FindMessageByName(md.Output().FullName())
+	if err != nil {
+		return Route{}, err
+	}
+
+	rel, ok := strings.CutPrefix(template, base)
+	if !ok || (rel != "" && rel[0] != '/') {
+		return Route{}, fmt.Errorf("path %s is not below %s", template, base)
+	}
+	path, vars, err := ginPath(rel, md.Input())
+	if err != nil {
+		return Route{}, err
+	}
+
+	if rule.Body != "" && rule.Body != "*" {
+		fd := md.Input().Fields().ByName(protoreflect.Name(rule.Body))
+		if fd == nil || fd.Message() == nil || fd.IsList() {
+			return Route{}, fmt.Errorf("body %q is not a message field", rule.Body)
+		}
+	}
+
+	return Route{
+		Method:     method,
+		Path:       path,
+		FullMethod: fmt.Sprintf("/%s/%s", md.Parent().FullName(), md.Name()),
+		Status:     http.StatusOK,
+		desc:       md,
+		input:      input,
+		output:     output,
+		body:       rule.Body,
+		vars:       vars,
+	}, nil
+}
+
+// ginPath converts a path template into a gin route. Gin needs routes that
+// share a prefix to name their parameters alike, so the names follow the
+// gateway's convention instead of the bound fields: the first parameter is
+// :id, later ones are named after the collection before them, as in
+// /users/:id/addresses/:address_id.
+func ginPath(template string, input protoreflect.MessageDescriptor) (string, []pathVar, error) {
+	segments := strings.Split(template, "/")
+	var vars []pathVar
+	for i, seg := range segments {
+		if !strings.HasPrefix(seg, "{") {
+			if strings.ContainsAny(seg, "{}*:") {
+				return "", nil, fmt.Errorf("unsupported path segment %q", seg)
+			}
+			continue
+		}
+		name, ok := strings.CutSuffix(seg[1:], "}")
+		if !ok || strings.ContainsAny(name, "=.*") {
+			return "", nil, fmt.Errorf("unsupported path variable %q", seg)
+		}
+		fd := input.Fields().ByName(protoreflect.Name(name))
+		if fd == nil || fd.Kind() != protoreflect.StringKind || fd.IsList() {
+			return "", nil, fmt.Errorf("path variable %s is not a string field of %s", name, input.FullName())
+		}
+
+		param := "id"
+		if len(vars) > 0 {
+			// earlier variables have already been rewritten to :param
+			if i == 0 || segments[i-1] == "" || strings.HasPrefix(segments[i-1], ":") {
+				return "", nil, fmt.Errorf("path variable %s does not follow a collection", name)
+			}
+			param = singular(segments[i-1]) + "_id"
+		}
+		segments[i] = ":" + param
+		vars = append(vars, pathVar{param: param, field: fd})
+	}
+	return strings.Join(segments, "/"), vars, nil
+}
+
+func singular(collection string) string {
+	collection = strings.ReplaceAll(collection, "-", "_")
+	if strings.HasSuffix(collection, "sses") {
+		return strings.TrimSuffix(collection, "es")
+	}
+	return strings.TrimSuffix(collection, "s")
+}
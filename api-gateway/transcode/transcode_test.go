@@ -0,0 +1,253 @@
+package transcode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/best-microservice/common/apierror"
+	orderpb "github.com/best-microservice/common/protos/order"
+	userpb "github.com/best-microservice/common/protos/user"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/best-microservice/api-gateway/problem"
+)
+
+// recordingConn answers every call with reply or err and keeps the request.
+type recordingConn struct {
+	method string
+	in     proto.Message
+	reply  proto.Message
+	err    error
+}
+
+func (c *recordingConn) Invoke(_ context.Context, method string, args, reply interface{}, _ ...grpc.CallOption) error {
+	c.method, c.in = method, args.(proto.Message)
+	if c.err != nil {
+		return c.err
+	}
+	if c.reply != nil {
+		proto.Merge(reply.(proto.Message), c.reply)
+	}
+	return nil
+}
+
+func (c *recordingConn) NewStream(context.Context, *grpc.StreamDesc, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+	panic("not used")
+}
+
+func routesOf(t *testing.T, service protoreflect.ServiceDescriptor) map[string]Route {
+	t.Helper()
+	rs, err := Routes(service, "/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := map[string]Route{}
+	for _, r := range rs {
+		routes[r.FullMethod] = r
+	}
+	return routes
+}
+
+func userRoutes(t *testing.T) map[string]Route {
+	return routesOf(t, userpb.File_user_proto.Services().ByName("UserService"))
+}
+
+func TestRoutes(t *testing.T) {
+	routes := userRoutes(t)
+	for name, r := range routesOf(t, orderpb.File_order_proto.Services().ByName("OrderService")) {
+		routes[name] = r
+	}
+
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{userpb.UserService_CreateUser_FullMethodName, "POST /users"},
+		{userpb.UserService_GetUser_FullMethodName, "GET /users/:id"},
+		{userpb.UserService_AddAddress_FullMethodName, "POST /users/:id/addresses"},
+		{userpb.UserService_DeleteAddress_FullMethodName, "DELETE /users/:id/addresses/:address_id"},
+		{orderpb.OrderService_UpdateShipmentStatus_FullMethodName, "PATCH /orders/:id/shipments/:shipment_id"},
+		{orderpb.OrderService_ListShippingMethods_FullMethodName, "GET /shipping-methods"},
+	}
+	for _, tt := range tests {
+		r, ok := routes[tt.method]
+		if !ok {
+			t.Errorf("%s has no route", tt.method)
+			continue
+		}
+		if got := r.Method + " " + r.Path; got != tt.want {
+			t.Errorf("%s: route = %s, want %s", tt.method, got, tt.want)
+		}
+	}
+	if _, ok := routes[userpb.UserService_GetAddress_FullMethodName]; ok {
+		t.Error("GetAddress has no http rule but got a route")
+	}
+}
+
+func TestGinPathRejects(t *testing.T) {
+	input := (&userpb.DeleteAddressRequest{}).ProtoReflect().Descriptor()
+	for _, template := range []string{
+		"/users/{user_id=*}",    // variable patterns
+		"/users/{user_id}:undo", // custom verbs
+		"/users/*",              // wildcards
+		"/users/{missing}",      // unknown fields
+		"/users/{user_id}/{id}", // variables without a collection before them
+		"/users/{user_id}/addresses/{user.id}",
+	} {
+		if _, _, err := ginPath(template, input); err == nil {
+			t.Errorf("ginPath(%q) succeeded", template)
+		}
+	}
+}
+
+func serve(t *testing.T, route Route, conn *recordingConn, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(route.Method, "/api/v1"+route.Path, route.Handler(conn))
+
+	var req *http.Request
+	if body != "" {
+		req = httptest.NewRequest(method, target, strings.NewReader(body))
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandlerBody(t *testing.T) {
+	routes := userRoutes(t)
+
+	t.Run("whole message", func(t *testing.T) {
+		conn := &recordingConn{reply: &userpb.UserResponse{Id: "u1", Name: "Ada"}}
+		w := serve(t, routes[userpb.UserService_CreateUser_FullMethodName], conn, http.MethodPost,
+			"/api/v1/users?name=ignored", `{"name":"Ada","email":"ada@example.com","password":"secret","unknown":1}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+		in := conn.in.(*userpb.CreateUserRequest)
+		if in.Name != "Ada" || in.Email != "ada@example.com" || in.Password != "secret" {
+			t.Errorf("request = %v", in)
+		}
+		var resp map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp["id"] != "u1" || resp["name"] != "Ada" {
+			t.Errorf("response = %s", w.Body)
+		}
+		if _, ok := resp["email_verified"]; !ok {
+			t.Errorf("response %s is missing unpopulated fields", w.Body)
+		}
+	})
+
+	t.Run("single field", func(t *testing.T) {
+		conn := &recordingConn{}
+		w := serve(t, routes[userpb.UserService_AddAddress_FullMethodName], conn, http.MethodPost,
+			"/api/v1/users/u1/addresses?user_id=u2", `{"city":"Lisbon","country":"PT"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+		in := conn.in.(*userpb.AddAddressRequest)
+		if in.UserId != "u1" || in.Address.GetCity() != "Lisbon" || in.Address.GetCountry() != "PT" {
+			t.Errorf("request = %v", in)
+		}
+	})
+
+	t.Run("path variables win over the body", func(t *testing.T) {
+		conn := &recordingConn{}
+		serve(t, routes[userpb.UserService_ChangePassword_FullMethodName], conn, http.MethodPut,
+			"/api/v1/users/u1/password", `{"user_id":"u2","current_password":"a","new_password":"b"}`)
+		in := conn.in.(*userpb.ChangePasswordRequest)
+		if in.UserId != "u1" || in.NewPassword != "b" {
+			t.Errorf("request = %v", in)
+		}
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		conn := &recordingConn{}
+		w := serve(t, routes[userpb.UserService_CreateUser_FullMethodName], conn, http.MethodPost,
+			"/api/v1/users", `{"name":42}`)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), apierror.ReasonMalformedRequest) {
+			t.Errorf("response = %d %s", w.Code, w.Body)
+		}
+		if conn.in != nil {
+			t.Error("the backend was called")
+		}
+	})
+}
+
+func TestHandlerQuery(t *testing.T) {
+	route := routesOf(t, orderpb.File_order_proto.Services().ByName("OrderService"))[orderpb.OrderService_GetUserOrders_FullMethodName]
+
+	t.Run("scalars and lists", func(t *testing.T) {
+		conn := &recordingConn{}
+		w := serve(t, route, conn, http.MethodGet,
+			"/api/v1/users/u1/orders?limit=5&offset=10&expand=items&expand=shipments&user_id=u2&unknown=x", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+		in := conn.in.(*orderpb.GetUserOrdersRequest)
+		if in.UserId != "u1" || in.Limit != 5 || in.Offset != 10 ||
+			len(in.Expand) != 2 || in.Expand[0] != "items" || in.Expand[1] != "shipments" {
+			t.Errorf("request = %v", in)
+		}
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		conn := &recordingConn{}
+		w := serve(t, route, conn, http.MethodGet, "/api/v1/users/u1/orders?limit=many", "")
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+		var p problem.Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Code != apierror.ReasonInvalidArgument || len(p.Errors) != 1 ||
+			p.Errors[0] != (problem.FieldError{Field: "limit", Message: "must be an integer"}) {
+			t.Errorf("problem = %+v", p)
+		}
+		if conn.in != nil {
+			t.Error("the backend was called")
+		}
+	})
+}
+
+func TestHandlerResponse(t *testing.T) {
+	route := userRoutes(t)[userpb.UserService_DeleteAddress_FullMethodName]
+
+	t.Run("no content", func(t *testing.T) {
+		route := route
+		route.Status = http.StatusNoContent
+		conn := &recordingConn{}
+		w := serve(t, route, conn, http.MethodDelete, "/api/v1/users/u1/addresses/a1", "")
+		if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+			t.Errorf("response = %d %q", w.Code, w.Body)
+		}
+		in := conn.in.(*userpb.DeleteAddressRequest)
+		if in.UserId != "u1" || in.Id != "a1" {
+			t.Errorf("request = %v", in)
+		}
+		if conn.method != userpb.UserService_DeleteAddress_FullMethodName {
+			t.Errorf("method = %s", conn.method)
+		}
+	})
+
+	t.Run("backend error", func(t *testing.T) {
+		conn := &recordingConn{err: apierror.NotFound(apierror.ReasonAddressNotFound, "address", "a1")}
+		w := serve(t, route, conn, http.MethodDelete, "/api/v1/users/u1/addresses/a1", "")
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), apierror.ReasonAddressNotFound) {
+			t.Errorf("response = %d %s", w.Code, w.Body)
+		}
+	})
+}
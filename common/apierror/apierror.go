@@ -0,0 +1,106 @@
+// Package apierror builds gRPC errors carrying google.rpc.Status details, so
+// that clients get a stable reason code, the offending fields and retry
+// hints instead of parsing messages. The gateway turns these details into
+// problem+json responses.
+package apierror
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Detail adds a detail message to an error built by New.
+type Detail func(*details)
+
+type details struct {
+	metadata   map[string]string
+	violations []*errdetails.BadRequest_FieldViolation
+	retryDelay time.Duration
+}
+
+// WithMetadata attaches a key/value pair to the ErrorInfo, e.g. the ID of
+// the resource that was not found.
+func WithMetadata(key, value string) Detail {
+	return func(d *details) {
+		if d.metadata == nil {
+			d.metadata = map[string]string{}
+		}
+		d.metadata[key] = value
+	}
+}
+
+// WithFieldViolation names a request field and what is wrong with it.
+func WithFieldViolation(field, description string) Detail {
+	return func(d *details) {
+		d.violations = append(d.violations, &errdetails.BadRequest_FieldViolation{Field: field, Description: description})
+	}
+}
+
+// WithRetryDelay tells the client to wait at least delay before retrying.
+func WithRetryDelay(delay time.Duration) Detail {
+	return func(d *details) { d.retryDelay = delay }
+}
+
+// New returns a status error with an ErrorInfo holding reason, one of the
+// Reason constants. message is shown to API clients and must not contain
+// internal error text. The ErrorInfo domain is filled in by the server
+// interceptor.
+func New(code codes.Code, reason, message string, opts ...Detail) error {
+	var d details
+	for _, opt := range opts {
+		opt(&d)
+	}
+
+	st := status.New(code, message)
+	msgs := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: reason, Metadata: d.metadata}}
+	if len(d.violations) > 0 {
+		msgs = append(msgs, &errdetails.BadRequest{FieldViolations: d.violations})
+	}
+	if d.retryDelay > 0 {
+		msgs = append(msgs, &errdetails.RetryInfo{RetryDelay: durationpb.New(d.retryDelay)})
+	}
+	if withDetails, err := st.WithDetails(msgs...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// Invalid returns an InvalidArgument error for a single bad field.
+func Invalid(field, description string) error {
+	return New(codes.InvalidArgument, ReasonInvalidArgument, field+" "+description,
+		WithFieldViolation(field, description))
+}
+
+// NotFound returns a NotFound error for the resource identified by id.
+func NotFound(reason, resource, id string) error {
+	return New(codes.NotFound, reason, resource+" not found", WithMetadata("id", id))
+}
+
+// Unavailable reports that a dependency could not be reached in time and
+// suggests retrying after delay.
+func Unavailable(message string, delay time.Duration) error {
+	return New(codes.Unavailable, ReasonUnavailable, message, WithRetryDelay(delay))
+}
+
+// Reason returns the ErrorInfo reason of err, or "" if it has none.
+func Reason(err error) string {
+	if info := ErrorInfo(status.Convert(err)); info != nil {
+		return info.Reason
+	}
+	return ""
+}
+
+// ErrorInfo returns the ErrorInfo detail of st, if any.
+func ErrorInfo(st *status.Status) *errdetails.ErrorInfo {
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
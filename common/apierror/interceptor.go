@@ -0,0 +1,88 @@
+package apierror
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// UnaryServerInterceptor makes sure every error leaving the server is a
+// status with an ErrorInfo. Errors that aren't statuses, and Internal or
+// Unknown statuses without a reason, may contain database or driver text:
+// they are logged and replaced by a generic Internal error. domain, the
+// service name, is set on every ErrorInfo that lacks one.
+func UnaryServerInterceptor(domain string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			err = normalize(ctx, domain, info.FullMethod, err)
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func StreamServerInterceptor(domain string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, ss)
+		if err != nil {
+			err = normalize(ss.Context(), domain, info.FullMethod, err)
+		}
+		return err
+	}
+}
+
+func normalize(ctx context.Context, domain, method string, err error) error {
+	st, ok := status.FromError(err)
+	if ok && ErrorInfo(st) != nil {
+		return withDomain(st, domain)
+	}
+
+	switch {
+	case !ok, st.Code() == codes.Internal, st.Code() == codes.Unknown:
+		slog.ErrorContext(ctx, "internal error", "method", method, "error", err)
+		return withDomain(status.Convert(New(codes.Internal, ReasonInternal, "internal error")), domain)
+	case st.Code() == codes.Canceled || st.Code() == codes.DeadlineExceeded:
+		return err
+	default:
+		// A plain status from a library or interceptor, e.g. mtls: keep the
+		// message and derive the reason from the code.
+		return withDomain(status.Convert(New(st.Code(), CodeReason(st.Code()), st.Message())), domain)
+	}
+}
+
+// withDomain sets the ErrorInfo domain of st when it is empty.
+func withDomain(st *status.Status, domain string) error {
+	pb := st.Proto()
+	for i, detail := range pb.Details {
+		var info errdetails.ErrorInfo
+		if detail.UnmarshalTo(&info) != nil || info.Domain != "" {
+			continue
+		}
+		info.Domain = domain
+		if updated, err := anypb.New(&info); err == nil {
+			pb.Details[i] = updated
+		}
+	}
+	return status.ErrorProto(pb)
+}
+
+// CodeReason spells a code the way reasons are written, e.g. NOT_FOUND. It
+// is the reason of errors that carry no ErrorInfo.
+func CodeReason(code codes.Code) string {
+	var b strings.Builder
+	for i, r := range code.String() {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,110 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    codes.Code
+		wantReason  string
+		wantMessage string
+		wantDomain  string
+	}{
+		{
+			name:        "service error gets the domain",
+			err:         NotFound(ReasonUserNotFound, "user", "u1"),
+			wantCode:    codes.NotFound,
+			wantReason:  ReasonUserNotFound,
+			wantMessage: "user not found",
+			wantDomain:  "user-service",
+		},
+		{
+			name:        "plain error is hidden",
+			err:         errors.New("pq: duplicate key value violates unique constraint"),
+			wantCode:    codes.Internal,
+			wantReason:  ReasonInternal,
+			wantMessage: "internal error",
+			wantDomain:  "user-service",
+		},
+		{
+			name:        "internal status without reason is hidden",
+			err:         status.Error(codes.Internal, "dial tcp 10.0.0.5:5432: connection refused"),
+			wantCode:    codes.Internal,
+			wantReason:  ReasonInternal,
+			wantMessage: "internal error",
+			wantDomain:  "user-service",
+		},
+		{
+			name:        "plain status gets a reason from its code",
+			err:         status.Error(codes.PermissionDenied, "client not allowed"),
+			wantCode:    codes.PermissionDenied,
+			wantReason:  "PERMISSION_DENIED",
+			wantMessage: "client not allowed",
+			wantDomain:  "user-service",
+		},
+		{
+			name:        "deadline passes through",
+			err:         status.Error(codes.DeadlineExceeded, "context deadline exceeded"),
+			wantCode:    codes.DeadlineExceeded,
+			wantMessage: "context deadline exceeded",
+		},
+	}
+
+	interceptor := UnaryServerInterceptor("user-service")
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/GetUser"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := interceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+				return nil, tt.err
+			})
+			st := status.Convert(err)
+			if st.Code() != tt.wantCode || st.Message() != tt.wantMessage {
+				t.Fatalf("got %s %q, want %s %q", st.Code(), st.Message(), tt.wantCode, tt.wantMessage)
+			}
+			info := ErrorInfo(st)
+			if tt.wantReason == "" {
+				if info != nil {
+					t.Fatalf("unexpected ErrorInfo %v", info)
+				}
+				return
+			}
+			if info == nil {
+				t.Fatal("missing ErrorInfo")
+			}
+			if info.Reason != tt.wantReason || info.Domain != tt.wantDomain {
+				t.Errorf("ErrorInfo = %s/%s, want %s/%s", info.Reason, info.Domain, tt.wantReason, tt.wantDomain)
+			}
+		})
+	}
+}
+
+func TestDomainIsKept(t *testing.T) {
+	err := withDomain(status.Convert(New(codes.NotFound, ReasonProductNotFound, "product not found")), "product-service")
+	err = normalize(context.Background(), "order-service", "/order.OrderService/CreateOrder", err)
+	if info := ErrorInfo(status.Convert(err)); info == nil || info.Domain != "product-service" {
+		t.Errorf("ErrorInfo = %v, want the domain of the service that failed", info)
+	}
+}
+
+func TestCodeReason(t *testing.T) {
+	tests := map[codes.Code]string{
+		codes.NotFound:           "NOT_FOUND",
+		codes.DeadlineExceeded:   "DEADLINE_EXCEEDED",
+		codes.Unavailable:        "UNAVAILABLE",
+		codes.FailedPrecondition: "FAILED_PRECONDITION",
+	}
+	for code, want := range tests {
+		if got := CodeReason(code); got != want {
+			t.Errorf("CodeReason(%s) = %q, want %q", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package apierror
+
+// Reasons are the stable, machine-readable error codes of the API. They are
+// returned as ErrorInfo.reason by the services and as "code" in the
+// gateway's problem responses; clients may depend on them, so existing
+// values must not change.
+const (
+	ReasonInvalidArgument  = "INVALID_ARGUMENT"
+	ReasonInternal         = "INTERNAL"
+	ReasonUnavailable      = "UNAVAILABLE"
+	ReasonUnauthenticated  = "UNAUTHENTICATED"
+	ReasonPermissionDenied = "PERMISSION_DENIED"
+
+	// user-service
+	ReasonUserNotFound       = "USER_NOT_FOUND"
+	ReasonEmailTaken         = "EMAIL_ALREADY_EXISTS"
+	ReasonInvalidCredentials = "INVALID_CREDENTIALS"
+	ReasonAddressNotFound    = "ADDRESS_NOT_FOUND"
+	ReasonEmailNotVerified   = "EMAIL_NOT_VERIFIED"
+	ReasonInvalidToken       = "INVALID_TOKEN"
+	ReasonInvalidMFACode     = "INVALID_MFA_CODE"
+	ReasonMFAAlreadyEnabled  = "MFA_ALREADY_ENABLED"
+	ReasonMFANotEnrolled     = "MFA_NOT_ENROLLED"
+
+	// product-service
+	ReasonProductNotFound   = "PRODUCT_NOT_FOUND"
+	ReasonInsufficientStock = "INSUFFICIENT_STOCK"
+
+	// order-service
+	ReasonOrderNotFound          = "ORDER_NOT_FOUND"
+	ReasonInvalidStatus          = "INVALID_STATUS_TRANSITION"
+	ReasonShippingMethodNotFound = "SHIPPING_METHOD_NOT_FOUND"
+	ReasonShipmentNotFound       = "SHIPMENT_NOT_FOUND"
+	ReasonOrderNotInvoiceable    = "ORDER_NOT_INVOICEABLE"
+
+	// api-gateway
+	ReasonMalformedRequest    = "MALFORMED_REQUEST"
+	ReasonRouteNotFound       = "ROUTE_NOT_FOUND"
+	ReasonRateLimited         = "RATE_LIMITED"
+	ReasonInvalidIdempotency  = "INVALID_IDEMPOTENCY_KEY"
+	ReasonIdempotencyInFlight = "IDEMPOTENCY_KEY_IN_USE"
+	ReasonIdempotencyMismatch = "IDEMPOTENCY_KEY_REUSED"
+	ReasonQueryTooComplex     = "QUERY_TOO_COMPLEX"
+)
@@ -0,0 +1,307 @@
+// Package config loads typed service configuration from defaults, a YAML
+// file, environment variables and command line flags, in increasing order of
+// precedence, and validates it on startup.
+//
+// Configuration structs describe their sources with field tags:
+//
+//	yaml:"host"        key in the YAML file (gopkg.in/yaml.v3 semantics)
+//	env:"DB_HOST"      environment variable; on a struct field, a prefix
+//	                   prepended to the variables of its fields
+//	flag:"db-host"     command line flag; on a struct field, a prefix
+//	usage:"..."        flag help text
+//	secret:"true"      the value may instead be read from the file named by
+//	                   <ENV>_FILE or -<flag>-file, e.g. DB_PASSWORD_FILE
+//	validate:"required" the value must not be empty after loading
+//
+// Defaults are whatever the struct holds when it is passed to Load. Structs
+// implementing Validator are checked after the required fields.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Validator is implemented by configuration structs with constraints beyond
+// required fields.
+type Validator interface {
+	Validate() error
+}
+
+// field is a leaf setting discovered on the configuration struct.
+type field struct {
+	path   string // dotted YAML path, used in error messages
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+	tags   reflect.StructTag
+}
+
+// Load fills cfg, a pointer to a struct holding the defaults, from the
+// configured sources and returns the positional arguments left after the
+// flags. args are the command line arguments without the program name.
+// Besides the flags derived from cfg it understands:
+//
+//	-config FILE     YAML file to read (or CONFIG_FILE)
+//	-env-file FILE   dotenv file to read (or ENV_FILE)
+//
+// No dotenv file is read unless one is named, so a stray .env cannot change
+// the configuration. Its variables never override the real environment.
+func Load(name string, cfg interface{}, args []string) ([]string, error) {
+	root := reflect.ValueOf(cfg)
+	if root.Kind() != reflect.Pointer || root.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config: Load needs a pointer to a struct, got %T", cfg)
+	}
+
+	var fields []*field
+	collect(root.Elem(), "", "", "", &fields)
+
+	// Flags are parsed first so -config and -env-file can be honoured, but
+	// applied last so they take precedence over everything else.
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration `file`")
+	envFile := fs.String("env-file", os.Getenv("ENV_FILE"), "dotenv `file` to load into the environment")
+	flagValues := map[string]string{}
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		name := f.flag
+		fs.Func(name, f.usage, func(v string) error { flagValues[name] = v; return nil })
+		if f.secret {
+			fileFlag := name + "-file"
+			fs.Func(fileFlag, "read "+name+" from `file`", func(v string) error { flagValues[fileFlag] = v; return nil })
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := loadEnvFile(*envFile); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadYAML(*configFile, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, f := range fields {
+		if err := f.apply(flagValues); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return fs.Args(), validate(root.Elem(), fields)
+}
+
+// collect walks v and records every leaf field that has at least one source.
+func collect(v reflect.Value, path, envPrefix, flagPrefix string, out *[]*field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		key := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(sf.Name)
+		}
+		if path != "" {
+			key = path + "." + key
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}) {
+			collect(fv, key, envPrefix+sf.Tag.Get("env"), flagPrefix+sf.Tag.Get("flag"), out)
+			continue
+		}
+
+		f := &field{path: key, value: fv, tags: sf.Tag, usage: sf.Tag.Get("usage"), secret: sf.Tag.Get("secret") == "true"}
+		if env := sf.Tag.Get("env"); env != "" {
+			f.env = envPrefix + env
+		}
+		if fl := sf.Tag.Get("flag"); fl != "" {
+			f.flag = flagPrefix + fl
+		}
+		*out = append(*out, f)
+	}
+}
+
+// apply sets the field from the environment, then from flags. Secrets read
+// from files win over a plain value from the same source.
+func (f *field) apply(flagValues map[string]string) error {
+	if f.env != "" {
+		if v, ok := os.LookupEnv(f.env); ok {
+			if err := f.set(v, "$"+f.env); err != nil {
+				return err
+			}
+		}
+		if f.secret {
+			if path := os.Getenv(f.env + "_FILE"); path != "" {
+				if err := f.setFromFile(path, "$"+f.env+"_FILE"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if f.flag != "" {
+		if v, ok := flagValues[f.flag]; ok {
+			if err := f.set(v, "-"+f.flag); err != nil {
+				return err
+			}
+		}
+		if path, ok := flagValues[f.flag+"-file"]; ok && f.secret {
+			if err := f.setFromFile(path, "-"+f.flag+"-file"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *field) setFromFile(path, source string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %s (%s): %w", f.path, source, err)
+	}
+	return f.set(strings.TrimRight(string(data), "\r\n"), source)
+}
+
+func (f *field) set(raw, source string) error {
+	if err := setValue(f.value, raw); err != nil {
+		return fmt.Errorf("config: %s (%s): %w", f.path, source, err)
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var parts []string
+		for _, p := range strings.Split(raw, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				parts = append(parts, p)
+			}
+		}
+		v.Set(reflect.ValueOf(parts))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func loadEnvFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := godotenv.Load(path); err != nil {
+		return fmt.Errorf("config: env file: %w", err)
+	}
+	return nil
+}
+
+func loadYAML(path string, cfg interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// validate checks required fields, then every Validator in the tree.
+func validate(root reflect.Value, fields []*field) error {
+	var errs []error
+	for _, f := range fields {
+		if f.tags.Get("validate") == "required" && f.value.IsZero() {
+			errs = append(errs, fmt.Errorf("config: %s is required%s", f.path, f.hint()))
+		}
+	}
+	walkValidators(root, &errs)
+	return errors.Join(errs...)
+}
+
+func walkValidators(v reflect.Value, errs *[]error) {
+	for i := 0; i < v.NumField(); i++ {
+		if fv := v.Field(i); fv.Kind() == reflect.Struct && v.Type().Field(i).IsExported() {
+			walkValidators(fv, errs)
+		}
+	}
+	if val, ok := v.Addr().Interface().(Validator); ok {
+		if err := val.Validate(); err != nil {
+			*errs = append(*errs, fmt.Errorf("config: %w", err))
+		}
+	}
+}
+
+// hint tells the operator where the missing value can be set.
+func (f *field) hint() string {
+	var sources []string
+	if f.env != "" {
+		sources = append(sources, "$"+f.env)
+	}
+	if f.flag != "" {
+		sources = append(sources, "-"+f.flag)
+	}
+	if len(sources) == 0 {
+		return ""
+	}
+	return " (set " + strings.Join(sources, " or ") + ")"
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testDatabase struct {
+	Host     string `yaml:"host" env:"HOST" flag:"host" usage:"database host"`
+	Password string `yaml:"password" env:"PASSWORD" flag:"password" usage:"database password" secret:"true"`
+}
+
+type testConfig struct {
+	Addr     string        `yaml:"addr" env:"CFGTEST_ADDR" flag:"addr" usage:"listen address" validate:"required"`
+	Timeout  time.Duration `yaml:"timeout" env:"CFGTEST_TIMEOUT" flag:"timeout" usage:"timeout"`
+	Peers    []string      `yaml:"peers" env:"CFGTEST_PEERS" flag:"peers" usage:"peers"`
+	Database testDatabase  `yaml:"database" env:"CFGTEST_DB_" flag:"db-"`
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// unsetEnv clears the test variables for the duration of the test, including
+// ones a dotenv file sets while it runs.
+func unsetEnv(t *testing.T) {
+	for _, name := range []string{"CFGTEST_ADDR", "CFGTEST_TIMEOUT", "CFGTEST_PEERS", "CFGTEST_DB_HOST",
+		"CFGTEST_DB_PASSWORD", "CFGTEST_DB_PASSWORD_FILE", "CONFIG_FILE", "ENV_FILE"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := writeFile(t, dir, "config.yaml", "addr: yaml:1\ntimeout: 2s\ndatabase:\n  host: yaml-db\n  password: yaml-secret\n")
+	envFile := writeFile(t, dir, "test.env", "CFGTEST_ADDR=dotenv:1\nCFGTEST_DB_HOST=dotenv-db\n")
+	secretFile := writeFile(t, dir, "password", "file-secret\n")
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		want    testConfig
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			want: testConfig{Addr: "default:1", Timeout: time.Second},
+		},
+		{
+			name: "yaml over defaults",
+			args: []string{"-config", yamlFile},
+			want: testConfig{Addr: "yaml:1", Timeout: 2 * time.Second, Database: testDatabase{Host: "yaml-db", Password: "yaml-secret"}},
+		},
+		{
+			name: "environment over yaml",
+			env:  map[string]string{"CFGTEST_ADDR": "env:1", "CFGTEST_PEERS": "a, b,,c", "CFGTEST_DB_HOST": "env-db"},
+			args: []string{"-config", yamlFile},
+			want: testConfig{Addr: "env:1", Timeout: 2 * time.Second, Peers: []string{"a", "b", "c"},
+				Database: testDatabase{Host: "env-db", Password: "yaml-secret"}},
+		},
+		{
+			name: "flags over environment",
+			env:  map[string]string{"CFGTEST_ADDR": "env:1", "CFGTEST_TIMEOUT": "3s"},
+			args: []string{"-addr", "flag:1", "-db-host", "flag-db"},
+			want: testConfig{Addr: "flag:1", Timeout: 3 * time.Second, Database: testDatabase{Host: "flag-db"}},
+		},
+		{
+			name: "secret file over plain value",
+			env:  map[string]string{"CFGTEST_DB_PASSWORD": "env-secret", "CFGTEST_DB_PASSWORD_FILE": secretFile},
+			want: testConfig{Addr: "default:1", Timeout: time.Second, Database: testDatabase{Password: "file-secret"}},
+		},
+		{
+			name: "env file fills the environment",
+			args: []string{"-env-file", envFile},
+			want: testConfig{Addr: "dotenv:1", Timeout: time.Second, Database: testDatabase{Host: "dotenv-db"}},
+		},
+		{
+			name: "env file does not override the environment",
+			env:  map[string]string{"CFGTEST_ADDR": "env:1", "ENV_FILE": envFile},
+			want: testConfig{Addr: "env:1", Timeout: time.Second, Database: testDatabase{Host: "dotenv-db"}},
+		},
+		{
+			name:    "missing env file",
+			args:    []string{"-env-file", filepath.Join(dir, "missing.env")},
+			wantErr: "env file",
+		},
+		{
+			name:    "required field",
+			args:    []string{"-addr", ""},
+			wantErr: "addr is required (set $CFGTEST_ADDR or -addr)",
+		},
+		{
+			name:    "invalid value names its source",
+			env:     map[string]string{"CFGTEST_TIMEOUT": "soon"},
+			wantErr: "timeout ($CFGTEST_TIMEOUT)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg := testConfig{Addr: "default:1", Timeout: time.Second}
+			_, err := Load("test", &cfg, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Addr != tt.want.Addr || cfg.Timeout != tt.want.Timeout || cfg.Database != tt.want.Database ||
+				strings.Join(cfg.Peers, ",") != strings.Join(tt.want.Peers, ",") {
+				t.Errorf("Load() = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadIgnoresUnnamedEnvFile(t *testing.T) {
+	unsetEnv(t)
+	dir := t.TempDir()
+	writeFile(t, dir, ".env", "CFGTEST_ADDR=dotenv:1\n")
+	child := filepath.Join(dir, "service")
+	if err := os.Mkdir(child, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, wd := range []string{dir, child} {
+		t.Chdir(wd)
+		cfg := testConfig{Addr: "default:1"}
+		if _, err := Load("test", &cfg, nil); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Addr != "default:1" {
+			t.Errorf("in %s: Addr = %q, want the default; .env must only be read when named", wd, cfg.Addr)
+		}
+	}
+}
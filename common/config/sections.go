@@ -0,0 +1,105 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Database configures the Postgres connection and pool. Each service owns
+// its database, or at least its own Schema in a shared one.
+type Database struct {
+	Host            string        `yaml:"host" env:"HOST" flag:"host" usage:"database host" validate:"required"`
+	Port            int           `yaml:"port" env:"PORT" flag:"port" usage:"database port"`
+	User            string        `yaml:"user" env:"USER" flag:"user" usage:"database user" validate:"required"`
+	Password        string        `yaml:"password" env:"PASSWORD" flag:"password" usage:"database password" secret:"true"`
+	Name            string        `yaml:"name" env:"NAME" flag:"name" usage:"database name" validate:"required"`
+	Schema          string        `yaml:"schema" env:"SCHEMA" flag:"schema" usage:"schema owned by the service when sharing a database"`
+	SSLMode         string        `yaml:"sslmode" env:"SSLMODE" flag:"sslmode" usage:"disable, require, verify-ca or verify-full"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"MAX_OPEN_CONNS" flag:"max-open-conns" usage:"maximum open connections (0 = unlimited)"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"MAX_IDLE_CONNS" flag:"max-idle-conns" usage:"maximum idle connections"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"CONN_MAX_LIFETIME" flag:"conn-max-lifetime" usage:"maximum lifetime of a connection (0 = forever)"`
+}
+
+// DefaultDatabase returns the settings for a database on localhost. TLS is
+// required unless sslmode is explicitly disabled, e.g. for development.
+func DefaultDatabase() Database {
+	return Database{
+		Host:         "localhost",
+		Port:         5432,
+		User:         "postgres",
+		SSLMode:      "require",
+		MaxOpenConns: 20,
+		MaxIdleConns: 5,
+	}
+}
+
+// DSN returns the lib/pq connection URL. With a Schema the search path is
+// the schema followed by public, where extensions such as uuid-ossp live.
+func (d Database) DSN() string {
+	query := url.Values{"sslmode": {d.SSLMode}}
+	if d.Schema != "" {
+		query.Set("search_path", d.Schema+",public")
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password),
+		Host:     net.JoinHostPort(d.Host, strconv.Itoa(d.Port)),
+		Path:     "/" + d.Name,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// ConfigurePool applies the pool limits to db.
+func (d Database) ConfigurePool(db *sql.DB) {
+	db.SetMaxOpenConns(d.MaxOpenConns)
+	db.SetMaxIdleConns(d.MaxIdleConns)
+	db.SetConnMaxLifetime(d.ConnMaxLifetime)
+}
+
+func (d Database) Validate() error {
+	if d.Port <= 0 || d.Port > 65535 {
+		return fmt.Errorf("database.port %d is out of range", d.Port)
+	}
+	switch d.SSLMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		return fmt.Errorf("database.sslmode %q must be disable, require, verify-ca or verify-full", d.SSLMode)
+	}
+	return nil
+}
+
+// Logging configures the structured logger.
+type Logging struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"json or text"`
+}
+
+// DefaultLogging logs JSON at info level.
+func DefaultLogging() Logging {
+	return Logging{Level: "info", Format: "json"}
+}
+
+func (l Logging) Validate() error {
+	switch l.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("log.level %q must be debug, info, warn or error", l.Level)
+	}
+	if l.Format != "json" && l.Format != "text" {
+		return fmt.Errorf("log.format %q must be json or text", l.Format)
+	}
+	return nil
+}
+
+// ValidateAddr checks that addr is a host:port listen or dial address.
+func ValidateAddr(name, addr string) error {
+	if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+		return fmt.Errorf("%s %q is not a host:port address", name, addr)
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+// TxHandler processes an event inside the transaction that also records the
+// event as processed, so its database effects are applied exactly once.
+type TxHandler func(ctx context.Context, tx *sql.Tx, event Event) error
+
+// Subscriber is implemented by brokers that deliver events to handlers.
+type Subscriber interface {
+	Subscribe(eventType string, h Handler)
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler error as not worth retrying; the event is moved
+// to the dead-letter table right away.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// ConsumerConfig tunes retries of a consumer.
+type ConsumerConfig struct {
+	// MaxAttempts is how many times a handler runs before the event is dead-lettered.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry; it doubles on every attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+}
+
+// Consumer dispatches events to registered handlers with idempotency, retries
+// and a dead-letter table for events that keep failing.
+type Consumer struct {
+	db       *sql.DB
+	name     string
+	cfg      ConsumerConfig
+	handlers map[string]TxHandler
+}
+
+// NewConsumer creates a consumer. name identifies the consumer in the
+// processed_events and dead_letters tables.
+func NewConsumer(db *sql.DB, name string, cfg ConsumerConfig) *Consumer {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = 100 * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Second
+	}
+	return &Consumer{db: db, name: name, cfg: cfg, handlers: make(map[string]TxHandler)}
+}
+
+// Handle registers the handler for an event type.
+func (c *Consumer) Handle(eventType string, h TxHandler) {
+	c.handlers[eventType] = h
+}
+
+// Subscribe registers the consumer with a broker for every handled event type.
+func (c *Consumer) Subscribe(sub Subscriber) {
+	for eventType := range c.handlers {
+		sub.Subscribe(eventType, c.Consume)
+	}
+}
+
+// Consume processes one delivered event. Duplicates are skipped; failures are
+// retried with jittered exponential backoff and finally dead-lettered. Consume
+// only returns an error when the event could not be dead-lettered either, so
+// the publisher redelivers it later.
+func (c *Consumer) Consume(ctx context.Context, event Event) error {
+	h, ok := c.handlers[event.Type]
+	if !ok {
+		return nil
+	}
+
+	var err error
+	for attempt := 1; attempt <= c.cfg.MaxAttempts; attempt++ {
+		err = c.process(ctx, h, event)
+		if err == nil {
+			return nil
+		}
+
+		var perm permanentError
+		if errors.As(err, &perm) || attempt == c.cfg.MaxAttempts {
+			return c.deadLetter(ctx, event, err, attempt)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.retryDelay(attempt)):
+		}
+	}
+	return err
+}
+
+func (c *Consumer) process(ctx context.Context, h TxHandler, event Event) (err error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO processed_events (consumer, event_id, event_type, processed_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (consumer, event_id) DO NOTHING
+	`, c.name, event.ID, event.Type)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		// Already processed by an earlier delivery
+		return nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panic: %v", r)
+		}
+	}()
+	if err := h(ctx, tx, event); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// retryDelay returns the backoff before the next attempt with up to 50% jitter.
+func (c *Consumer) retryDelay(attempt int) time.Duration {
+	d := c.cfg.InitialBackoff << (attempt - 1)
+	if d <= 0 || d > c.cfg.MaxBackoff {
+		d = c.cfg.MaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (c *Consumer) deadLetter(ctx context.Context, event Event, cause error, attempts int) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.db.ExecContext(ctx, `
+		INSERT INTO dead_letters (consumer, event_id, event_type, event, error, attempts, failed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	`, c.name, event.ID, event.Type, data, cause.Error(), attempts)
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter event %s: %w", event.ID, err)
+	}
+
+	slog.ErrorContext(ctx, "event dead-lettered",
+		"consumer", c.name, "event_type", event.Type, "event_id", event.ID,
+		"attempts", attempts, "error", cause)
+	return nil
+}
+
+// DeadLetter is an event that a consumer gave up on.
+type DeadLetter struct {
+	ID         int64
+	Consumer   string
+	Event      Event
+	Error      string
+	Attempts   int
+	FailedAt   time.Time
+	ReplayedAt *time.Time
+}
+
+// ErrDeadLetterNotFound is returned when replaying an unknown dead letter.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetters lists this consumer's dead letters, newest first.
+func (c *Consumer) DeadLetters(ctx context.Context, limit, offset int, includeReplayed bool) ([]DeadLetter, int, error) {
+	var total int
+	err := c.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM dead_letters
+		WHERE consumer = $1 AND ($2 OR replayed_at IS NULL)
+	`, c.name, includeReplayed).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT id, consumer, event, error, attempts, failed_at, replayed_at
+		FROM dead_letters
+		WHERE consumer = $1 AND ($2 OR replayed_at IS NULL)
+		ORDER BY failed_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, c.name, includeReplayed, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var letters []DeadLetter
+	for rows.Next() {
+		dl, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		letters = append(letters, *dl)
+	}
+
+	return letters, total, rows.Err()
+}
+
+// Replay runs a dead-lettered event through its handler once more. On success
+// the dead letter is marked as replayed; on failure its error is updated.
+func (c *Consumer) Replay(ctx context.Context, id int64) (*DeadLetter, error) {
+	dl, err := scanDeadLetter(c.db.QueryRowContext(ctx, `
+		SELECT id, consumer, event, error, attempts, failed_at, replayed_at
+		FROM dead_letters
+		WHERE id = $1 AND consumer = $2
+	`, id, c.name))
+	if err == sql.ErrNoRows {
+		return nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	h, ok := c.handlers[dl.Event.Type]
+	if !ok {
+		return nil, fmt.Errorf("no handler for event type %s", dl.Event.Type)
+	}
+
+	dl.Attempts++
+	if perr := c.process(ctx, h, dl.Event); perr != nil {
+		dl.Error = perr.Error()
+		_, err := c.db.ExecContext(ctx,
+			`UPDATE dead_letters SET error = $2, attempts = $3 WHERE id = $1`, dl.ID, dl.Error, dl.Attempts)
+		if err != nil {
+			return nil, err
+		}
+		return dl, perr
+	}
+
+	now := time.Now().UTC()
+	dl.ReplayedAt = &now
+	_, err = c.db.ExecContext(ctx,
+		`UPDATE dead_letters SET replayed_at = $2, attempts = $3 WHERE id = $1`, dl.ID, now, dl.Attempts)
+	if err != nil {
+		return nil, err
+	}
+	return dl, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDeadLetter(row rowScanner) (*DeadLetter, error) {
+	var dl DeadLetter
+	var data []byte
+	var replayedAt sql.NullTime
+	if err := row.Scan(&dl.ID, &dl.Consumer, &data, &dl.Error, &dl.Attempts, &dl.FailedAt, &replayedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &dl.Event); err != nil {
+		return nil, err
+	}
+	if replayedAt.Valid {
+		dl.ReplayedAt = &replayedAt.Time
+	}
+	return &dl, nil
+}
@@ -0,0 +1,244 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestConsume(t *testing.T) {
+	event := Event{ID: "e1", Type: OrderCreated, Payload: json.RawMessage(`{}`), OccurredAt: time.Now()}
+	claim := func(mock sqlmock.Sqlmock, affected int64) {
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO processed_events").WithArgs("stock", "e1", OrderCreated).
+			WillReturnResult(sqlmock.NewResult(0, affected))
+	}
+	deadLettered := func(mock sqlmock.Sqlmock, attempts int) {
+		mock.ExpectExec("INSERT INTO dead_letters").
+			WithArgs("stock", "e1", OrderCreated, sqlmock.AnyArg(), sqlmock.AnyArg(), attempts).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+
+	tests := []struct {
+		name      string
+		handler   func(calls int) error
+		expect    func(mock sqlmock.Sqlmock)
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:    "first delivery",
+			handler: func(int) error { return nil },
+			expect: func(mock sqlmock.Sqlmock) {
+				claim(mock, 1)
+				mock.ExpectCommit()
+			},
+			wantCalls: 1,
+		},
+		{
+			name:    "duplicate delivery",
+			handler: func(int) error { return nil },
+			expect: func(mock sqlmock.Sqlmock) {
+				claim(mock, 0)
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name: "retried until it succeeds",
+			handler: func(calls int) error {
+				if calls < 2 {
+					return errors.New("deadlock detected")
+				}
+				return nil
+			},
+			expect: func(mock sqlmock.Sqlmock) {
+				claim(mock, 1)
+				mock.ExpectRollback()
+				claim(mock, 1)
+				mock.ExpectCommit()
+			},
+			wantCalls: 2,
+		},
+		{
+			name:    "dead-lettered after the last attempt",
+			handler: func(int) error { return errors.New("deadlock detected") },
+			expect: func(mock sqlmock.Sqlmock) {
+				for range 3 {
+					claim(mock, 1)
+					mock.ExpectRollback()
+				}
+				deadLettered(mock, 3)
+			},
+			wantCalls: 3,
+		},
+		{
+			name:    "permanent errors are not retried",
+			handler: func(int) error { return Permanent(errors.New("unknown product")) },
+			expect: func(mock sqlmock.Sqlmock) {
+				claim(mock, 1)
+				mock.ExpectRollback()
+				deadLettered(mock, 1)
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "panics count as failures",
+			handler: func(calls int) error {
+				if calls == 1 {
+					panic("nil map")
+				}
+				return nil
+			},
+			expect: func(mock sqlmock.Sqlmock) {
+				claim(mock, 1)
+				mock.ExpectRollback()
+				claim(mock, 1)
+				mock.ExpectCommit()
+			},
+			wantCalls: 2,
+		},
+		{
+			name:    "redelivered when it cannot be dead-lettered",
+			handler: func(int) error { return Permanent(errors.New("unknown product")) },
+			expect: func(mock sqlmock.Sqlmock) {
+				claim(mock, 1)
+				mock.ExpectRollback()
+				mock.ExpectExec("INSERT INTO dead_letters").WillReturnError(errors.New("connection reset"))
+			},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			tt.expect(mock)
+
+			c := NewConsumer(db, "stock", ConsumerConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+			calls := 0
+			c.Handle(OrderCreated, func(_ context.Context, _ *sql.Tx, _ Event) error {
+				calls++
+				return tt.handler(calls)
+			})
+			c.Handle(OrderStatusChanged, func(context.Context, *sql.Tx, Event) error {
+				t.Error("handler of another event type called")
+				return nil
+			})
+
+			err = c.Consume(context.Background(), event)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Consume() = %v, want error %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler called %d times, want %d", calls, tt.wantCalls)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestConsumeUnhandledType(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	c := NewConsumer(db, "stock", ConsumerConfig{})
+	if err := c.Consume(context.Background(), Event{ID: "e1", Type: UserRegistered}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	event := Event{ID: "e1", Type: OrderCreated, Payload: json.RawMessage(`{}`), OccurredAt: time.Now().UTC()}
+	data, _ := json.Marshal(event)
+	letter := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery("FROM dead_letters").WithArgs(int64(7), "stock").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "consumer", "event", "error", "attempts", "failed_at", "replayed_at"}).
+				AddRow(7, "stock", data, "deadlock detected", 3, time.Now(), nil))
+	}
+	tests := []struct {
+		name         string
+		handlerErr   error
+		expect       func(mock sqlmock.Sqlmock)
+		wantErr      error
+		wantReplayed bool
+	}{
+		{
+			name: "handled now",
+			expect: func(mock sqlmock.Sqlmock) {
+				letter(mock)
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO processed_events").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectExec("UPDATE dead_letters SET replayed_at").WithArgs(int64(7), sqlmock.AnyArg(), 4).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantReplayed: true,
+		},
+		{
+			name:       "still failing",
+			handlerErr: errors.New("deadlock detected again"),
+			expect: func(mock sqlmock.Sqlmock) {
+				letter(mock)
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO processed_events").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectRollback()
+				mock.ExpectExec("UPDATE dead_letters SET error").WithArgs(int64(7), "deadlock detected again", 4).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantErr: errors.New("deadlock detected again"),
+		},
+		{
+			name: "unknown dead letter",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("FROM dead_letters").WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: ErrDeadLetterNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			tt.expect(mock)
+
+			c := NewConsumer(db, "stock", ConsumerConfig{})
+			c.Handle(OrderCreated, func(context.Context, *sql.Tx, Event) error { return tt.handlerErr })
+
+			dl, err := c.Replay(context.Background(), 7)
+			switch {
+			case tt.wantErr != nil:
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("Replay() = %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatal(err)
+			}
+			if tt.wantReplayed && (dl.ReplayedAt == nil || dl.Attempts != 4 || dl.Event.ID != "e1") {
+				t.Errorf("dead letter %+v, want it replayed on the fourth attempt", dl)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
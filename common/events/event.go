@@ -0,0 +1,106 @@
+// Package events defines the domain events published by the services and the
+// transactional outbox used to deliver them.
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types
+const (
+	UserRegistered         = "user.registered"
+	ProductCreated         = "product.created"
+	StockChanged           = "product.stock_changed"
+	StockReservationFailed = "product.stock_reservation_failed"
+	OrderCreated           = "order.created"
+	OrderStatusChanged     = "order.status_changed"
+)
+
+// Event is the envelope every domain event is published in.
+type Event struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Source        string          `json:"source"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+}
+
+// New creates an event of the given type for an aggregate, encoding payload as JSON.
+func New(eventType, source, aggregateType, aggregateID string, payload interface{}) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		ID:            uuid.New().String(),
+		Type:          eventType,
+		Source:        source,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       data,
+		OccurredAt:    time.Now().UTC(),
+	}, nil
+}
+
+// Decode unmarshals the event payload into v.
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+type UserRegisteredPayload struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+}
+
+type ProductCreatedPayload struct {
+	ProductID   string  `json:"product_id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	Stock       int     `json:"stock"`
+	Weight      float64 `json:"weight"`
+}
+
+type StockChangedPayload struct {
+	ProductID string `json:"product_id"`
+	Delta     int    `json:"delta"`
+	Stock     int    `json:"stock"`
+	Reason    string `json:"reason"`
+}
+
+// StockReservationFailedPayload reports an order whose stock could not be
+// reserved. None of its items are reserved then.
+type StockReservationFailedPayload struct {
+	OrderID   string `json:"order_id"`
+	ProductID string `json:"product_id"`
+	Reason    string `json:"reason"`
+}
+
+type OrderItemPayload struct {
+	ProductID string  `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	Price     float64 `json:"price"`
+}
+
+type OrderCreatedPayload struct {
+	OrderID string             `json:"order_id"`
+	UserID  string             `json:"user_id"`
+	Items   []OrderItemPayload `json:"items"`
+	Total   float64            `json:"total"`
+	Status  string             `json:"status"`
+}
+
+type OrderStatusChangedPayload struct {
+	OrderID   string             `json:"order_id"`
+	UserID    string             `json:"user_id"`
+	OldStatus string             `json:"old_status"`
+	NewStatus string             `json:"new_status"`
+	Items     []OrderItemPayload `json:"items"`
+}
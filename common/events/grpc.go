@@ -0,0 +1,216 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	eventspb "github.com/best-microservice/common/protos/events"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCPublisher delivers events to other services through their
+// EventService.Deliver RPC. An event only counts as published once every
+// target accepted it; targets that already got it see a duplicate on retry.
+type GRPCPublisher struct {
+	conns   []*grpc.ClientConn
+	clients []eventspb.EventServiceClient
+}
+
+// DialPublisher creates a publisher delivering to the services listening on
+// targets. Blank targets are ignored.
+func DialPublisher(targets []string, opts ...grpc.DialOption) (*GRPCPublisher, error) {
+	p := &GRPCPublisher{}
+	for _, target := range targets {
+		if target = strings.TrimSpace(target); target == "" {
+			continue
+		}
+		conn, err := grpc.NewClient(target, opts...)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("failed to dial event subscriber %s: %w", target, err)
+		}
+		p.conns = append(p.conns, conn)
+		p.clients = append(p.clients, eventspb.NewEventServiceClient(conn))
+	}
+	return p, nil
+}
+
+// Close closes the connections to all targets.
+func (p *GRPCPublisher) Close() error {
+	var errs []error
+	for _, conn := range p.conns {
+		errs = append(errs, conn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (p *GRPCPublisher) Publish(ctx context.Context, event Event) error {
+	msg := toProto(event)
+	var errs []error
+	for _, client := range p.clients {
+		if _, err := client.Deliver(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FanOut publishes every event to all publishers, failing if any of them fails.
+type FanOut []Publisher
+
+func (f FanOut) Publish(ctx context.Context, event Event) error {
+	var errs []error
+	for _, p := range f {
+		if err := p.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Server implements EventService: delivered events are published to the
+// local broker, and dead letters of the service's consumer can be inspected
+// and replayed. Without a consumer, as in the gateway, which only reacts to
+// events and keeps no dead letters, the dead letter RPCs are unimplemented.
+// Callers must present a verified client certificate; without mutual TLS
+// only calls from this host are served, see checkPeer.
+type Server struct {
+	eventspb.UnimplementedEventServiceServer
+	broker   *Broker
+	consumer *Consumer
+}
+
+func NewServer(broker *Broker, consumer *Consumer) *Server {
+	return &Server{broker: broker, consumer: consumer}
+}
+
+func (s *Server) Deliver(ctx context.Context, req *eventspb.Event) (*eventspb.DeliverResponse, error) {
+	if err := checkPeer(ctx); err != nil {
+		return nil, err
+	}
+	event, err := fromProto(req)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid event: %v", err)
+	}
+
+	if err := s.broker.Publish(ctx, event); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to deliver event: %v", err)
+	}
+	return &eventspb.DeliverResponse{}, nil
+}
+
+func (s *Server) ListDeadLetters(ctx context.Context, req *eventspb.ListDeadLettersRequest) (*eventspb.ListDeadLettersResponse, error) {
+	if err := checkPeer(ctx); err != nil {
+		return nil, err
+	}
+	if s.consumer == nil {
+		return s.UnimplementedEventServiceServer.ListDeadLetters(ctx, req)
+	}
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	letters, total, err := s.consumer.DeadLetters(ctx, int(req.Limit), int(req.Offset), req.IncludeReplayed)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list dead letters: %v", err)
+	}
+
+	res := &eventspb.ListDeadLettersResponse{Total: int32(total)}
+	for i := range letters {
+		res.DeadLetters = append(res.DeadLetters, deadLetterToProto(&letters[i]))
+	}
+	return res, nil
+}
+
+func (s *Server) ReplayDeadLetter(ctx context.Context, req *eventspb.ReplayDeadLetterRequest) (*eventspb.ReplayDeadLetterResponse, error) {
+	if err := checkPeer(ctx); err != nil {
+		return nil, err
+	}
+	if s.consumer == nil {
+		return s.UnimplementedEventServiceServer.ReplayDeadLetter(ctx, req)
+	}
+	dl, err := s.consumer.Replay(ctx, req.Id)
+	if err != nil {
+		if errors.Is(err, ErrDeadLetterNotFound) {
+			return nil, status.Error(codes.NotFound, "dead letter not found")
+		}
+		return nil, status.Errorf(codes.Aborted, "replay failed: %v", err)
+	}
+	return &eventspb.ReplayDeadLetterResponse{DeadLetter: deadLetterToProto(dl)}, nil
+}
+
+// checkPeer accepts callers that proved who they are with a client
+// certificate, which the mtls interceptor checks against the allowed
+// clients, and, when the server runs without mutual TLS, callers on this
+// host. Anyone else reaching the port could inject events or replay dead
+// letters.
+func checkPeer(ctx context.Context) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "unknown caller")
+	}
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+		return nil
+	}
+	if addr, ok := p.Addr.(*net.TCPAddr); ok && addr.IP.IsLoopback() {
+		return nil
+	}
+	return status.Error(codes.Unauthenticated, "events are only accepted over mutual TLS or from this host")
+}
+
+func toProto(e Event) *eventspb.Event {
+	return &eventspb.Event{
+		Id:            e.ID,
+		Type:          e.Type,
+		Source:        e.Source,
+		AggregateType: e.AggregateType,
+		AggregateId:   e.AggregateID,
+		Payload:       e.Payload,
+		OccurredAt:    e.OccurredAt.Format(time.RFC3339Nano),
+	}
+}
+
+func fromProto(e *eventspb.Event) (Event, error) {
+	if e.Id == "" || e.Type == "" {
+		return Event{}, fmt.Errorf("id and type are required")
+	}
+	occurredAt, err := time.Parse(time.RFC3339Nano, e.OccurredAt)
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid occurred_at: %w", err)
+	}
+	return Event{
+		ID:            e.Id,
+		Type:          e.Type,
+		Source:        e.Source,
+		AggregateType: e.AggregateType,
+		AggregateID:   e.AggregateId,
+		Payload:       e.Payload,
+		OccurredAt:    occurredAt,
+	}, nil
+}
+
+func deadLetterToProto(dl *DeadLetter) *eventspb.DeadLetter {
+	res := &eventspb.DeadLetter{
+		Id:       dl.ID,
+		Consumer: dl.Consumer,
+		Event:    toProto(dl.Event),
+		Error:    dl.Error,
+		Attempts: int32(dl.Attempts),
+		FailedAt: dl.FailedAt.Format(time.RFC3339),
+	}
+	if dl.ReplayedAt != nil {
+		res.ReplayedAt = dl.ReplayedAt.Format(time.RFC3339)
+	}
+	return res
+}
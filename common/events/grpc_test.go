@@ -0,0 +1,68 @@
+package events
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+
+	eventspb "github.com/best-microservice/common/protos/events"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestServerCallers(t *testing.T) {
+	remote := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 40000}
+	verified := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}}
+
+	tests := []struct {
+		name     string
+		peer     *peer.Peer
+		wantCode codes.Code
+	}{
+		{"loopback", &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000}}, codes.OK},
+		{"loopback IPv6", &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv6loopback, Port: 40000}}, codes.OK},
+		{"remote over mutual TLS", &peer.Peer{Addr: remote, AuthInfo: verified}, codes.OK},
+		{"remote over TLS without a client certificate", &peer.Peer{Addr: remote, AuthInfo: credentials.TLSInfo{}}, codes.Unauthenticated},
+		{"remote in plaintext", &peer.Peer{Addr: remote}, codes.Unauthenticated},
+		{"no peer", nil, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivered := 0
+			broker := NewBroker()
+			broker.Subscribe("*", func(context.Context, Event) error {
+				delivered++
+				return nil
+			})
+			ctx := context.Background()
+			if tt.peer != nil {
+				ctx = peer.NewContext(ctx, tt.peer)
+			}
+
+			_, err := NewServer(broker, nil).Deliver(ctx, &eventspb.Event{
+				Id: "e1", Type: ProductCreated, OccurredAt: time.Now().Format(time.RFC3339Nano),
+			})
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("Deliver() = %v, want %s", err, tt.wantCode)
+			}
+			if wantDelivered := map[bool]int{true: 1}[tt.wantCode == codes.OK]; delivered != wantDelivered {
+				t.Errorf("delivered %d events, want %d", delivered, wantDelivered)
+			}
+
+			// the dead letter RPCs are guarded the same way
+			_, err = NewServer(broker, nil).ReplayDeadLetter(ctx, &eventspb.ReplayDeadLetterRequest{Id: 1})
+			wantReplay := tt.wantCode
+			if wantReplay == codes.OK {
+				wantReplay = codes.Unimplemented
+			}
+			if got := status.Code(err); got != wantReplay {
+				t.Errorf("ReplayDeadLetter() = %v, want %s", err, wantReplay)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Execer is satisfied by *sql.Tx and *sqlx.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Write stores events in the outbox table. Pass the transaction that performs
+// the state change so the events are committed atomically with it.
+func Write(ctx context.Context, tx Execer, evts ...Event) error {
+	for _, e := range evts {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO outbox (id, source, event_type, aggregate_type, aggregate_id, payload, occurred_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, e.ID, e.Source, e.Type, e.AggregateType, e.AggregateID, []byte(e.Payload), e.OccurredAt)
+		if err != nil {
+			return fmt.Errorf("failed to write %s to outbox: %w", e.Type, err)
+		}
+	}
+	return nil
+}
+
+// RelayConfig tunes the outbox relay.
+type RelayConfig struct {
+	// Source restricts the relay to events written by one service.
+	Source string
+	// PollInterval is how often the outbox is checked for pending events.
+	PollInterval time.Duration
+	// BatchSize is the maximum number of events published per poll.
+	BatchSize int
+	// MaxBackoff caps the delay between retries of a failing event.
+	MaxBackoff time.Duration
+}
+
+// Relay publishes pending outbox events. Events are marked as published only
+// after the publisher accepted them, so delivery is at-least-once: consumers
+// must tolerate duplicates.
+type Relay struct {
+	db        *sql.DB
+	publisher Publisher
+	cfg       RelayConfig
+}
+
+func NewRelay(db *sql.DB, publisher Publisher, cfg RelayConfig) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	return &Relay{db: db, publisher: publisher, cfg: cfg}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := r.RelayOnce(ctx)
+			if err != nil {
+				slog.Error("outbox relay failed", "error", err)
+			}
+			// Keep draining while full batches are being published
+			if err != nil || n < r.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes one batch of due events and returns how many were
+// published. Rows are locked with SKIP LOCKED so several relay instances can
+// run side by side without publishing the same event concurrently.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, source, event_type, aggregate_type, aggregate_id, payload, occurred_at, attempts
+		FROM outbox
+		WHERE published_at IS NULL AND next_attempt_at <= NOW() AND ($1 = '' OR source = $1)
+		ORDER BY occurred_at, id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, r.cfg.Source, r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type pending struct {
+		event    Event
+		attempts int
+	}
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		var payload []byte
+		if err := rows.Scan(&p.event.ID, &p.event.Source, &p.event.Type, &p.event.AggregateType,
+			&p.event.AggregateID, &payload, &p.event.OccurredAt, &p.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		p.event.Payload = payload
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, p := range batch {
+		if err := r.publisher.Publish(ctx, p.event); err != nil {
+			delay := backoff(p.attempts+1, r.cfg.MaxBackoff)
+			if _, uerr := tx.ExecContext(ctx, `
+				UPDATE outbox
+				SET attempts = attempts + 1, last_error = $2, next_attempt_at = NOW() + $3 * INTERVAL '1 millisecond'
+				WHERE id = $1
+			`, p.event.ID, err.Error(), delay.Milliseconds()); uerr != nil {
+				return published, uerr
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE outbox SET published_at = NOW(), attempts = attempts + 1, last_error = NULL WHERE id = $1`,
+			p.event.ID); err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return published, nil
+}
+
+// backoff returns an exponential delay for the given attempt, capped at max.
+func backoff(attempt int, max time.Duration) time.Duration {
+	d := time.Second
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
@@ -0,0 +1,140 @@
+package events
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// recordingPublisher records published events and fails those in fail.
+type recordingPublisher struct {
+	fail      map[string]bool
+	published []string
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, event Event) error {
+	if p.fail[event.ID] {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event.ID)
+	return nil
+}
+
+func TestRelayOnce(t *testing.T) {
+	columns := []string{"id", "source", "event_type", "aggregate_type", "aggregate_id", "payload", "occurred_at", "attempts"}
+	pending := func(rows ...[]driver.Value) *sqlmock.Rows {
+		r := sqlmock.NewRows(columns)
+		for _, row := range rows {
+			r.AddRow(row...)
+		}
+		return r
+	}
+	e1 := []driver.Value{"e1", "order-service", OrderCreated, "order", "o1", []byte(`{}`), time.Now(), 0}
+	e2 := []driver.Value{"e2", "order-service", OrderCreated, "order", "o2", []byte(`{}`), time.Now(), 3}
+	published := regexp.QuoteMeta("UPDATE outbox SET published_at = NOW(), attempts = attempts + 1, last_error = NULL WHERE id = $1")
+
+	tests := []struct {
+		name          string
+		fail          map[string]bool
+		expect        func(mock sqlmock.Sqlmock)
+		wantCount     int
+		wantPublished []string
+		wantErr       bool
+	}{
+		{
+			name: "publishes due events and marks them",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("FOR UPDATE SKIP LOCKED").WithArgs("order-service", 10).WillReturnRows(pending(e1, e2))
+				mock.ExpectExec(published).WithArgs("e1").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(published).WithArgs("e2").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantCount:     2,
+			wantPublished: []string{"e1", "e2"},
+		},
+		{
+			name: "failed events are retried later",
+			fail: map[string]bool{"e2": true},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("FOR UPDATE SKIP LOCKED").WillReturnRows(pending(e1, e2))
+				mock.ExpectExec(published).WithArgs("e1").WillReturnResult(sqlmock.NewResult(0, 1))
+				// The fourth attempt of e2 waits 2^3 seconds
+				mock.ExpectExec("UPDATE outbox\\s+SET attempts = attempts \\+ 1, last_error").
+					WithArgs("e2", "broker unavailable", int64(8000)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantCount:     1,
+			wantPublished: []string{"e1"},
+		},
+		{
+			name: "nothing due",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("FOR UPDATE SKIP LOCKED").WillReturnRows(pending())
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "rows stay locked when marking fails",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("FOR UPDATE SKIP LOCKED").WillReturnRows(pending(e1))
+				mock.ExpectExec(published).WillReturnError(errors.New("connection reset"))
+				mock.ExpectRollback()
+			},
+			wantPublished: []string{"e1"},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			tt.expect(mock)
+
+			publisher := &recordingPublisher{fail: tt.fail}
+			relay := NewRelay(db, publisher, RelayConfig{Source: "order-service", BatchSize: 10, MaxBackoff: time.Minute})
+			n, err := relay.RelayOnce(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RelayOnce() error = %v, want error %v", err, tt.wantErr)
+			}
+			if n != tt.wantCount {
+				t.Errorf("published %d, want %d", n, tt.wantCount)
+			}
+			if len(publisher.published) != len(tt.wantPublished) {
+				t.Errorf("published %v, want %v", publisher.published, tt.wantPublished)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempt, time.Minute); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
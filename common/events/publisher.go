@@ -0,0 +1,58 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Publisher delivers events to a message broker.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Handler processes a delivered event. Returning an error signals that
+// delivery failed and the event should be retried.
+type Handler func(ctx context.Context, event Event) error
+
+// Broker is an in-process publisher for local use. Every handler subscribed
+// to an event type is invoked synchronously on publish; a failing handler
+// fails the publish so the outbox relay retries the event.
+type Broker struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewBroker() *Broker {
+	return &Broker{handlers: make(map[string][]Handler)}
+}
+
+// Subscribe registers h for eventType. Use "*" to receive every event.
+func (b *Broker) Subscribe(eventType string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], h)
+}
+
+func (b *Broker) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	handlers := append(append([]Handler(nil), b.handlers[event.Type]...), b.handlers["*"]...)
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		if err := h(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogPublisher writes events to the standard logger. It is useful when no
+// broker is configured and events only need to be observable.
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(ctx context.Context, event Event) error {
+	slog.InfoContext(ctx, "event", "event_id", event.ID, "event_type", event.Type,
+		"aggregate_type", event.AggregateType, "aggregate_id", event.AggregateID)
+	return nil
+}
@@ -0,0 +1,87 @@
+// Package health reports service health over the standard grpc.health.v1
+// protocol, based on database connectivity.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// DefaultInterval is how often the database is probed when no interval is given.
+const DefaultInterval = 5 * time.Second
+
+// Checker keeps the serving status of a gRPC health server in sync with the
+// reachability of the database.
+type Checker struct {
+	server   *health.Server
+	db       *sql.DB
+	services []string
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// Register installs the grpc.health.v1 service on s and returns a Checker
+// reporting for the overall server ("") and each of the named services, e.g.
+// "user.UserService". Everything starts NOT_SERVING until the first probe.
+func Register(s *grpc.Server, db *sql.DB, services ...string) *Checker {
+	c := &Checker{
+		server:   health.NewServer(),
+		db:       db,
+		services: append([]string{""}, services...),
+		interval: DefaultInterval,
+		timeout:  2 * time.Second,
+	}
+	c.set(healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(s, c.server)
+	return c
+}
+
+// Run probes the database every interval until ctx is cancelled.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		status := c.probe(ctx)
+		if status != last {
+			slog.Info("health status changed", "status", status.String())
+			last = status
+		}
+		c.set(status)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown reports NOT_SERVING for every service and ignores later updates,
+// so load balancers drain the instance before it stops.
+func (c *Checker) Shutdown() {
+	c.server.Shutdown()
+}
+
+func (c *Checker) probe(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	if err := c.db.PingContext(ctx); err != nil {
+		slog.Warn("database health check failed", "error", err)
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
+
+func (c *Checker) set(status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, name := range c.services {
+		c.server.SetServingStatus(name, status)
+	}
+}
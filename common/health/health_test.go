@@ -0,0 +1,68 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestChecker(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	c := Register(grpc.NewServer(), db, "user.UserService")
+	c.interval = 10 * time.Millisecond
+
+	status := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := c.server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Status
+	}
+	waitFor := func(want healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for status("user.UserService") != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("status = %s, want %s", status("user.UserService"), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		if got := status(""); got != want {
+			t.Fatalf("server status = %s, want %s", got, want)
+		}
+	}
+
+	if got := status("user.UserService"); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status before the first probe = %s", got)
+	}
+
+	mock.ExpectPing()
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	waitFor(healthpb.HealthCheckResponse_SERVING)
+	waitFor(healthpb.HealthCheckResponse_NOT_SERVING)
+	cancel()
+	<-done
+
+	c.set(healthpb.HealthCheckResponse_SERVING)
+	c.Shutdown()
+	c.set(healthpb.HealthCheckResponse_SERVING)
+	if got := status("user.UserService"); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("status after Shutdown = %s", got)
+	}
+}
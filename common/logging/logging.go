@@ -0,0 +1,94 @@
+// Package logging configures structured JSON logging (log/slog) for the
+// services, correlates log lines by request ID and redacts sensitive fields.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Redacted replaces the value of sensitive attributes.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are matched as substrings of lower-cased attribute keys, so
+// "new_password" and "refresh_token" are covered as well.
+var sensitiveKeys = []string{"password", "token", "secret", "authorization", "cookie", "api_key", "totp"}
+
+// IsSensitive reports whether an attribute, header or field named key must
+// not be logged in clear.
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Setup installs a logger tagged with service as the slog default and returns
+// it. Output of the standard log package is routed through the same handler
+// at error level, so existing log.Fatal calls stay structured.
+//
+// level is the minimum level (debug, info, warn, error; default info) and
+// format "text" switches from JSON to human readable output.
+func Setup(service, level, format string) *slog.Logger {
+	logger := New(os.Stdout, service, level, format)
+	slog.SetDefault(logger)
+	slog.SetLogLoggerLevel(slog.LevelError)
+	return logger
+}
+
+// New builds a logger writing to w. See Setup for level and format values.
+func New(w io.Writer, service, level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       parseLevel(level),
+		ReplaceAttr: redact,
+	}
+
+	var h slog.Handler
+	if strings.EqualFold(format, "text") {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(contextHandler{h}).With("service", service)
+}
+
+func parseLevel(s string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if IsSensitive(a.Key) && a.Value.Kind() != slog.KindGroup {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+// contextHandler adds the request ID carried by the context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	tests := []struct {
+		name  string
+		attrs []any
+		want  map[string]any
+	}{
+		{
+			name:  "sensitive keys",
+			attrs: []any{"password", "hunter2", "new_password", "hunter3", "Authorization", "Bearer abc", "refresh_token", "xyz", "totp_code", "123456"},
+			want: map[string]any{"password": Redacted, "new_password": Redacted, "Authorization": Redacted,
+				"refresh_token": Redacted, "totp_code": Redacted},
+		},
+		{
+			name:  "other keys",
+			attrs: []any{"user_id", "u1", "email", "ada@example.com"},
+			want:  map[string]any{"user_id": "u1", "email": "ada@example.com"},
+		},
+		{
+			name:  "inside groups",
+			attrs: []any{slog.Group("request", "api_key", "k1", "path", "/orders")},
+			want:  map[string]any{"request": map[string]any{"api_key": Redacted, "path": "/orders"}},
+		},
+		{
+			name:  "groups named like sensitive keys keep their attributes",
+			attrs: []any{slog.Group("token", "expires_in", 60)},
+			want:  map[string]any{"token": map[string]any{"expires_in": float64(60)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			New(&buf, "test", "info", "json").Info("message", tt.attrs...)
+
+			var line map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.want {
+				got, _ := json.Marshal(line[key])
+				wantJSON, _ := json.Marshal(want)
+				if string(got) != string(wantJSON) {
+					t.Errorf("%s = %s, want %s", key, got, wantJSON)
+				}
+			}
+			for _, secret := range []string{"hunter2", "hunter3", "Bearer abc", "xyz", "123456", "k1"} {
+				if strings.Contains(buf.String(), secret) {
+					t.Errorf("log line contains %q: %s", secret, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestLoggerContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "order-service", "warn", "json")
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	logger.InfoContext(ctx, "below the level")
+	if buf.Len() != 0 {
+		t.Fatalf("logged below the level: %s", buf.String())
+	}
+	logger.With("order_id", "o1").WarnContext(ctx, "slow query")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["service"] != "order-service" || line["request_id"] != "req-1" || line["order_id"] != "o1" {
+		t.Errorf("log line %v, want service, request ID and order ID", line)
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"6f1c7a52-3a0b-4f5e-9d7e-2b8a4c1d0e9f", true},
+		{"trace:abc.def_1", true},
+		{"", false},
+		{strings.Repeat("a", 129), false},
+		{"line\nbreak", false},
+		{"with space", false},
+	}
+	for _, tt := range tests {
+		if got := ValidRequestID(tt.id); got != tt.want {
+			t.Errorf("ValidRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
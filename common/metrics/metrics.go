@@ -0,0 +1,44 @@
+// Package metrics exposes Prometheus metrics for the services: gRPC request
+// counts and latencies, database pool statistics and a /metrics endpoint.
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes every metric registered by this repository.
+const Namespace = "bestms"
+
+// Handler returns the HTTP handler serving the default registry.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Serve starts a /metrics endpoint on addr in the background. The returned
+// server should be shut down together with the process.
+func Serve(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		slog.Info("serving metrics", "addr", addr, "path", "/metrics")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("metrics server failed", "error", err)
+		}
+	}()
+	return srv
+}
+
+// RegisterDB exports the connection pool statistics of db (open, in use and
+// idle connections, waits and closes) labelled with db_name.
+func RegisterDB(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/GetUser"}
+	notFound := grpcHandled.WithLabelValues("user.UserService", "GetUser", "NotFound")
+	ok := grpcHandled.WithLabelValues("user.UserService", "GetUser", "OK")
+	before, beforeOK := testutil.ToFloat64(notFound), testutil.ToFloat64(ok)
+
+	for _, err := range []error{status.Error(codes.NotFound, "user not found"), status.Error(codes.NotFound, "user not found"), nil} {
+		interceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+			return nil, err
+		})
+	}
+
+	if got := testutil.ToFloat64(notFound) - before; got != 2 {
+		t.Errorf("NotFound calls = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(ok) - beforeOK; got != 1 {
+		t.Errorf("OK calls = %v, want 1", got)
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	counter := grpcClientHandled.WithLabelValues("product.ProductService", "GetProduct", "Unavailable")
+	before := testutil.ToFloat64(counter)
+
+	err := UnaryClientInterceptor()(context.Background(), "/product.ProductService/GetProduct", nil, nil, nil,
+		func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			return status.Error(codes.Unavailable, "connection refused")
+		})
+
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("error = %v, want the invoker's error", err)
+	}
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("calls = %v, want 1", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/order.OrderService/GetOrder"},
+		func(context.Context, interface{}) (interface{}, error) { return nil, nil })
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := w.Body.String()
+	for _, want := range []string{
+		`bestms_grpc_server_handled_total{grpc_code="OK",grpc_method="GetOrder",grpc_service="order.OrderService"}`,
+		`bestms_grpc_server_handling_seconds_count{grpc_method="GetOrder",grpc_service="order.OrderService"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics is missing %s", want)
+		}
+	}
+}
+
+func TestSplitMethod(t *testing.T) {
+	tests := []struct{ in, service, method string }{
+		{"/user.UserService/GetUser", "user.UserService", "GetUser"},
+		{"/grpc.health.v1.Health/Check", "grpc.health.v1.Health", "Check"},
+		{"Check", "unknown", "Check"},
+	}
+	for _, tt := range tests {
+		if service, method := splitMethod(tt.in); service != tt.service || method != tt.method {
+			t.Errorf("splitMethod(%q) = %q, %q", tt.in, service, method)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// Usage describes the arguments understood by Run.
+const Usage = `migrate up            apply all pending migrations
+migrate down [N]      revert the last N migrations (default 1)
+migrate to VERSION    migrate up or down to VERSION (0 reverts everything)
+migrate status        list migrations and whether they are applied`
+
+// Run executes a migrate subcommand and reports the outcome to out.
+func Run(ctx context.Context, m *Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command\n%s", Usage)
+	}
+
+	switch cmd, rest := args[0], args[1:]; cmd {
+	case "up":
+		n, err := m.Up(ctx)
+		fmt.Fprintf(out, "applied %d migration(s)\n", n)
+		return err
+	case "down":
+		steps := 1
+		if len(rest) > 0 {
+			var err error
+			if steps, err = strconv.Atoi(rest[0]); err != nil || steps < 1 {
+				return fmt.Errorf("down: invalid step count %q", rest[0])
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		fmt.Fprintf(out, "reverted %d migration(s)\n", n)
+		return err
+	case "to":
+		if len(rest) != 1 {
+			return fmt.Errorf("to: expected exactly one VERSION")
+		}
+		version, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("to: invalid version %q", rest[0])
+		}
+		n, err := m.To(ctx, version)
+		fmt.Fprintf(out, "migrated %d step(s) to version %d\n", n, version)
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, at := "pending", ""
+			switch {
+			case s.Missing:
+				state = "applied, file missing"
+			case s.Modified:
+				state = "applied, modified"
+			case s.Applied:
+				state = "applied"
+			}
+			if s.Applied {
+				at = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, at)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", cmd, Usage)
+	}
+}
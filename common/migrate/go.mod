@@ -0,0 +1,3 @@
+module github.com/best-microservice/common/migrate
+
+go 1.24.2
@@ -0,0 +1,111 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        fstest.MapFS
+		wantVersions []int64
+		wantErr      string
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"0010_add_index.up.sql":      {Data: []byte("CREATE INDEX i ON t(c);")},
+				"0002_create_t.up.sql":       {Data: []byte("CREATE TABLE t (c INT);")},
+				"0002_create_t.down.sql":     {Data: []byte("DROP TABLE t;")},
+				"0001_init.up.sql":           {Data: []byte("SELECT 1;")},
+				"README.md":                  {Data: []byte("not a migration")},
+				"0003_backfill.sql":          {Data: []byte("not a migration either")},
+				"nested/0004_ignored.up.sql": {Data: []byte("SELECT 4;")},
+			},
+			wantVersions: []int64{1, 2, 10},
+		},
+		{
+			name: "down without up",
+			files: fstest.MapFS{
+				"0001_init.down.sql": {Data: []byte("SELECT 1;")},
+			},
+			wantErr: "version 1 (init) has no up step",
+		},
+		{
+			name: "version used twice",
+			files: fstest.MapFS{
+				"0001_init.up.sql":  {Data: []byte("SELECT 1;")},
+				"0001_other.up.sql": {Data: []byte("SELECT 2;")},
+			},
+			wantErr: "version 1 is used by",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := Load(tt.files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var versions []int64
+			for _, m := range migrations {
+				versions = append(versions, m.Version)
+				if len(m.Checksum) != 64 {
+					t.Errorf("version %d checksum = %q, want a SHA-256", m.Version, m.Checksum)
+				}
+			}
+			if len(versions) != len(tt.wantVersions) {
+				t.Fatalf("versions = %v, want %v", versions, tt.wantVersions)
+			}
+			for i := range versions {
+				if versions[i] != tt.wantVersions[i] {
+					t.Fatalf("versions = %v, want %v", versions, tt.wantVersions)
+				}
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"0001_init.up.sql":     {Data: []byte("SELECT 1;")},
+		"0002_create_t.up.sql": {Data: []byte("CREATE TABLE t (c INT);")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Migrator{service: "test", migrations: migrations}
+	sum := func(i int) string { return migrations[i].Checksum }
+
+	tests := []struct {
+		name    string
+		applied map[int64]appliedRow
+		wantErr string
+	}{
+		{"nothing applied", map[int64]appliedRow{}, ""},
+		{"matching history", map[int64]appliedRow{1: {name: "init", checksum: sum(0)}, 2: {name: "create_t", checksum: sum(1)}}, ""},
+		{"modified file", map[int64]appliedRow{1: {name: "init", checksum: sum(1)}}, "version 1 (init) was modified"},
+		{"missing file", map[int64]appliedRow{1: {name: "init", checksum: sum(0)}, 7: {name: "gone"}}, "version 7 (gone) has no migration file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.verify(tt.applied)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("verify() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("verify() = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
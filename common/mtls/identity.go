@@ -0,0 +1,105 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// healthPrefix is exempt from the identity check so probes only need a
+// certificate from the CA, not a specific identity.
+const healthPrefix = "/grpc.health.v1.Health/"
+
+type identityKey struct{}
+
+// Identity returns the verified identity of the calling service, or "" when
+// the call was not made over mutual TLS.
+func Identity(ctx context.Context) string {
+	id, _ := ctx.Value(identityKey{}).(string)
+	return id
+}
+
+// UnaryServerInterceptor stores the caller identity in the context and
+// rejects callers that are not in AllowedClients.
+func (c *Credentials) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := c.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func (c *Credentials) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := c.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (c *Credentials) authorize(ctx context.Context, method string) (context.Context, error) {
+	if c.reloader == nil {
+		return ctx, nil
+	}
+
+	id := peerIdentity(ctx)
+	if id != "" {
+		ctx = context.WithValue(ctx, identityKey{}, id)
+	}
+	if len(c.cfg.AllowedClients) == 0 || strings.HasPrefix(method, healthPrefix) {
+		return ctx, nil
+	}
+	if id == "" {
+		return nil, status.Error(codes.Unauthenticated, "client certificate required")
+	}
+	for _, allowed := range c.cfg.AllowedClients {
+		if id == allowed {
+			return ctx, nil
+		}
+	}
+	return nil, status.Errorf(codes.PermissionDenied, "%s may not call %s", id, method)
+}
+
+// peerIdentity returns the common name of the verified client certificate,
+// or its first DNS name when the common name is empty.
+func peerIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return certIdentity(info.State.VerifiedChains[0][0])
+}
+
+func certIdentity(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return ""
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,100 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// callerContext is the context of a call from a client that presented cert,
+// or no certificate when cert is nil.
+func callerContext(cert *x509.Certificate) context.Context {
+	info := credentials.TLSInfo{}
+	if cert != nil {
+		info.State = tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: info})
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	gateway := &x509.Certificate{Subject: pkix.Name{CommonName: "api-gateway"}}
+	orders := &x509.Certificate{Subject: pkix.Name{CommonName: "order-service"}}
+	byDNSName := &x509.Certificate{DNSNames: []string{"api-gateway"}}
+	const method = "/user.UserService/GetUser"
+
+	tests := []struct {
+		name         string
+		enabled      bool
+		allowed      []string
+		caller       *x509.Certificate
+		method       string
+		wantCode     codes.Code
+		wantIdentity string
+	}{
+		{name: "TLS disabled", caller: nil, method: method},
+		{name: "any verified client", enabled: true, caller: orders, method: method, wantIdentity: "order-service"},
+		{name: "allowed client", enabled: true, allowed: []string{"api-gateway"}, caller: gateway, method: method, wantIdentity: "api-gateway"},
+		{name: "identity from the DNS name", enabled: true, allowed: []string{"api-gateway"}, caller: byDNSName, method: method, wantIdentity: "api-gateway"},
+		{name: "other client", enabled: true, allowed: []string{"api-gateway"}, caller: orders, method: method, wantCode: codes.PermissionDenied},
+		{name: "no client certificate", enabled: true, allowed: []string{"api-gateway"}, method: method, wantCode: codes.Unauthenticated},
+		{name: "health checks need no identity", enabled: true, allowed: []string{"api-gateway"}, method: "/grpc.health.v1.Health/Check"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Credentials{cfg: Config{Enabled: tt.enabled, RequireClientCert: tt.enabled, AllowedClients: tt.allowed}}
+			if tt.enabled {
+				c.reloader = &reloader{}
+			}
+
+			var identity string
+			called := false
+			_, err := c.UnaryServerInterceptor()(callerContext(tt.caller), nil, &grpc.UnaryServerInfo{FullMethod: tt.method},
+				func(ctx context.Context, _ interface{}) (interface{}, error) {
+					called = true
+					identity = Identity(ctx)
+					return nil, nil
+				})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("code %s, want %s: %v", status.Code(err), tt.wantCode, err)
+			}
+			if called != (tt.wantCode == codes.OK) {
+				t.Errorf("handler called: %v", called)
+			}
+			if identity != tt.wantIdentity {
+				t.Errorf("identity %q, want %q", identity, tt.wantIdentity)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"disabled", Config{}, false},
+		{"server TLS", Config{Enabled: true, CertFile: "c.pem", KeyFile: "k.pem", CAFile: "ca.pem"}, false},
+		{"mutual TLS with allowed clients", Config{Enabled: true, CertFile: "c.pem", KeyFile: "k.pem", CAFile: "ca.pem",
+			RequireClientCert: true, AllowedClients: []string{"api-gateway"}}, false},
+		{"no certificate", Config{Enabled: true, CAFile: "ca.pem"}, true},
+		{"no CA", Config{Enabled: true, CertFile: "c.pem", KeyFile: "k.pem"}, true},
+		{"allowed clients without client certificates", Config{Enabled: true, CertFile: "c.pem", KeyFile: "k.pem", CAFile: "ca.pem",
+			AllowedClients: []string{"api-gateway"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
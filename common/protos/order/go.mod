@@ -3,6 +3,7 @@ module github.com/best-microservice/common/protos/order
 go 1.24.2
 
 require (
+	google.golang.org/genproto/googleapis/api v0.0.0-20250603155806-513f23925822
 	google.golang.org/grpc v1.74.2
 	google.golang.org/protobuf v1.36.6
 )
@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr bool
+	}{
+		{"defaults", func(*Config) {}, false},
+		{"otlp", func(c *Config) { c.Exporter = ExporterOTLP }, false},
+		{"unknown exporter", func(c *Config) { c.Exporter = "jaeger" }, true},
+		{"negative ratio", func(c *Config) { c.SampleRatio = -0.1 }, true},
+		{"ratio above one", func(c *Config) { c.SampleRatio = 1.5 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig("svc")
+			tt.mutate(&cfg)
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// span is the part of the stdout exporter's JSON the test looks at.
+type span struct {
+	Name        string
+	SpanKind    int
+	SpanContext struct{ TraceID, SpanID string }
+	Parent      struct{ TraceID, SpanID string }
+	Resource    []struct {
+		Key   string
+		Value struct{ Value interface{} }
+	}
+}
+
+// TestPropagation calls a gRPC server through instrumented connections and
+// checks that the file exporter records the server span as a child of the
+// client span.
+func TestPropagation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traces.json")
+	cfg := DefaultConfig("tracing-test")
+	cfg.Exporter, cfg.File = ExporterFile, file
+	shutdown, err := Init(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(ServerOption())
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()), DialOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := map[int]span{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var s span
+		if err := dec.Decode(&s); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		spans[s.SpanKind] = s
+	}
+
+	const serverKind, clientKind = 2, 3
+	server, client := spans[serverKind], spans[clientKind]
+	if server.Name != "grpc.health.v1.Health/Check" || client.Name != server.Name {
+		t.Fatalf("spans = %+v", spans)
+	}
+	if server.SpanContext.TraceID != client.SpanContext.TraceID || server.Parent.SpanID != client.SpanContext.SpanID {
+		t.Errorf("server span %+v is not a child of client span %+v", server.SpanContext, client.SpanContext)
+	}
+	var name interface{}
+	for _, kv := range server.Resource {
+		if kv.Key == "service.name" {
+			name = kv.Value.Value
+		}
+	}
+	if name != "tracing-test" {
+		t.Errorf("service.name = %v", name)
+	}
+}
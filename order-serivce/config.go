@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/best-microservice/common/config"
+	"github.com/best-microservice/common/mtls"
+	"github.com/best-microservice/common/tracing"
+)
+
+// Config is the order service configuration. See the config package for the
+// sources and their precedence.
+type Config struct {
+	ListenAddr           string          `yaml:"listen_addr" env:"ORDER_LISTEN_ADDR" flag:"listen-addr" usage:"gRPC listen address"`
+	MetricsAddr          string          `yaml:"metrics_addr" env:"ORDER_METRICS_ADDR" flag:"metrics-addr" usage:"Prometheus /metrics listen address"`
+	EventSubscribers     []string        `yaml:"event_subscribers" env:"ORDER_EVENT_SUBSCRIBERS" flag:"event-subscribers" usage:"comma separated gRPC addresses events are pushed to"`
+	UserServiceAddr      string          `yaml:"user_service_addr" env:"USER_SERVICE_ADDR" flag:"user-service-addr" usage:"user service gRPC address, to validate user IDs" validate:"required"`
+	ProductServiceAddr   string          `yaml:"product_service_addr" env:"PRODUCT_SERVICE_ADDR" flag:"product-service-addr" usage:"product service gRPC address, to validate product IDs" validate:"required"`
+	MigrateOnStart       bool            `yaml:"migrate_on_start" env:"ORDER_MIGRATE_ON_START" flag:"migrate-on-start" usage:"apply pending migrations before serving"`
+	WatchPollInterval    time.Duration   `yaml:"watch_poll_interval" env:"ORDER_WATCH_POLL_INTERVAL" flag:"watch-poll-interval" usage:"how often order watchers look for status changes made by other replicas"`
+	RequireVerifiedEmail bool            `yaml:"require_verified_email" env:"ORDER_REQUIRE_VERIFIED_EMAIL" flag:"require-verified-email" usage:"only accept orders from users who verified their email address"`
+	Database             config.Database `yaml:"database" env:"ORDER_DB_" flag:"db-"`
+	Invoice              InvoiceConfig   `yaml:"invoice" env:"INVOICE_" flag:"invoice-"`
+	Log                  config.Logging  `yaml:"log"`
+	TLS                  mtls.Config     `yaml:"tls" env:"ORDER_TLS_" flag:"tls-"`
+	Tracing              tracing.Config  `yaml:"tracing"`
+}
+
+// InvoiceConfig describes the seller printed on invoices and how they are taxed.
+type InvoiceConfig struct {
+	SellerName string `yaml:"seller_name" env:"SELLER_NAME" flag:"seller-name" usage:"legal name of the seller"`
+	// SellerAddress holds the address lines separated by ";".
+	SellerAddress string  `yaml:"seller_address" env:"SELLER_ADDRESS" flag:"seller-address" usage:"seller address lines separated by ;"`
+	SellerTaxID   string  `yaml:"seller_tax_id" env:"SELLER_TAX_ID" flag:"seller-tax-id" usage:"seller VAT or tax ID"`
+	TaxRate       float64 `yaml:"tax_rate" env:"TAX_RATE" flag:"tax-rate" usage:"tax rate included in prices, e.g. 0.2"`
+	Currency      string  `yaml:"currency" env:"CURRENCY" flag:"currency" usage:"ISO 4217 currency code"`
+}
+
+// loadConfig returns the configuration and the positional arguments, such as
+// the migrate subcommand.
+func loadConfig(args []string) (Config, []string, error) {
+	cfg := Config{
+		ListenAddr:         ":50053",
+		MetricsAddr:        ":9103",
+		MigrateOnStart:     true,
+		WatchPollInterval:  5 * time.Second,
+		UserServiceAddr:    "localhost:50051",
+		ProductServiceAddr: "localhost:50052",
+		Database:           config.DefaultDatabase(),
+		Invoice:            InvoiceConfig{Currency: "USD"},
+		Log:                config.DefaultLogging(),
+		Tracing:            tracing.DefaultConfig("order-service"),
+	}
+	rest, err := config.Load("order-service", &cfg, args)
+	return cfg, rest, err
+}
+
+func (c Config) Validate() error {
+	var pollErr error
+	if c.WatchPollInterval <= 0 {
+		pollErr = fmt.Errorf("watch_poll_interval %v must be positive", c.WatchPollInterval)
+	}
+	return errors.Join(
+		config.ValidateAddr("listen_addr", c.ListenAddr),
+		config.ValidateAddr("metrics_addr", c.MetricsAddr),
+		config.ValidateAddr("user_service_addr", c.UserServiceAddr),
+		config.ValidateAddr("product_service_addr", c.ProductServiceAddr),
+		pollErr,
+	)
+}
+
+func (c InvoiceConfig) Validate() error {
+	if c.TaxRate < 0 || c.TaxRate >= 1 {
+		return fmt.Errorf("invoice.tax_rate %v must be a fraction between 0 and 1", c.TaxRate)
+	}
+	if len(c.Currency) != 3 {
+		return fmt.Errorf("invoice.currency %q must be a three letter ISO 4217 code", c.Currency)
+	}
+	return nil
+}
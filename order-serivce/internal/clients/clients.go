@@ -0,0 +1,71 @@
+// Package clients wraps the gRPC APIs of the services owning data that
+// orders refer to by ID.
+package clients
+
+import (
+	"context"
+
+	productpb "github.com/best-microservice/common/protos/product"
+	userpb "github.com/best-microservice/common/protos/user"
+	"github.com/best-microservice/order-service/internal/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Users looks up users in the user service.
+type Users struct {
+	client userpb.UserServiceClient
+}
+
+func NewUsers(conn *grpc.ClientConn) *Users {
+	return &Users{client: userpb.NewUserServiceClient(conn)}
+}
+
+// GetUser returns the user with the given ID, or nil if there is none.
+// Errors other than NotFound, e.g. the service being unavailable, are
+// returned as is.
+func (u *Users) GetUser(ctx context.Context, id string) (*models.UserSummary, error) {
+	res, err := u.client.GetUser(ctx, &userpb.GetUserRequest{Id: id})
+	if ok, err := exists(err); !ok {
+		return nil, err
+	}
+	return &models.UserSummary{ID: res.Id, Name: res.Name, Email: res.Email, EmailVerified: res.EmailVerified}, nil
+}
+
+// Products looks up products in the product service.
+type Products struct {
+	client productpb.ProductServiceClient
+}
+
+func NewProducts(conn *grpc.ClientConn) *Products {
+	return &Products{client: productpb.NewProductServiceClient(conn)}
+}
+
+// GetProducts returns the products with the given IDs in one call, keyed
+// by ID. IDs the product service does not know are missing from the map.
+func (p *Products) GetProducts(ctx context.Context, ids []string) (map[string]*models.ProductSummary, error) {
+	res, err := p.client.GetProducts(ctx, &productpb.GetProductsRequest{Ids: ids})
+	if err != nil {
+		return nil, err
+	}
+	products := make(map[string]*models.ProductSummary, len(res.Products))
+	for _, pr := range res.Products {
+		products[pr.Id] = &models.ProductSummary{
+			ID:          pr.Id,
+			Name:        pr.Name,
+			Description: pr.Description,
+			Price:       float64(pr.Price),
+			Stock:       int(pr.Stock),
+			Weight:      float64(pr.Weight),
+		}
+	}
+	return products, nil
+}
+
+func exists(err error) (bool, error) {
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
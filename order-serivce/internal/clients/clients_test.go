@@ -0,0 +1,82 @@
+package clients
+
+import (
+	"context"
+	"testing"
+
+	productpb "github.com/best-microservice/common/protos/product"
+	userpb "github.com/best-microservice/common/protos/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeUserService struct {
+	userpb.UserServiceClient
+	users map[string]*userpb.UserResponse
+	err   error
+}
+
+func (f *fakeUserService) GetUser(_ context.Context, req *userpb.GetUserRequest, _ ...grpc.CallOption) (*userpb.UserResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if u, ok := f.users[req.Id]; ok {
+		return u, nil
+	}
+	return nil, status.Error(codes.NotFound, "user not found")
+}
+
+type fakeProductService struct {
+	productpb.ProductServiceClient
+	products map[string]*productpb.ProductResponse
+}
+
+func (f *fakeProductService) GetProducts(_ context.Context, req *productpb.GetProductsRequest, _ ...grpc.CallOption) (*productpb.GetProductsResponse, error) {
+	res := &productpb.GetProductsResponse{}
+	for _, id := range req.Ids {
+		if p, ok := f.products[id]; ok {
+			res.Products = append(res.Products, p)
+		}
+	}
+	return res, nil
+}
+
+func TestGetUser(t *testing.T) {
+	users := &Users{client: &fakeUserService{users: map[string]*userpb.UserResponse{
+		"u1": {Id: "u1", Name: "Ada", Email: "ada@example.com", EmailVerified: true},
+	}}}
+
+	user, err := users.GetUser(context.Background(), "u1")
+	if err != nil || user == nil || user.Name != "Ada" || !user.EmailVerified {
+		t.Fatalf("GetUser(u1) = %+v, %v", user, err)
+	}
+
+	user, err = users.GetUser(context.Background(), "nobody")
+	if err != nil || user != nil {
+		t.Errorf("GetUser(nobody) = %+v, %v, want nil, nil", user, err)
+	}
+
+	users.client = &fakeUserService{err: status.Error(codes.Unavailable, "connection refused")}
+	user, err = users.GetUser(context.Background(), "u1")
+	if status.Code(err) != codes.Unavailable || user != nil {
+		t.Errorf("GetUser with the service down = %+v, %v, want Unavailable", user, err)
+	}
+}
+
+func TestGetProducts(t *testing.T) {
+	products := &Products{client: &fakeProductService{products: map[string]*productpb.ProductResponse{
+		"p1": {Id: "p1", Name: "Kettle", Price: 30, Stock: 4, Weight: 1.5},
+	}}}
+
+	found, err := products.GetProducts(context.Background(), []string{"p1", "p9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("found %v, want only p1", found)
+	}
+	if p := found["p1"]; p.Name != "Kettle" || p.Price != 30 || p.Stock != 4 || p.Weight != 1.5 {
+		t.Errorf("p1 = %+v", p)
+	}
+}
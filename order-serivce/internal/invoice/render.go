@@ -0,0 +1,169 @@
+// Package invoice renders order invoices to HTML and PDF.
+package invoice
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/best-microservice/order-service/internal/models"
+)
+
+//go:embed templates/invoice.html
+var templates embed.FS
+
+// Seller describes the company issuing the invoices.
+type Seller struct {
+	Name    string
+	Address []string
+	TaxID   string
+}
+
+type Renderer struct {
+	seller Seller
+	html   *template.Template
+}
+
+func NewRenderer(seller Seller) (*Renderer, error) {
+	tmpl, err := template.New("invoice.html").Funcs(template.FuncMap{
+		"money":        formatMoney,
+		"percent":      formatPercent,
+		"addressLines": addressLines,
+	}).ParseFS(templates, "templates/invoice.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse invoice template: %w", err)
+	}
+
+	return &Renderer{seller: seller, html: tmpl}, nil
+}
+
+func (r *Renderer) RenderHTML(inv *models.Invoice) ([]byte, error) {
+	var buf bytes.Buffer
+	err := r.html.Execute(&buf, struct {
+		Invoice *models.Invoice
+		Seller  Seller
+	}{inv, r.seller})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render invoice html: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderPDF lays the invoice out on A4 pages using the standard PDF fonts.
+func (r *Renderer) RenderPDF(inv *models.Invoice) ([]byte, error) {
+	doc := newPDFDocument()
+
+	doc.text(50, 790, 22, true, "INVOICE")
+	doc.text(50, 765, 10, false, "No. "+inv.Number)
+	doc.text(50, 751, 10, false, "Order "+inv.OrderID)
+	doc.text(400, 765, 10, false, "Issued "+inv.IssuedAt.Format("2006-01-02"))
+	doc.text(400, 751, 10, false, "Currency "+inv.Currency)
+
+	y := 715.0
+	sellerLines := append([]string{r.seller.Name}, r.seller.Address...)
+	if r.seller.TaxID != "" {
+		sellerLines = append(sellerLines, "Tax ID "+r.seller.TaxID)
+	}
+	columns := []struct {
+		x     float64
+		title string
+		lines []string
+	}{
+		{50, "From", sellerLines},
+		{230, "Bill to", addressLines(inv.BillingAddress)},
+		{410, "Ship to", addressLines(inv.ShippingAddress)},
+	}
+	bottom := y
+	for _, col := range columns {
+		doc.text(col.x, y, 10, true, col.title)
+		ly := y - 14
+		for _, l := range col.lines {
+			doc.text(col.x, ly, 9, false, l)
+			ly -= 12
+		}
+		if ly < bottom {
+			bottom = ly
+		}
+	}
+
+	y = bottom - 20
+	header := func() {
+		doc.text(50, y, 10, true, "Item")
+		doc.textRight(360, y, 10, true, "Qty")
+		doc.textRight(450, y, 10, true, "Unit price")
+		doc.textRight(545, y, 10, true, "Amount")
+		doc.line(50, y-4, 545, y-4)
+		y -= 18
+	}
+	header()
+	for _, l := range inv.Lines {
+		if y < 120 {
+			doc.newPage()
+			y = 790
+			header()
+		}
+		doc.text(50, y, 9, false, truncate(l.Description, 50))
+		doc.textRight(360, y, 9, false, fmt.Sprintf("%d", l.Quantity))
+		doc.textRight(450, y, 9, false, formatMoney(l.UnitPrice))
+		doc.textRight(545, y, 9, false, formatMoney(l.Amount))
+		y -= 14
+	}
+
+	if y < 140 {
+		doc.newPage()
+		y = 790
+	}
+	y -= 10
+	totals := [][2]string{{"Subtotal", formatMoney(inv.Subtotal)}}
+	if inv.Discount != 0 {
+		totals = append(totals, [2]string{"Discount", "-" + formatMoney(inv.Discount)})
+	}
+	totals = append(totals,
+		[2]string{"Shipping", formatMoney(inv.Shipping)},
+		[2]string{"Tax (" + formatPercent(inv.TaxRate) + ")", formatMoney(inv.Tax)},
+	)
+	for _, t := range totals {
+		doc.text(360, y, 10, false, t[0])
+		doc.textRight(545, y, 10, false, t[1])
+		y -= 14
+	}
+	doc.line(360, y+10, 545, y+10)
+	y -= 4
+	doc.text(360, y, 11, true, "Total")
+	doc.textRight(545, y, 11, true, formatMoney(inv.Total)+" "+inv.Currency)
+
+	return doc.bytes(), nil
+}
+
+func formatMoney(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
+
+func formatPercent(rate float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", rate*100), "0"), ".") + "%"
+}
+
+func addressLines(a *models.Address) []string {
+	if a == nil {
+		return nil
+	}
+	lines := []string{a.Recipient, a.Line1}
+	if a.Line2 != "" {
+		lines = append(lines, a.Line2)
+	}
+	city := strings.TrimSpace(a.PostalCode + " " + a.City)
+	if a.State != "" {
+		city += ", " + a.State
+	}
+	lines = append(lines, city, a.Country)
+	return lines
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type InvoiceLine struct {
+	Description string  `json:"description"`
+	ProductID   string  `json:"product_id"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Amount      float64 `json:"amount"`
+}
+
+// InvoiceLines is stored as a JSONB array on the invoice.
+type InvoiceLines []InvoiceLine
+
+func (l InvoiceLines) Value() (driver.Value, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(l)
+}
+
+func (l *InvoiceLines) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return errors.New("unsupported invoice lines type")
+	}
+}
+
+type Invoice struct {
+	ID              string       `json:"id" db:"id"`
+	OrderID         string       `json:"order_id" db:"order_id"`
+	Number          string       `json:"number" db:"number"`
+	IssuedAt        time.Time    `json:"issued_at" db:"issued_at"`
+	Currency        string       `json:"currency" db:"currency"`
+	Lines           InvoiceLines `json:"lines" db:"lines"`
+	Subtotal        float64      `json:"subtotal" db:"subtotal"`
+	Discount        float64      `json:"discount" db:"discount"`
+	TaxRate         float64      `json:"tax_rate" db:"tax_rate"`
+	Tax             float64      `json:"tax" db:"tax"`
+	Shipping        float64      `json:"shipping" db:"shipping"`
+	Total           float64      `json:"total" db:"total"`
+	BillingAddress  *Address     `json:"billing_address" db:"billing_address"`
+	ShippingAddress *Address     `json:"shipping_address" db:"shipping_address"`
+	HTML            []byte       `json:"-" db:"html"`
+	PDF             []byte       `json:"-" db:"pdf"`
+}
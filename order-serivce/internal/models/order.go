@@ -1,18 +1,106 @@
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+)
 
 type OrderItem struct {
-	ProductID string  `json:"product_id"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	ProductID string  `json:"product_id" db:"product_id"`
+	Quantity  int     `json:"quantity" db:"quantity"`
+	Price     float64 `json:"price" db:"price"`
+	Weight    float64 `json:"weight" db:"weight"`
+	// Snapshot of the product taken when the order was placed
+	ProductName        string `json:"product_name" db:"product_name"`
+	ProductDescription string `json:"product_description" db:"product_description"`
+	// Product is the current product, set only when the order is expanded
+	Product *ProductSummary `json:"product,omitempty" db:"-"`
+}
+
+// ProductSummary is a product as the product service reports it now.
+type ProductSummary struct {
+	ID          string
+	Name        string
+	Description string
+	Price       float64
+	Stock       int
+	// Weight of a single unit in kilograms
+	Weight float64
+}
+
+// UserSummary is the public part of a user account.
+type UserSummary struct {
+	ID            string
+	Name          string
+	Email         string
+	EmailVerified bool
 }
 
 type Order struct {
-	ID        string      `json:"id"`
-	UserID    string      `json:"user_id"`
-	Items     []OrderItem `json:"items"`
-	Total     float64     `json:"total"`
-	Status    string      `json:"status"`
-	CreatedAt time.Time   `json:"created_at"`
+	ID               string      `json:"id" db:"id"`
+	UserID           string      `json:"user_id" db:"user_id"`
+	Items            []OrderItem `json:"items" db:"-"`
+	Subtotal         float64     `json:"subtotal" db:"subtotal"`
+	ShippingMethodID string      `json:"shipping_method_id" db:"shipping_method_id"`
+	ShippingCost     float64     `json:"shipping_cost" db:"shipping_cost"`
+	ShippingAddress  *Address    `json:"shipping_address" db:"shipping_address"`
+	BillingAddress   *Address    `json:"billing_address" db:"billing_address"`
+	Shipments        []Shipment  `json:"shipments" db:"-"`
+	Total            float64     `json:"total" db:"total"`
+	Status           string      `json:"status" db:"status"`
+	CreatedAt        time.Time   `json:"created_at" db:"created_at"`
+	// User is set only when the order is expanded
+	User *UserSummary `json:"user,omitempty" db:"-"`
+}
+
+// OrderStatusEvent records a status transition of an order. The first event
+// of an order has no previous status.
+type OrderStatusEvent struct {
+	ID             int64     `db:"id"`
+	OrderID        string    `db:"order_id"`
+	PreviousStatus string    `db:"previous_status"`
+	Status         string    `db:"status"`
+	OccurredAt     time.Time `db:"occurred_at"`
+}
+
+// Address is the snapshot of a delivery or billing address stored with an
+// order, so later edits to the user's address book don't rewrite history.
+type Address struct {
+	Recipient  string `json:"recipient"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+	Phone      string `json:"phone,omitempty"`
+}
+
+// Value stores the address as JSONB.
+func (a Address) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+// Scan reads the address from a JSONB column.
+func (a *Address) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return errors.New("unsupported address type")
+	}
 }
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	ShippingRuleFlat   = "flat"
+	ShippingRuleWeight = "weight"
+	ShippingRuleTotal  = "total"
+)
+
+const (
+	ShipmentStatusLabelCreated   = "label_created"
+	ShipmentStatusInTransit      = "in_transit"
+	ShipmentStatusOutForDelivery = "out_for_delivery"
+	ShipmentStatusDelivered      = "delivered"
+	ShipmentStatusException      = "exception"
+	ShipmentStatusReturned       = "returned"
+)
+
+type ShippingRateTier struct {
+	Min  float64 `json:"min"`
+	Cost float64 `json:"cost"`
+}
+
+// ShippingRateTiers is stored as a JSONB array on the shipping method.
+type ShippingRateTiers []ShippingRateTier
+
+func (t ShippingRateTiers) Value() (driver.Value, error) {
+	if t == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(t)
+}
+
+func (t *ShippingRateTiers) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	default:
+		return errors.New("unsupported shipping tiers type")
+	}
+}
+
+type ShippingMethod struct {
+	ID       string            `json:"id" db:"id"`
+	Code     string            `json:"code" db:"code"`
+	Name     string            `json:"name" db:"name"`
+	Carrier  string            `json:"carrier" db:"carrier"`
+	RuleType string            `json:"rule_type" db:"rule_type"`
+	BaseCost float64           `json:"base_cost" db:"base_cost"`
+	Tiers    ShippingRateTiers `json:"tiers" db:"tiers"`
+	Active   bool              `json:"active" db:"active"`
+}
+
+type ShipmentEvent struct {
+	Status     string    `json:"status" db:"status"`
+	Note       string    `json:"note" db:"note"`
+	OccurredAt time.Time `json:"occurred_at" db:"occurred_at"`
+}
+
+type Shipment struct {
+	ID             string          `json:"id" db:"id"`
+	OrderID        string          `json:"order_id" db:"order_id"`
+	Carrier        string          `json:"carrier" db:"carrier"`
+	TrackingNumber string          `json:"tracking_number" db:"tracking_number"`
+	Status         string          `json:"status" db:"status"`
+	ShippedAt      *time.Time      `json:"shipped_at" db:"shipped_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at" db:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	Events         []ShipmentEvent `json:"events" db:"-"`
+}
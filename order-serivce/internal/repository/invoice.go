@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/best-microservice/order-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type InvoiceRepository struct {
+	db *sqlx.DB
+}
+
+func NewInvoiceRepository(db *sqlx.DB) *InvoiceRepository {
+	return &InvoiceRepository{db: db}
+}
+
+// CreateInvoice allocates the next invoice number for the issue year and stores
+// the invoice in one transaction. The sequence row stays locked until commit and
+// the increment is rolled back together with a failed insert or render, so
+// numbers are gap-free. render is called once the number is known.
+func (r *InvoiceRepository) CreateInvoice(ctx context.Context, inv *models.Invoice, render func(*models.Invoice) error) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	inv.ID = uuid.New().String()
+	inv.IssuedAt = time.Now().UTC()
+
+	var seq int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO invoice_sequences (year, last_number)
+		VALUES ($1, 1)
+		ON CONFLICT (year) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+		RETURNING last_number
+	`, inv.IssuedAt.Year()).Scan(&seq)
+	if err != nil {
+		return err
+	}
+	inv.Number = invoiceNumber(inv.IssuedAt.Year(), seq)
+
+	if err := render(inv); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO invoices (id, order_id, number, issued_at, currency, lines, subtotal, discount,
+			tax_rate, tax, shipping, total, billing_address, shipping_address, html, pdf)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`
+
+	_, err = tx.ExecContext(ctx, query,
+		inv.ID, inv.OrderID, inv.Number, inv.IssuedAt, inv.Currency, inv.Lines, inv.Subtotal,
+		inv.Discount, inv.TaxRate, inv.Tax, inv.Shipping, inv.Total, inv.BillingAddress,
+		inv.ShippingAddress, inv.HTML, inv.PDF)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// invoiceNumber formats the seq-th invoice of year. Numbers sort in issue
+// order up to a million invoices a year.
+func invoiceNumber(year int, seq int64) string {
+	return fmt.Sprintf("INV-%d-%06d", year, seq)
+}
+
+func (r *InvoiceRepository) GetInvoiceByOrderID(ctx context.Context, orderID string) (*models.Invoice, error) {
+	query := `
+		SELECT id, order_id, number, issued_at, currency, lines, subtotal, discount,
+			tax_rate, tax, shipping, total, billing_address, shipping_address, html, pdf
+		FROM invoices
+		WHERE order_id = $1
+	`
+
+	var inv models.Invoice
+	err := r.db.GetContext(ctx, &inv, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inv, nil
+}
@@ -0,0 +1,21 @@
+package repository
+
+import "testing"
+
+func TestInvoiceNumber(t *testing.T) {
+	tests := []struct {
+		year int
+		seq  int64
+		want string
+	}{
+		{2024, 1, "INV-2024-000001"},
+		{2024, 42, "INV-2024-000042"},
+		{2025, 999999, "INV-2025-999999"},
+		{2025, 1000000, "INV-2025-1000000"},
+	}
+	for _, tt := range tests {
+		if got := invoiceNumber(tt.year, tt.seq); got != tt.want {
+			t.Errorf("invoiceNumber(%d, %d) = %q, want %q", tt.year, tt.seq, got, tt.want)
+		}
+	}
+}
@@ -2,14 +2,19 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
+	"github.com/best-microservice/common/events"
 	"github.com/best-microservice/order-service/internal/models"
 	"github.com/google/uuid"
-
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// EventSource identifies this service in the events it writes to the outbox.
+const EventSource = "order-service"
+
 type OrderRepository struct {
 	db *sqlx.DB
 }
@@ -19,28 +24,70 @@ func NewOrderRepository(db *sqlx.DB) *OrderRepository {
 }
 
 func (r *OrderRepository) CreateOrder(ctx context.Context, order *models.Order) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO users (id, user_id, item, total, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO orders (id, user_id, subtotal, shipping_method_id, shipping_cost,
+			shipping_address, billing_address, total, status, created_at, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5, $6, $7, $8, $9, $10, $10)
 		RETURNING id
 	`
 
-	order.ID = uuid.New().String()
-	now := time.Now()
+	if order.ID == "" {
+		order.ID = uuid.New().String()
+	}
+	order.CreatedAt = time.Now()
 
-	err := r.db.QueryRowContext(ctx, query,
-		order.ID, order.UserID, order.Items, order.Total, order.Status, now).Scan(&order.ID)
+	err = tx.QueryRowContext(ctx, query,
+		order.ID, order.UserID, order.Subtotal, order.ShippingMethodID, order.ShippingCost,
+		order.ShippingAddress, order.BillingAddress, order.Total, order.Status,
+		order.CreatedAt).Scan(&order.ID)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	itemQuery := `
+		INSERT INTO order_items (order_id, product_id, quantity, price, weight, product_name, product_description)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	for _, item := range order.Items {
+		if _, err := tx.ExecContext(ctx, itemQuery,
+			order.ID, item.ProductID, item.Quantity, item.Price, item.Weight,
+			item.ProductName, item.ProductDescription); err != nil {
+			return err
+		}
+	}
+
+	if err := insertStatusEvent(ctx, tx.Tx, order.ID, "", order.Status, order.CreatedAt); err != nil {
+		return err
+	}
+
+	evt, err := events.New(events.OrderCreated, EventSource, "order", order.ID, events.OrderCreatedPayload{
+		OrderID: order.ID,
+		UserID:  order.UserID,
+		Items:   itemPayloads(order.Items),
+		Total:   order.Total,
+		Status:  order.Status,
+	})
+	if err != nil {
+		return err
+	}
+	if err := events.Write(ctx, tx, evt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (r *OrderRepository) GetOrderByID(ctx context.Context, id string) (*models.Order, error) {
 	query := `
-		SELECT *
-		FROM users
+		SELECT id, user_id, subtotal, COALESCE(shipping_method_id::text, '') AS shipping_method_id,
+			shipping_cost, shipping_address, billing_address, total, status, created_at
+		FROM orders
 		WHERE id = $1
 	`
 
@@ -50,9 +97,221 @@ func (r *OrderRepository) GetOrderByID(ctx context.Context, id string) (*models.
 		return nil, err
 	}
 
+	itemQuery := `
+		SELECT product_id, quantity, price, weight, product_name, product_description
+		FROM order_items
+		WHERE order_id = $1
+	`
+	if err := r.db.SelectContext(ctx, &order.Items, itemQuery, id); err != nil {
+		return nil, err
+	}
+
 	return &order, nil
 }
 
+// UserOrders is a page of the orders of one user.
+type UserOrders struct {
+	Orders []*models.Order
+	// Total counts all orders of the user, not just this page.
+	Total int
+}
+
+// ListOrdersByUsers returns a page of the orders of each user, newest first,
+// in three queries however many users are asked for. Users without orders
+// are left out of the result. The queries read one snapshot, so totals
+// count the orders of the page.
+func (r *OrderRepository) ListOrdersByUsers(ctx context.Context, userIDs []string, limit, offset int) (map[string]*UserOrders, error) {
+	// Users are stored by UUID, so other IDs cannot have orders, and the
+	// database returns them in canonical form
+	var ids []string
+	requested := map[string][]string{}
+	for _, id := range userIDs {
+		if u, err := uuid.Parse(id); err == nil {
+			ids = append(ids, u.String())
+			requested[u.String()] = append(requested[u.String()], id)
+		}
+	}
+	result := map[string]*UserOrders{}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	defer func() {
+		for canonical, originals := range requested {
+			if page, ok := result[canonical]; ok {
+				for _, id := range originals {
+					result[id] = page
+				}
+			}
+		}
+	}()
+
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var counts []struct {
+		UserID string `db:"user_id"`
+		Total  int    `db:"total"`
+	}
+	err = tx.SelectContext(ctx, &counts, `
+		SELECT user_id, COUNT(*) AS total
+		FROM orders
+		WHERE user_id = ANY($1::uuid[])
+		GROUP BY user_id
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range counts {
+		result[c.UserID] = &UserOrders{Total: c.Total}
+	}
+
+	var orders []*models.Order
+	err = tx.SelectContext(ctx, &orders, `
+		SELECT id, user_id, subtotal, shipping_method_id, shipping_cost, shipping_address,
+			billing_address, total, status, created_at
+		FROM (
+			SELECT id, user_id, subtotal, COALESCE(shipping_method_id::text, '') AS shipping_method_id,
+				shipping_cost, shipping_address, billing_address, total, status, created_at,
+				ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at DESC, id) AS position
+			FROM orders
+			WHERE user_id = ANY($1::uuid[])
+		) ranked
+		WHERE position > $2 AND position <= $2 + $3
+		ORDER BY user_id, position
+	`, pq.Array(ids), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return result, tx.Commit()
+	}
+
+	byID := make(map[string]*models.Order, len(orders))
+	orderIDs := make([]string, len(orders))
+	for i, o := range orders {
+		byID[o.ID] = o
+		orderIDs[i] = o.ID
+		page, ok := result[o.UserID]
+		if !ok {
+			page = &UserOrders{}
+			result[o.UserID] = page
+		}
+		page.Orders = append(page.Orders, o)
+	}
+
+	var items []struct {
+		OrderID string `db:"order_id"`
+		models.OrderItem
+	}
+	err = tx.SelectContext(ctx, &items, `
+		SELECT order_id, product_id, quantity, price, weight, product_name, product_description
+		FROM order_items
+		WHERE order_id = ANY($1::uuid[])
+	`, pq.Array(orderIDs))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		o := byID[item.OrderID]
+		o.Items = append(o.Items, item.OrderItem)
+	}
+	return result, tx.Commit()
+}
+
+// UpdateOrderStatus moves the order from its current status to newStatus,
+// adds the transition to the order's status history and records an
+// OrderStatusChanged event. It returns sql.ErrNoRows if the order's status
+// was changed concurrently.
+func (r *OrderRepository) UpdateOrderStatus(ctx context.Context, order *models.Order, newStatus string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.UpdateOrderStatusTx(ctx, tx, order, newStatus); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateOrderStatusTx is UpdateOrderStatus within a caller-owned transaction,
+// used by event handlers. It returns sql.ErrNoRows when the order no longer
+// has the status it was read with.
+func (r *OrderRepository) UpdateOrderStatusTx(ctx context.Context, tx *sql.Tx, order *models.Order, newStatus string) error {
+	now := time.Now()
+	res, err := tx.ExecContext(ctx,
+		`UPDATE orders SET status = $3, updated_at = $4 WHERE id = $1 AND status = $2`,
+		order.ID, order.Status, newStatus, now)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := insertStatusEvent(ctx, tx, order.ID, order.Status, newStatus, now); err != nil {
+		return err
+	}
+
+	evt, err := events.New(events.OrderStatusChanged, EventSource, "order", order.ID, events.OrderStatusChangedPayload{
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		OldStatus: order.Status,
+		NewStatus: newStatus,
+		Items:     itemPayloads(order.Items),
+	})
+	if err != nil {
+		return err
+	}
+	return events.Write(ctx, tx, evt)
+}
+
+func insertStatusEvent(ctx context.Context, tx *sql.Tx, orderID, previous, status string, at time.Time) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO order_status_events (order_id, previous_status, status, occurred_at)
+		VALUES ($1, $2, $3, $4)
+	`, orderID, previous, status, at)
+	return err
+}
+
+// ListStatusEvents returns the status history of an order after the event
+// afterID, oldest first.
+func (r *OrderRepository) ListStatusEvents(ctx context.Context, orderID string, afterID int64) ([]models.OrderStatusEvent, error) {
+	query := `
+		SELECT id, order_id, previous_status, status, occurred_at
+		FROM order_status_events
+		WHERE order_id = $1 AND id > $2
+		ORDER BY id
+	`
+
+	var statusEvents []models.OrderStatusEvent
+	if err := r.db.SelectContext(ctx, &statusEvents, query, orderID, afterID); err != nil {
+		return nil, err
+	}
+	return statusEvents, nil
+}
+
+func itemPayloads(items []models.OrderItem) []events.OrderItemPayload {
+	payloads := make([]events.OrderItemPayload, len(items))
+	for i, item := range items {
+		payloads[i] = events.OrderItemPayload{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			Price:     item.Price,
+		}
+	}
+	return payloads
+}
+
 type OrderItemRepository struct {
 	db *sqlx.DB
 }
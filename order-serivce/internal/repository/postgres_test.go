@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestListOrdersByUsers(t *testing.T) {
+	const (
+		u1 = "6f1c7a52-3a0b-4f5e-9d7e-2b8a4c1d0e9f"
+		u2 = "0b6c2a7e-5d4f-4a1b-9c3e-7f8a9b0c1d2e"
+		o1 = "11111111-1111-4111-8111-111111111111"
+		o2 = "22222222-2222-4222-8222-222222222222"
+	)
+	counts := func(rows ...any) *sqlmock.Rows {
+		r := sqlmock.NewRows([]string{"user_id", "total"})
+		for i := 0; i < len(rows); i += 2 {
+			r.AddRow(rows[i], rows[i+1])
+		}
+		return r
+	}
+	orders := func(ids ...string) *sqlmock.Rows {
+		r := sqlmock.NewRows([]string{"id", "user_id", "subtotal", "shipping_method_id", "shipping_cost",
+			"shipping_address", "billing_address", "total", "status", "created_at"})
+		for i := 0; i < len(ids); i += 2 {
+			r.AddRow(ids[i], ids[i+1], 10.0, "", 0.0, nil, nil, 10.0, "pending", time.Now())
+		}
+		return r
+	}
+	items := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"order_id", "product_id", "quantity", "price", "weight", "product_name", "product_description"}).
+			AddRow(o1, "p1", 2, 5.0, 0.5, "Mug", "")
+	}
+
+	tests := []struct {
+		name       string
+		userIDs    []string
+		expect     func(mock sqlmock.Sqlmock)
+		wantOrders map[string]int
+		wantTotals map[string]int
+	}{
+		{
+			name:    "pages with items",
+			userIDs: []string{u1, u2},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT user_id, COUNT").WillReturnRows(counts(u1, 1))
+				mock.ExpectQuery("ROW_NUMBER").WithArgs(sqlmock.AnyArg(), 0, 10).WillReturnRows(orders(o1, u1))
+				mock.ExpectQuery("FROM order_items").WillReturnRows(items())
+				mock.ExpectCommit()
+			},
+			wantOrders: map[string]int{u1: 1},
+			wantTotals: map[string]int{u1: 1},
+		},
+		{
+			// Cannot happen within one snapshot, but must not panic
+			name:    "order without a counted user",
+			userIDs: []string{u1, u2},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT user_id, COUNT").WillReturnRows(counts(u1, 1))
+				mock.ExpectQuery("ROW_NUMBER").WillReturnRows(orders(o1, u1, o2, u2))
+				mock.ExpectQuery("FROM order_items").WillReturnRows(items())
+				mock.ExpectCommit()
+			},
+			wantOrders: map[string]int{u1: 1, u2: 1},
+			wantTotals: map[string]int{u1: 1, u2: 0},
+		},
+		{
+			name:    "IDs as requested",
+			userIDs: []string{strings.ToUpper(u1)},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT user_id, COUNT").WillReturnRows(counts(u1, 1))
+				mock.ExpectQuery("ROW_NUMBER").WillReturnRows(orders(o1, u1))
+				mock.ExpectQuery("FROM order_items").WillReturnRows(items())
+				mock.ExpectCommit()
+			},
+			wantOrders: map[string]int{u1: 1, strings.ToUpper(u1): 1},
+			wantTotals: map[string]int{u1: 1, strings.ToUpper(u1): 1},
+		},
+		{
+			name:    "no orders",
+			userIDs: []string{u2},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT user_id, COUNT").WillReturnRows(counts())
+				mock.ExpectQuery("ROW_NUMBER").WillReturnRows(orders())
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:    "IDs that are not UUIDs",
+			userIDs: []string{"nope"},
+			expect:  func(mock sqlmock.Sqlmock) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			tt.expect(mock)
+
+			result, err := NewOrderRepository(sqlx.NewDb(db, "postgres")).ListOrdersByUsers(context.Background(), tt.userIDs, 10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result) != len(tt.wantOrders) {
+				t.Errorf("pages for %d users, want %d", len(result), len(tt.wantOrders))
+			}
+			for id, want := range tt.wantOrders {
+				page, ok := result[id]
+				if !ok {
+					t.Errorf("no page for %s", id)
+					continue
+				}
+				if len(page.Orders) != want || page.Total != tt.wantTotals[id] {
+					t.Errorf("page of %s has %d orders of %d, want %d of %d", id, len(page.Orders), page.Total, want, tt.wantTotals[id])
+				}
+			}
+			if page := result[u1]; page != nil && len(page.Orders[0].Items) != 1 {
+				t.Errorf("order items %+v, want the mug", page.Orders[0].Items)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
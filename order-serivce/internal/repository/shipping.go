@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/best-microservice/order-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type ShippingRepository struct {
+	db *sqlx.DB
+}
+
+func NewShippingRepository(db *sqlx.DB) *ShippingRepository {
+	return &ShippingRepository{db: db}
+}
+
+func (r *ShippingRepository) ListShippingMethods(ctx context.Context) ([]*models.ShippingMethod, error) {
+	query := `
+		SELECT id, code, name, carrier, rule_type, base_cost, tiers, active
+		FROM shipping_methods
+		WHERE active
+		ORDER BY base_cost ASC, name ASC
+	`
+
+	var methods []*models.ShippingMethod
+	err := r.db.SelectContext(ctx, &methods, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return methods, nil
+}
+
+func (r *ShippingRepository) GetShippingMethodByID(ctx context.Context, id string) (*models.ShippingMethod, error) {
+	query := `
+		SELECT id, code, name, carrier, rule_type, base_cost, tiers, active
+		FROM shipping_methods
+		WHERE id = $1
+	`
+
+	var method models.ShippingMethod
+	err := r.db.GetContext(ctx, &method, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &method, nil
+}
+
+// CreateShipment stores the shipment together with its initial tracking event.
+func (r *ShippingRepository) CreateShipment(ctx context.Context, shipment *models.Shipment) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO shipments (id, order_id, carrier, tracking_number, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+	`
+
+	shipment.ID = uuid.New().String()
+	shipment.CreatedAt = time.Now()
+
+	_, err = tx.ExecContext(ctx, query,
+		shipment.ID, shipment.OrderID, shipment.Carrier, shipment.TrackingNumber,
+		shipment.Status, shipment.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	event := models.ShipmentEvent{Status: shipment.Status, OccurredAt: shipment.CreatedAt}
+	if err := insertShipmentEvent(ctx, tx, shipment.ID, event); err != nil {
+		return err
+	}
+	shipment.Events = []models.ShipmentEvent{event}
+
+	return tx.Commit()
+}
+
+// UpdateShipmentStatus moves the shipment to a new status and appends the
+// change to its tracking history.
+func (r *ShippingRepository) UpdateShipmentStatus(ctx context.Context, shipment *models.Shipment, event models.ShipmentEvent) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE shipments
+		SET status = $2, shipped_at = $3, delivered_at = $4, updated_at = $5
+		WHERE id = $1
+	`
+
+	_, err = tx.ExecContext(ctx, query,
+		shipment.ID, event.Status, shipment.ShippedAt, shipment.DeliveredAt, event.OccurredAt)
+	if err != nil {
+		return err
+	}
+
+	if err := insertShipmentEvent(ctx, tx, shipment.ID, event); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *ShippingRepository) GetShipment(ctx context.Context, orderID, id string) (*models.Shipment, error) {
+	query := `
+		SELECT id, order_id, carrier, tracking_number, status, shipped_at, delivered_at, created_at
+		FROM shipments
+		WHERE id = $1 AND order_id = $2
+	`
+
+	var shipment models.Shipment
+	err := r.db.GetContext(ctx, &shipment, query, id, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadEvents(ctx, &shipment); err != nil {
+		return nil, err
+	}
+
+	return &shipment, nil
+}
+
+func (r *ShippingRepository) ListShipmentsByOrderID(ctx context.Context, orderID string) ([]models.Shipment, error) {
+	query := `
+		SELECT id, order_id, carrier, tracking_number, status, shipped_at, delivered_at, created_at
+		FROM shipments
+		WHERE order_id = $1
+		ORDER BY created_at ASC
+	`
+
+	var shipments []models.Shipment
+	err := r.db.SelectContext(ctx, &shipments, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range shipments {
+		if err := r.loadEvents(ctx, &shipments[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return shipments, nil
+}
+
+func (r *ShippingRepository) loadEvents(ctx context.Context, shipment *models.Shipment) error {
+	query := `
+		SELECT status, note, occurred_at
+		FROM shipment_events
+		WHERE shipment_id = $1
+		ORDER BY occurred_at ASC
+	`
+
+	return r.db.SelectContext(ctx, &shipment.Events, query, shipment.ID)
+}
+
+func insertShipmentEvent(ctx context.Context, tx *sqlx.Tx, shipmentID string, event models.ShipmentEvent) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO shipment_events (shipment_id, status, note, occurred_at)
+		VALUES ($1, $2, $3, $4)
+	`, shipmentID, event.Status, event.Note, event.OccurredAt)
+	return err
+}
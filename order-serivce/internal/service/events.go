@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/best-microservice/common/events"
+	"github.com/best-microservice/order-service/internal/models"
+)
+
+// RegisterEventHandlers subscribes the order service to product events:
+// orders whose stock could not be reserved are cancelled.
+func (s *OrderService) RegisterEventHandlers(c *events.Consumer) {
+	c.Handle(events.StockReservationFailed, s.cancelUnreserved)
+}
+
+func (s *OrderService) cancelUnreserved(ctx context.Context, tx *sql.Tx, event events.Event) error {
+	var payload events.StockReservationFailedPayload
+	if err := event.Decode(&payload); err != nil {
+		return events.Permanent(err)
+	}
+
+	order, err := s.repo.GetOrderByID(ctx, payload.OrderID)
+	if err == sql.ErrNoRows {
+		return events.Permanent(fmt.Errorf("%w: %s", ErrOrderNotFound, payload.OrderID))
+	}
+	if err != nil {
+		return err
+	}
+	if !canTransition(orderTransitions, order.Status, models.OrderStatusCancelled) {
+		slog.WarnContext(ctx, "stock reservation failed for an order that cannot be cancelled",
+			"order_id", order.ID, "status", order.Status, "reason", payload.Reason)
+		return nil
+	}
+
+	slog.InfoContext(ctx, "cancelling order without stock",
+		"order_id", order.ID, "product_id", payload.ProductID, "reason", payload.Reason)
+	// sql.ErrNoRows means the status changed since it was read; retry
+	return s.repo.UpdateOrderStatusTx(ctx, tx, order, models.OrderStatusCancelled)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/best-microservice/order-service/internal/models"
+)
+
+var ErrUnknownExpansion = errors.New("unknown expansion")
+
+// Expansion names the related data included in order responses.
+type Expansion struct {
+	// Snapshot includes the products as they were when the order was placed.
+	Snapshot bool
+	// Products includes the products as they are now.
+	Products bool
+	// User includes the user who placed the order.
+	User bool
+}
+
+// Expansions accepted by ParseExpansion.
+const (
+	ExpandSnapshot = "items.snapshot"
+	ExpandProducts = "items.product"
+	ExpandUser     = "user"
+)
+
+// ParseExpansion reads expansion names, each value holding one or more
+// comma separated names.
+func ParseExpansion(values []string) (Expansion, error) {
+	var e Expansion
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			switch strings.TrimSpace(name) {
+			case "":
+			case ExpandSnapshot:
+				e.Snapshot = true
+			case ExpandProducts:
+				e.Products = true
+			case ExpandUser:
+				e.User = true
+			default:
+				return Expansion{}, fmt.Errorf("%w %q", ErrUnknownExpansion, name)
+			}
+		}
+	}
+	return e, nil
+}
+
+// Expand fetches the current products and users e asks for and attaches
+// them to orders. Products are looked up in one call for all orders, each
+// user once. Products and users that no longer exist are left unset.
+func (s *OrderService) Expand(ctx context.Context, orders []*models.Order, e Expansion) error {
+	if ids := productIDs(orders); e.Products && len(ids) > 0 {
+		products, err := s.products.GetProducts(ctx, ids)
+		if err != nil {
+			return fmt.Errorf("look up products: %w", err)
+		}
+		for _, o := range orders {
+			for i := range o.Items {
+				o.Items[i].Product = products[o.Items[i].ProductID]
+			}
+		}
+	}
+
+	if e.User {
+		users := map[string]*models.UserSummary{}
+		for _, o := range orders {
+			user, ok := users[o.UserID]
+			if !ok {
+				var err error
+				user, err = s.users.GetUser(ctx, o.UserID)
+				if err != nil {
+					return fmt.Errorf("look up user %s: %w", o.UserID, err)
+				}
+				users[o.UserID] = user
+			}
+			o.User = user
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/best-microservice/order-service/internal/models"
+)
+
+// countingCatalog counts the GetProducts calls made through it.
+type countingCatalog struct {
+	fakeCatalog
+	calls int
+}
+
+func (c *countingCatalog) GetProducts(ctx context.Context, ids []string) (map[string]*models.ProductSummary, error) {
+	c.calls++
+	return c.fakeCatalog.GetProducts(ctx, ids)
+}
+
+// countingUsers counts the GetUser calls made through it.
+type countingUsers struct {
+	fakeUsers
+	calls int
+}
+
+func (c *countingUsers) GetUser(ctx context.Context, id string) (*models.UserSummary, error) {
+	c.calls++
+	return c.fakeUsers.GetUser(ctx, id)
+}
+
+func TestParseExpansion(t *testing.T) {
+	tests := []struct {
+		values  []string
+		want    Expansion
+		wantErr bool
+	}{
+		{nil, Expansion{}, false},
+		{[]string{"items.snapshot"}, Expansion{Snapshot: true}, false},
+		{[]string{"items.product, user", "items.snapshot"}, Expansion{Snapshot: true, Products: true, User: true}, false},
+		{[]string{"user,"}, Expansion{User: true}, false},
+		{[]string{"items"}, Expansion{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseExpansion(tt.values)
+		if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrUnknownExpansion)) {
+			t.Errorf("ParseExpansion(%q) error = %v, wantErr %v", tt.values, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseExpansion(%q) = %+v, want %+v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	newOrders := func() []*models.Order {
+		return []*models.Order{
+			{ID: "o1", UserID: "u1", Items: []models.OrderItem{{ProductID: "p1"}, {ProductID: "p2"}}},
+			{ID: "o2", UserID: "u1", Items: []models.OrderItem{{ProductID: "p2"}}},
+			{ID: "o3", UserID: "u2", Items: []models.OrderItem{{ProductID: "p3"}, {ProductID: "gone"}}},
+		}
+	}
+	tests := []struct {
+		name             string
+		expansion        Expansion
+		wantProductCalls int
+		wantUserCalls    int
+		wantProductsSet  bool
+		wantUsersSet     bool
+	}{
+		{"nothing", Expansion{Snapshot: true}, 0, 0, false, false},
+		{"products of all orders in one call", Expansion{Products: true}, 1, 0, true, false},
+		{"each user once", Expansion{User: true}, 0, 2, false, true},
+		{"both", Expansion{Products: true, User: true}, 1, 2, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog := &countingCatalog{fakeCatalog: fakeCatalog{
+				"p1": {ID: "p1", Name: "Kettle"},
+				"p2": {ID: "p2", Name: "Mug"},
+				"p3": {ID: "p3", Name: "Teapot"},
+			}}
+			users := &countingUsers{fakeUsers: fakeUsers{
+				"u1": {ID: "u1", Name: "Ada"},
+				"u2": {ID: "u2", Name: "Grace"},
+			}}
+			s := &OrderService{users: users, products: catalog}
+
+			orders := newOrders()
+			if err := s.Expand(context.Background(), orders, tt.expansion); err != nil {
+				t.Fatal(err)
+			}
+			if catalog.calls != tt.wantProductCalls {
+				t.Errorf("GetProducts calls = %d, want %d", catalog.calls, tt.wantProductCalls)
+			}
+			if users.calls != tt.wantUserCalls {
+				t.Errorf("GetUser calls = %d, want %d", users.calls, tt.wantUserCalls)
+			}
+			for _, o := range orders {
+				if (o.User != nil) != tt.wantUsersSet || (o.User != nil && o.User.ID != o.UserID) {
+					t.Errorf("order %s user = %+v", o.ID, o.User)
+				}
+				for _, item := range o.Items {
+					want := tt.wantProductsSet && item.ProductID != "gone"
+					if (item.Product != nil) != want || (item.Product != nil && item.Product.ID != item.ProductID) {
+						t.Errorf("order %s item %s product = %+v", o.ID, item.ProductID, item.Product)
+					}
+				}
+			}
+		})
+	}
+}
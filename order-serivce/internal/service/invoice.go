@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/best-microservice/order-service/internal/invoice"
+	"github.com/best-microservice/order-service/internal/models"
+	"github.com/best-microservice/order-service/internal/repository"
+	"github.com/lib/pq"
+)
+
+var ErrOrderNotInvoiceable = errors.New("order is not paid")
+
+// invoiceableStatuses are the order statuses for which an invoice may be issued.
+var invoiceableStatuses = map[string]bool{
+	models.OrderStatusPaid:      true,
+	models.OrderStatusShipped:   true,
+	models.OrderStatusDelivered: true,
+}
+
+type InvoiceService struct {
+	repo      *repository.InvoiceRepository
+	orderRepo *repository.OrderRepository
+	renderer  *invoice.Renderer
+	taxRate   float64
+	currency  string
+}
+
+// NewInvoiceService creates the invoice service. Order prices are treated as
+// tax-inclusive, so taxRate is only used to show the tax share of the total.
+func NewInvoiceService(repo *repository.InvoiceRepository, orderRepo *repository.OrderRepository,
+	renderer *invoice.Renderer, taxRate float64, currency string) *InvoiceService {
+	return &InvoiceService{
+		repo:      repo,
+		orderRepo: orderRepo,
+		renderer:  renderer,
+		taxRate:   taxRate,
+		currency:  currency,
+	}
+}
+
+// GetInvoice returns the invoice of an order, issuing it first if the order
+// has been paid but not invoiced yet.
+func (s *InvoiceService) GetInvoice(ctx context.Context, orderID string) (*models.Invoice, error) {
+	inv, err := s.repo.GetInvoiceByOrderID(ctx, orderID)
+	if err == nil {
+		return inv, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	order, err := s.orderRepo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+
+	return s.IssueInvoice(ctx, order)
+}
+
+// IssueInvoice creates the invoice for a paid order. Issuing is idempotent: if
+// another request invoiced the order concurrently, that invoice is returned.
+func (s *InvoiceService) IssueInvoice(ctx context.Context, order *models.Order) (*models.Invoice, error) {
+	if !invoiceableStatuses[order.Status] {
+		return nil, ErrOrderNotInvoiceable
+	}
+
+	inv := s.buildInvoice(order)
+	err := s.repo.CreateInvoice(ctx, inv, s.render)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return s.repo.GetInvoiceByOrderID(ctx, order.ID)
+		}
+		return nil, fmt.Errorf("failed to issue invoice: %w", err)
+	}
+
+	return inv, nil
+}
+
+func (s *InvoiceService) buildInvoice(order *models.Order) *models.Invoice {
+	inv := &models.Invoice{
+		OrderID:         order.ID,
+		Currency:        s.currency,
+		Subtotal:        order.Subtotal,
+		Shipping:        order.ShippingCost,
+		Total:           order.Total,
+		TaxRate:         s.taxRate,
+		BillingAddress:  order.BillingAddress,
+		ShippingAddress: order.ShippingAddress,
+	}
+
+	for _, item := range order.Items {
+		// Orders placed before products were snapshotted have no name
+		description := item.ProductName
+		if description == "" {
+			description = item.ProductID
+		}
+		inv.Lines = append(inv.Lines, models.InvoiceLine{
+			Description: description,
+			ProductID:   item.ProductID,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.Price,
+			Amount:      roundMoney(item.Price * float64(item.Quantity)),
+		})
+	}
+
+	// Whatever the order total doesn't account for was taken off as a discount
+	inv.Discount = roundMoney(math.Max(0, inv.Subtotal+inv.Shipping-inv.Total))
+	inv.Tax = roundMoney(inv.Total - inv.Total/(1+s.taxRate))
+
+	return inv
+}
+
+func (s *InvoiceService) render(inv *models.Invoice) error {
+	html, err := s.renderer.RenderHTML(inv)
+	if err != nil {
+		return err
+	}
+	pdf, err := s.renderer.RenderPDF(inv)
+	if err != nil {
+		return err
+	}
+
+	inv.HTML = html
+	inv.PDF = pdf
+	return nil
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/best-microservice/order-service/internal/models"
+)
+
+func TestBuildInvoice(t *testing.T) {
+	s := &InvoiceService{taxRate: 0.2, currency: "EUR"}
+	tests := []struct {
+		name        string
+		order       models.Order
+		wantDesc    []string
+		wantDisc    float64
+		wantTax     float64
+		wantAmounts []float64
+	}{
+		{
+			name: "snapshotted names",
+			order: models.Order{
+				Items: []models.OrderItem{
+					{ProductID: "p1", ProductName: "Kettle", Quantity: 2, Price: 30},
+					{ProductID: "p2", ProductName: "Mug", Quantity: 3, Price: 8.333},
+				},
+				Subtotal: 85, ShippingCost: 5, Total: 90,
+			},
+			wantDesc:    []string{"Kettle", "Mug"},
+			wantAmounts: []float64{60, 25},
+			wantTax:     15,
+		},
+		{
+			name: "old order without snapshot falls back to the product ID",
+			order: models.Order{
+				Items:    []models.OrderItem{{ProductID: "p1", Quantity: 1, Price: 12}},
+				Subtotal: 12, Total: 12,
+			},
+			wantDesc:    []string{"p1"},
+			wantAmounts: []float64{12},
+			wantTax:     2,
+		},
+		{
+			name: "discount is what the total does not account for",
+			order: models.Order{
+				Items:    []models.OrderItem{{ProductID: "p1", ProductName: "Kettle", Quantity: 1, Price: 100}},
+				Subtotal: 100, ShippingCost: 8, Total: 96,
+			},
+			wantDesc:    []string{"Kettle"},
+			wantAmounts: []float64{100},
+			wantDisc:    12,
+			wantTax:     16,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := s.buildInvoice(&tt.order)
+			if len(inv.Lines) != len(tt.wantDesc) {
+				t.Fatalf("got %d lines, want %d", len(inv.Lines), len(tt.wantDesc))
+			}
+			for i, line := range inv.Lines {
+				if line.Description != tt.wantDesc[i] {
+					t.Errorf("line %d description = %q, want %q", i, line.Description, tt.wantDesc[i])
+				}
+				if line.Amount != tt.wantAmounts[i] {
+					t.Errorf("line %d amount = %v, want %v", i, line.Amount, tt.wantAmounts[i])
+				}
+			}
+			if inv.Discount != tt.wantDisc {
+				t.Errorf("Discount = %v, want %v", inv.Discount, tt.wantDisc)
+			}
+			if inv.Tax != tt.wantTax {
+				t.Errorf("Tax = %v, want %v", inv.Tax, tt.wantTax)
+			}
+			if inv.Currency != "EUR" {
+				t.Errorf("Currency = %q, want EUR", inv.Currency)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"github.com/best-microservice/common/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	ordersCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "orders_created_total",
+		Help:      "Orders successfully created.",
+	})
+
+	orderValue = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Name:      "order_value",
+		Help:      "Total value of created orders, including shipping.",
+		Buckets:   []float64{10, 25, 50, 100, 250, 500, 1000, 2500, 5000},
+	})
+)
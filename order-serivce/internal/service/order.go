@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/best-microservice/order-service/internal/models"
 	"github.com/best-microservice/order-service/internal/repository"
@@ -14,15 +16,39 @@ var (
 	ErrOrderNotFound     = errors.New("order not found")
 	ErrProductNotFound   = errors.New("product not found")
 	ErrUserNotFound      = errors.New("user not found")
+	ErrEmailNotVerified  = errors.New("email address not verified")
 	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrInvalidStatus     = errors.New("invalid order status")
 )
 
+// orderTransitions lists the statuses an order may move to from each status.
+var orderTransitions = map[string][]string{
+	models.OrderStatusPending:   {models.OrderStatusPaid, models.OrderStatusCancelled},
+	models.OrderStatusPaid:      {models.OrderStatusShipped, models.OrderStatusCancelled},
+	models.OrderStatusShipped:   {models.OrderStatusDelivered},
+	models.OrderStatusDelivered: {},
+	models.OrderStatusCancelled: {},
+}
+
 type OrderService struct {
-	repo *repository.OrderRepository
+	repo         *repository.OrderRepository
+	shippingRepo *repository.ShippingRepository
+	invoices     *InvoiceService
+	users        UserDirectory
+	products     ProductCatalog
+	watchers     *watchers
+	// watchPoll is how often WatchOrder looks for status changes it was not
+	// notified of.
+	watchPoll time.Duration
+	// requireVerifiedEmail refuses orders from users who have not verified
+	// their email address.
+	requireVerifiedEmail bool
 }
 
-func NewOrderService(repo *repository.OrderRepository) *OrderService {
-	return &OrderService{repo: repo}
+func NewOrderService(repo *repository.OrderRepository, shippingRepo *repository.ShippingRepository, invoices *InvoiceService,
+	users UserDirectory, products ProductCatalog, watchPoll time.Duration, requireVerifiedEmail bool) *OrderService {
+	return &OrderService{repo: repo, shippingRepo: shippingRepo, invoices: invoices, users: users, products: products,
+		watchers: newWatchers(), watchPoll: watchPoll, requireVerifiedEmail: requireVerifiedEmail}
 }
 
 func (s *OrderService) CreateOrder(ctx context.Context, order *models.Order) error {
@@ -34,7 +60,56 @@ func (s *OrderService) CreateOrder(ctx context.Context, order *models.Order) err
 		return err
 	}
 
-	return s.repo.CreateOrder(ctx, order)
+	if err := s.checkReferences(ctx, order); err != nil {
+		return err
+	}
+
+	if err := s.applyShipping(ctx, order); err != nil {
+		return err
+	}
+
+	if err := s.repo.CreateOrder(ctx, order); err != nil {
+		return err
+	}
+	ordersCreated.Inc()
+	orderValue.Observe(order.Total)
+	return nil
+}
+
+// applyShipping prices the selected shipping method and adds it to the order total.
+func (s *OrderService) applyShipping(ctx context.Context, order *models.Order) error {
+	var subtotal, weight float64
+	for _, item := range order.Items {
+		subtotal += item.Price * float64(item.Quantity)
+		weight += item.Weight * float64(item.Quantity)
+	}
+	order.Subtotal = subtotal
+	order.Total = subtotal
+
+	if order.BillingAddress == nil {
+		order.BillingAddress = order.ShippingAddress
+	}
+	if order.ShippingMethodID == "" {
+		return nil
+	}
+	if order.ShippingAddress == nil {
+		return ErrShippingAddressRequired
+	}
+
+	method, err := s.shippingRepo.GetShippingMethodByID(ctx, order.ShippingMethodID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrShippingMethodNotFound
+		}
+		return err
+	}
+	if !method.Active {
+		return ErrShippingMethodNotFound
+	}
+
+	order.ShippingCost = ShippingCost(method, subtotal, weight)
+	order.Total = subtotal + order.ShippingCost
+	return nil
 }
 
 func (s *OrderService) GetOder(ctx context.Context, id string) (*models.Order, error) {
@@ -66,60 +141,82 @@ func (s *OrderService) GetOrderByID(ctx context.Context, id string) (*models.Ord
 }
 
 func (s *OrderService) GetOrder(ctx context.Context, id string) (*models.Order, error) {
-	return nil, fmt.Errorf("GetOrder not implemented")
+	order, err := s.repo.GetOrderByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	order.Shipments, err = s.shippingRepo.ListShipmentsByOrderID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shipments: %w", err)
+	}
+
+	return order, nil
+}
+
+// UpdateOrderStatus moves an order to a new status. Orders that become paid are
+// invoiced right away; if that fails the invoice is issued on first request.
+func (s *OrderService) UpdateOrderStatus(ctx context.Context, id, newStatus string) (*models.Order, error) {
+	if _, ok := orderTransitions[newStatus]; !ok {
+		return nil, ErrInvalidStatus
+	}
+
+	order, err := s.repo.GetOrderByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if !canTransition(orderTransitions, order.Status, newStatus) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidStatus, order.Status, newStatus)
+	}
+
+	if err := s.repo.UpdateOrderStatus(ctx, order, newStatus); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: order was modified concurrently", ErrInvalidStatus)
+		}
+		return nil, fmt.Errorf("failed to update order status: %w", err)
+	}
+	order.Status = newStatus
+
+	if newStatus == models.OrderStatusPaid {
+		if _, err := s.invoices.IssueInvoice(ctx, order); err != nil {
+			slog.ErrorContext(ctx, "failed to issue invoice", "order_id", order.ID, "error", err)
+		}
+	}
+
+	return order, nil
 }
+
+// GetUserOrders returns a page of the orders of a user, newest first, and
+// the number of orders the user has. Unknown users are ErrUserNotFound.
 func (s *OrderService) GetUserOrders(ctx context.Context, userID string, limit, offset int) ([]*models.Order, int, error) {
-	return nil, 0, fmt.Errorf("GetUserOrders not implemented")
+	user, err := s.users.GetUser(ctx, userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("look up user %s: %w", userID, err)
+	}
+	if user == nil {
+		return nil, 0, ErrUserNotFound
+	}
+
+	byUser, err := s.repo.ListOrdersByUsers(ctx, []string{userID}, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get user orders: %w", err)
+	}
+	page, ok := byUser[userID]
+	if !ok {
+		return nil, 0, nil
+	}
+	return page.Orders, page.Total, nil
 }
 
-// func (s *OrderService) GetOrder(ctx context.Context, id string) (*models.Order, error) {
-// 	order, err := s.repo.GetOrderByID(ctx, id)
-// 	if err != nil {
-// 		if err == repository.ErrNotFound {
-// 			return nil, ErrOrderNotFound
-// 		}
-// 		return nil, fmt.Errorf("failed to get order: %w", err)
-// 	}
-
-// 	// Enrich order items with product details
-// 	for i := range order.Items {
-// 		product, err := s.repo.GetProductByID(ctx, order.Items[i].ProductID)
-// 		if err != nil {
-// 			return nil, fmt.Errorf("failed to get product details: %w", err)
-// 		}
-// 		order.Items[i].ProductName = product.Name
-// 		order.Items[i].ProductDescription = product.Description
-// 	}
-
-// 	return order, nil
-// }
-
-// func (s *OrderService) GetUserOrders(ctx context.Context, userID string, limit, offset int) ([]*models.Order, int, error) {
-// 	// Validate user exists
-// 	_, err := s.userRepo.GetUserByID(ctx, userID)
-// 	if err != nil {
-// 		if err == repository.ErrNotFound {
-// 			return nil, 0, ErrUserNotFound
-// 		}
-// 		return nil, 0, fmt.Errorf("failed to get user: %w", err)
-// 	}
-
-// 	orders, total, err := s.orderRepo.GetOrdersByUserID(ctx, userID, limit, offset)
-// 	if err != nil {
-// 		return nil, 0, fmt.Errorf("failed to get user orders: %w", err)
-// 	}
-
-// 	// Enrich order items with product details
-// 	for _, order := range orders {
-// 		for i := range order.Items {
-// 			product, err := s.productRepo.GetProductByID(ctx, order.Items[i].ProductID)
-// 			if err != nil {
-// 				return nil, 0, fmt.Errorf("failed to get product details: %w", err)
-// 			}
-// 			order.Items[i].ProductName = product.Name
-// 			order.Items[i].ProductDescription = product.Description
-// 		}
-// 	}
-
-// 	return orders, total, nil
-// }
+// GetOrdersByUsers returns a page of the orders of each user, newest first.
+// Users are not looked up; those without orders have none in the result.
+func (s *OrderService) GetOrdersByUsers(ctx context.Context, userIDs []string, limit, offset int) (map[string]*repository.UserOrders, error) {
+	return s.repo.ListOrdersByUsers(ctx, userIDs, limit, offset)
+}
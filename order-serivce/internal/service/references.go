@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/best-microservice/order-service/internal/models"
+)
+
+// UserDirectory resolves user IDs owned by the user service. Orders store
+// them without a foreign key, so they are validated here instead.
+type UserDirectory interface {
+	// GetUser returns nil if the user does not exist.
+	GetUser(ctx context.Context, id string) (*models.UserSummary, error)
+}
+
+// ProductCatalog resolves product IDs owned by the product service.
+type ProductCatalog interface {
+	// GetProducts leaves unknown IDs out of the result.
+	GetProducts(ctx context.Context, ids []string) (map[string]*models.ProductSummary, error)
+}
+
+// checkReferences verifies that the user and every product of the order
+// exist, and snapshots the products into the order items. Prices and
+// weights come from the catalog so callers cannot set their own. When
+// verified email addresses are required, the user must have one.
+func (s *OrderService) checkReferences(ctx context.Context, order *models.Order) error {
+	user, err := s.users.GetUser(ctx, order.UserID)
+	if err != nil {
+		return fmt.Errorf("look up user %s: %w", order.UserID, err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if s.requireVerifiedEmail && !user.EmailVerified {
+		return ErrEmailNotVerified
+	}
+
+	products, err := s.products.GetProducts(ctx, productIDs([]*models.Order{order}))
+	if err != nil {
+		return fmt.Errorf("look up products: %w", err)
+	}
+	for i := range order.Items {
+		item := &order.Items[i]
+		product, ok := products[item.ProductID]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrProductNotFound, item.ProductID)
+		}
+		item.ProductName = product.Name
+		item.ProductDescription = product.Description
+		item.Price = product.Price
+		item.Weight = product.Weight
+	}
+	return nil
+}
+
+// productIDs returns the distinct products of the items of orders.
+func productIDs(orders []*models.Order) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, o := range orders {
+		for _, item := range o.Items {
+			if !seen[item.ProductID] {
+				seen[item.ProductID] = true
+				ids = append(ids, item.ProductID)
+			}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/best-microservice/order-service/internal/models"
+)
+
+type fakeUsers map[string]*models.UserSummary
+
+func (f fakeUsers) GetUser(_ context.Context, id string) (*models.UserSummary, error) {
+	return f[id], nil
+}
+
+type fakeCatalog map[string]*models.ProductSummary
+
+func (f fakeCatalog) GetProducts(_ context.Context, ids []string) (map[string]*models.ProductSummary, error) {
+	found := map[string]*models.ProductSummary{}
+	for _, id := range ids {
+		if p, ok := f[id]; ok {
+			found[id] = p
+		}
+	}
+	return found, nil
+}
+
+func TestCheckReferences(t *testing.T) {
+	s := &OrderService{
+		users: fakeUsers{
+			"u1": {ID: "u1", EmailVerified: true},
+			"u2": {ID: "u2"},
+		},
+		products: fakeCatalog{
+			"p1": {ID: "p1", Name: "Kettle", Price: 30, Weight: 1.2},
+			"p2": {ID: "p2", Name: "Mug", Price: 8, Weight: 0.4},
+		},
+	}
+	tests := []struct {
+		name           string
+		userID         string
+		items          []models.OrderItem
+		verifiedOnly   bool
+		wantErr        error
+		wantSubtotal   float64
+		wantItemWeight float64
+	}{
+		{
+			name:   "catalog price and weight replace caller values",
+			userID: "u1",
+			items: []models.OrderItem{
+				{ProductID: "p1", Quantity: 2, Price: 0.01, Weight: 0},
+				{ProductID: "p2", Quantity: 1, Price: 0.01, Weight: 0},
+			},
+			wantSubtotal:   68,
+			wantItemWeight: 1.2,
+		},
+		{
+			name:    "unknown user",
+			userID:  "nobody",
+			items:   []models.OrderItem{{ProductID: "p1", Quantity: 1}},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name:    "unknown product",
+			userID:  "u1",
+			items:   []models.OrderItem{{ProductID: "p9", Quantity: 1}},
+			wantErr: ErrProductNotFound,
+		},
+		{
+			name:         "unverified email",
+			userID:       "u2",
+			items:        []models.OrderItem{{ProductID: "p1", Quantity: 1}},
+			verifiedOnly: true,
+			wantErr:      ErrEmailNotVerified,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.requireVerifiedEmail = tt.verifiedOnly
+			order := &models.Order{UserID: tt.userID, Items: tt.items}
+			err := s.checkReferences(context.Background(), order)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkReferences() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if err := s.applyShipping(context.Background(), order); err != nil {
+				t.Fatal(err)
+			}
+			if order.Subtotal != tt.wantSubtotal {
+				t.Errorf("Subtotal = %v, want %v", order.Subtotal, tt.wantSubtotal)
+			}
+			if got := order.Items[0].Weight; got != tt.wantItemWeight {
+				t.Errorf("Items[0].Weight = %v, want %v", got, tt.wantItemWeight)
+			}
+			if got := order.Items[0].ProductName; got != "Kettle" {
+				t.Errorf("Items[0].ProductName = %q, want Kettle", got)
+			}
+		})
+	}
+}
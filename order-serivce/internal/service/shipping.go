@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/best-microservice/order-service/internal/models"
+	"github.com/best-microservice/order-service/internal/repository"
+)
+
+var (
+	ErrShippingMethodNotFound    = errors.New("shipping method not found")
+	ErrShippingAddressRequired   = errors.New("shipping address is required")
+	ErrShipmentNotFound          = errors.New("shipment not found")
+	ErrInvalidShipmentStatus     = errors.New("invalid shipment status")
+	ErrInvalidShipmentTransition = errors.New("invalid shipment status transition")
+)
+
+// shipmentTransitions lists the statuses a shipment may move to from each status.
+var shipmentTransitions = map[string][]string{
+	models.ShipmentStatusLabelCreated:   {models.ShipmentStatusInTransit, models.ShipmentStatusException},
+	models.ShipmentStatusInTransit:      {models.ShipmentStatusOutForDelivery, models.ShipmentStatusDelivered, models.ShipmentStatusException},
+	models.ShipmentStatusOutForDelivery: {models.ShipmentStatusDelivered, models.ShipmentStatusException},
+	models.ShipmentStatusException:      {models.ShipmentStatusInTransit, models.ShipmentStatusReturned},
+	models.ShipmentStatusDelivered:      {},
+	models.ShipmentStatusReturned:       {},
+}
+
+type ShippingService struct {
+	repo      *repository.ShippingRepository
+	orderRepo *repository.OrderRepository
+}
+
+func NewShippingService(repo *repository.ShippingRepository, orderRepo *repository.OrderRepository) *ShippingService {
+	return &ShippingService{repo: repo, orderRepo: orderRepo}
+}
+
+func (s *ShippingService) ListShippingMethods(ctx context.Context) ([]*models.ShippingMethod, error) {
+	return s.repo.ListShippingMethods(ctx)
+}
+
+func (s *ShippingService) CreateShipment(ctx context.Context, shipment *models.Shipment) error {
+	if _, err := s.orderRepo.GetOrderByID(ctx, shipment.OrderID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrOrderNotFound
+		}
+		return err
+	}
+
+	shipment.Status = models.ShipmentStatusLabelCreated
+	return s.repo.CreateShipment(ctx, shipment)
+}
+
+func (s *ShippingService) UpdateShipmentStatus(ctx context.Context, orderID, shipmentID, newStatus, note string) (*models.Shipment, error) {
+	if _, ok := shipmentTransitions[newStatus]; !ok {
+		return nil, ErrInvalidShipmentStatus
+	}
+
+	shipment, err := s.repo.GetShipment(ctx, orderID, shipmentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrShipmentNotFound
+		}
+		return nil, err
+	}
+
+	if !canTransition(shipmentTransitions, shipment.Status, newStatus) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidShipmentTransition, shipment.Status, newStatus)
+	}
+
+	now := time.Now()
+	if newStatus == models.ShipmentStatusInTransit && shipment.ShippedAt == nil {
+		shipment.ShippedAt = &now
+	}
+	if newStatus == models.ShipmentStatusDelivered {
+		shipment.DeliveredAt = &now
+	}
+
+	event := models.ShipmentEvent{Status: newStatus, Note: note, OccurredAt: now}
+	if err := s.repo.UpdateShipmentStatus(ctx, shipment, event); err != nil {
+		return nil, err
+	}
+
+	shipment.Status = newStatus
+	shipment.Events = append(shipment.Events, event)
+	return shipment, nil
+}
+
+func (s *ShippingService) ListShipments(ctx context.Context, orderID string) ([]models.Shipment, error) {
+	return s.repo.ListShipmentsByOrderID(ctx, orderID)
+}
+
+func canTransition(transitions map[string][]string, from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ShippingCost applies the method's cost rule to an order. Flat methods always
+// cost the base amount; weight and total methods use the highest tier whose
+// minimum is reached, falling back to the base cost below the first tier.
+func ShippingCost(method *models.ShippingMethod, subtotal, weight float64) float64 {
+	var measure float64
+	switch method.RuleType {
+	case models.ShippingRuleWeight:
+		measure = weight
+	case models.ShippingRuleTotal:
+		measure = subtotal
+	default:
+		return method.BaseCost
+	}
+
+	cost := method.BaseCost
+	best := math.Inf(-1)
+	for _, tier := range method.Tiers {
+		if measure >= tier.Min && tier.Min > best {
+			best = tier.Min
+			cost = tier.Cost
+		}
+	}
+	return cost
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/best-microservice/order-service/internal/models"
+)
+
+func TestShippingCost(t *testing.T) {
+	tiers := models.ShippingRateTiers{
+		{Min: 10, Cost: 12},
+		{Min: 0, Cost: 5},
+		{Min: 2, Cost: 8},
+	}
+	tests := []struct {
+		name     string
+		method   models.ShippingMethod
+		subtotal float64
+		weight   float64
+		want     float64
+	}{
+		{"flat ignores tiers", models.ShippingMethod{RuleType: models.ShippingRuleFlat, BaseCost: 4, Tiers: tiers}, 100, 100, 4},
+		{"weight first tier", models.ShippingMethod{RuleType: models.ShippingRuleWeight, BaseCost: 4, Tiers: tiers}, 100, 1.5, 5},
+		{"weight middle tier", models.ShippingMethod{RuleType: models.ShippingRuleWeight, BaseCost: 4, Tiers: tiers}, 100, 2, 8},
+		{"weight highest tier", models.ShippingMethod{RuleType: models.ShippingRuleWeight, BaseCost: 4, Tiers: tiers}, 0, 25, 12},
+		{"total uses subtotal", models.ShippingMethod{RuleType: models.ShippingRuleTotal, BaseCost: 4, Tiers: tiers}, 10, 0, 12},
+		{"below every tier", models.ShippingMethod{RuleType: models.ShippingRuleTotal, BaseCost: 4, Tiers: models.ShippingRateTiers{{Min: 50, Cost: 0}}}, 49.99, 0, 4},
+		{"no tiers", models.ShippingMethod{RuleType: models.ShippingRuleWeight, BaseCost: 6}, 0, 3, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShippingCost(&tt.method, tt.subtotal, tt.weight); got != tt.want {
+				t.Errorf("ShippingCost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
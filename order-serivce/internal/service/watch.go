@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/best-microservice/common/events"
+
+	"github.com/best-microservice/order-service/internal/models"
+)
+
+// watchers wakes the WatchOrder calls of an order when its status changes.
+// Notifications only reach watchers of this replica, so watchers also poll
+// the status history to pick up changes made elsewhere.
+type watchers struct {
+	mu     sync.Mutex
+	orders map[string]map[chan struct{}]struct{}
+}
+
+func newWatchers() *watchers {
+	return &watchers{orders: make(map[string]map[chan struct{}]struct{})}
+}
+
+// add registers a watcher of orderID. The returned channel receives a value
+// when the order changes; remove must be called once the watcher is done.
+func (w *watchers) add(orderID string) (ch chan struct{}, remove func()) {
+	ch = make(chan struct{}, 1)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.orders[orderID] == nil {
+		w.orders[orderID] = make(map[chan struct{}]struct{})
+	}
+	w.orders[orderID][ch] = struct{}{}
+
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.orders[orderID], ch)
+		if len(w.orders[orderID]) == 0 {
+			delete(w.orders, orderID)
+		}
+	}
+}
+
+func (w *watchers) notify(orderID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.orders[orderID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// StatusChanged handles OrderStatusChanged events by waking the watchers of
+// the order.
+func (s *OrderService) StatusChanged(ctx context.Context, event events.Event) error {
+	s.watchers.notify(event.AggregateID)
+	return nil
+}
+
+// CheckOrderOwner returns ErrOrderNotFound unless the order exists and was
+// placed by userID, so callers cannot tell other users' orders apart from
+// missing ones.
+func (s *OrderService) CheckOrderOwner(ctx context.Context, orderID, userID string) error {
+	order, err := s.repo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrOrderNotFound
+		}
+		return fmt.Errorf("failed to get order: %w", err)
+	}
+	if order.UserID != userID {
+		return ErrOrderNotFound
+	}
+	return nil
+}
+
+// WatchOrder sends the status events of an order after afterID, then each
+// new one as it happens, until ctx is done or the order reaches a final
+// status. Changes are picked up when this replica publishes them, and
+// otherwise within the poll interval.
+func (s *OrderService) WatchOrder(ctx context.Context, orderID string, afterID int64, send func(models.OrderStatusEvent) error) error {
+	changed, remove := s.watchers.add(orderID)
+	defer remove()
+
+	poll := time.NewTicker(s.watchPoll)
+	defer poll.Stop()
+
+	for resumed := afterID > 0; ; resumed = false {
+		statusEvents, err := s.repo.ListStatusEvents(ctx, orderID, afterID)
+		if err != nil {
+			return fmt.Errorf("failed to list status events: %w", err)
+		}
+		for _, e := range statusEvents {
+			if err := send(e); err != nil {
+				return err
+			}
+			afterID = e.ID
+			if final(e.Status) {
+				return nil
+			}
+		}
+		if resumed && len(statusEvents) == 0 {
+			// A resumed watch of a finished order has nothing left to wait for
+			order, err := s.repo.GetOrderByID(ctx, orderID)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					return ErrOrderNotFound
+				}
+				return fmt.Errorf("failed to get order: %w", err)
+			}
+			if final(order.Status) {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		case <-poll.C:
+		}
+	}
+}
+
+// final reports whether no transitions lead out of status.
+func final(status string) bool {
+	return len(orderTransitions[status]) == 0
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/best-microservice/common/apierror"
 	"github.com/best-microservice/common/protos/order"
 	"github.com/best-microservice/order-service/internal/models"
 	"github.com/best-microservice/order-service/internal/service"
@@ -17,90 +18,115 @@ import (
 
 type OrderServer struct {
 	order.UnimplementedOrderServiceServer
-	service *service.OrderService
+	service         *service.OrderService
+	shippingService *service.ShippingService
+	invoiceService  *service.InvoiceService
 }
 
-func NewOrderServer(service *service.OrderService) *OrderServer {
-	return &OrderServer{service: service}
+func NewOrderServer(service *service.OrderService, shippingService *service.ShippingService, invoiceService *service.InvoiceService) *OrderServer {
+	return &OrderServer{service: service, shippingService: shippingService, invoiceService: invoiceService}
 }
 
 func (s *OrderServer) CreateOrder(ctx context.Context, req *order.CreateOrderRequest) (*order.OrderResponse, error) {
 	// Validate request
 	if req.UserId == "" {
-		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+		return nil, apierror.Invalid("user_id", "is required")
 	}
 	if len(req.Items) == 0 {
-		return nil, status.Error(codes.InvalidArgument, "at least one order item is required")
+		return nil, apierror.Invalid("items", "must contain at least one item")
 	}
 
 	// Convert protobuf items to models
 	var orderItems []models.OrderItem
-	var total float64
 
-	for _, item := range req.Items {
+	seen := make(map[string]bool, len(req.Items))
+	for i, item := range req.Items {
 		if item.Quantity <= 0 {
-			return nil, status.Errorf(codes.InvalidArgument, "invalid quantity for product %s", item.ProductId)
+			return nil, apierror.Invalid(fmt.Sprintf("items[%d].quantity", i), "must be positive")
 		}
-		if item.Price <= 0 {
-			return nil, status.Errorf(codes.InvalidArgument, "invalid price for product %s", item.ProductId)
+		// An order has one item per product
+		if seen[item.ProductId] {
+			return nil, apierror.Invalid(fmt.Sprintf("items[%d].product_id", i), "is already ordered by another item, raise its quantity instead")
 		}
+		seen[item.ProductId] = true
 
+		// Price and weight are taken from the product catalog
 		orderItems = append(orderItems, models.OrderItem{
 			ProductID: item.ProductId,
 			Quantity:  int(item.Quantity),
-			Price:     float64(item.Price),
 		})
-
-		total += float64(item.Price) * float64(item.Quantity)
 	}
 
-	// Create order model
+	// Create order model; totals and shipping cost are computed by the service
 	newOrder := &models.Order{
-		ID:     uuid.New().String(),
-		UserID: req.UserId,
-		Items:  orderItems,
-		Total:  total,
-		Status: "pending",
+		ID:               uuid.New().String(),
+		UserID:           req.UserId,
+		Items:            orderItems,
+		ShippingMethodID: req.ShippingMethodId,
+		ShippingAddress:  addressFromProto(req.ShippingAddress),
+		BillingAddress:   addressFromProto(req.BillingAddress),
+		Status:           "pending",
 	}
 
 	// Call service layer
 	err := s.service.CreateOrder(ctx, newOrder)
 	if err != nil {
 		if errors.Is(err, service.ErrProductNotFound) {
-			return nil, status.Error(codes.NotFound, "product not found")
+			return nil, apierror.New(codes.NotFound, apierror.ReasonProductNotFound, err.Error())
 		}
 		if errors.Is(err, service.ErrUserNotFound) {
-			return nil, status.Error(codes.NotFound, "user not found")
+			return nil, apierror.NotFound(apierror.ReasonUserNotFound, "user", req.UserId)
+		}
+		if errors.Is(err, service.ErrEmailNotVerified) {
+			return nil, apierror.New(codes.FailedPrecondition, apierror.ReasonEmailNotVerified, "the user must verify their email address before ordering")
 		}
 		if errors.Is(err, service.ErrInsufficientStock) {
-			return nil, status.Error(codes.FailedPrecondition, "insufficient stock")
+			return nil, apierror.New(codes.FailedPrecondition, apierror.ReasonInsufficientStock, "insufficient stock")
+		}
+		if errors.Is(err, service.ErrShippingMethodNotFound) {
+			return nil, apierror.New(codes.InvalidArgument, apierror.ReasonShippingMethodNotFound, "shipping method not found",
+				apierror.WithFieldViolation("shipping_method_id", "does not name an active shipping method"))
+		}
+		if errors.Is(err, service.ErrShippingAddressRequired) {
+			return nil, apierror.Invalid("shipping_address", "is required with a shipping method")
+		}
+		// The user or product service could not be reached
+		if code := status.Code(err); code == codes.Unavailable || code == codes.DeadlineExceeded {
+			return nil, apierror.Unavailable("order references could not be verified, try again later", time.Second)
 		}
 		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to create order: %v", err))
 	}
 
 	// Convert back to protobuf response
-	return s.orderToResponse(newOrder), nil
+	return s.orderToResponse(newOrder, service.Expansion{}), nil
 }
 
 func (s *OrderServer) GetOrder(ctx context.Context, req *order.GetOrderRequest) (*order.OrderResponse, error) {
 	if req.Id == "" {
-		return nil, status.Error(codes.InvalidArgument, "order id is required")
+		return nil, apierror.Invalid("id", "is required")
+	}
+	expand, err := service.ParseExpansion(req.Expand)
+	if errors.Is(err, service.ErrUnknownExpansion) {
+		return nil, apierror.Invalid("expand", expandDescription)
 	}
 
 	order, err := s.service.GetOrder(ctx, req.Id)
 	if err != nil {
 		if errors.Is(err, service.ErrOrderNotFound) {
-			return nil, status.Error(codes.NotFound, "order not found")
+			return nil, apierror.NotFound(apierror.ReasonOrderNotFound, "order", req.Id)
 		}
 		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to get order: %v", err))
 	}
+	if err := s.service.Expand(ctx, []*models.Order{order}, expand); err != nil {
+		return nil, expansionError(err)
+	}
 
-	return s.orderToResponse(order), nil
+	return s.orderToResponse(order, expand), nil
 }
 
 func (s *OrderServer) GetUserOrders(ctx context.Context, req *order.GetUserOrdersRequest) (*order.GetUserOrdersResponse, error) {
 	if req.UserId == "" {
-		return nil, status.Error(codes.InvalidArgument, "user id is required")
+		return nil, apierror.Invalid("user_id", "is required")
 	}
 
 	if req.Limit <= 0 {
@@ -109,19 +135,31 @@ func (s *OrderServer) GetUserOrders(ctx context.Context, req *order.GetUserOrder
 	if req.Offset < 0 {
 		req.Offset = 0
 	}
+	expand, err := service.ParseExpansion(req.Expand)
+	if errors.Is(err, service.ErrUnknownExpansion) {
+		return nil, apierror.Invalid("expand", expandDescription)
+	}
 
 	orders, total, err := s.service.GetUserOrders(ctx, req.UserId, int(req.Limit), int(req.Offset))
 	if err != nil {
 		if errors.Is(err, service.ErrUserNotFound) {
-			return nil, status.Error(codes.NotFound, "user not found")
+			return nil, apierror.NotFound(apierror.ReasonUserNotFound, "user", req.UserId)
+		}
+		// The user service could not be reached; the caller may retry
+		if st, ok := status.FromError(err); ok && (st.Code() == codes.Unavailable || st.Code() == codes.DeadlineExceeded) {
+			return nil, apierror.New(st.Code(), apierror.ReasonUnavailable, "the user could not be looked up, try again later",
+				apierror.WithRetryDelay(time.Second))
 		}
 		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to get user orders: %v", err))
 	}
+	if err := s.service.Expand(ctx, orders, expand); err != nil {
+		return nil, expansionError(err)
+	}
 
 	// Convert orders to protobuf response
 	var pbOrders []*order.OrderResponse
 	for _, o := range orders {
-		pbOrders = append(pbOrders, s.orderToResponse(o))
+		pbOrders = append(pbOrders, s.orderToResponse(o, expand))
 	}
 
 	return &order.GetUserOrdersResponse{
@@ -130,22 +168,165 @@ func (s *OrderServer) GetUserOrders(ctx context.Context, req *order.GetUserOrder
 	}, nil
 }
 
-func (s *OrderServer) orderToResponse(o *models.Order) *order.OrderResponse {
+// maxUserBatch bounds the number of users a GetOrdersByUsers call may ask for.
+const maxUserBatch = 100
+
+func (s *OrderServer) GetOrdersByUsers(ctx context.Context, req *order.GetOrdersByUsersRequest) (*order.GetOrdersByUsersResponse, error) {
+	if len(req.UserIds) > maxUserBatch {
+		return nil, apierror.Invalid("user_ids", fmt.Sprintf("must not contain more than %d IDs", maxUserBatch))
+	}
+	if req.Limit <= 0 {
+		req.Limit = 10
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+	expand, err := service.ParseExpansion(req.Expand)
+	if errors.Is(err, service.ErrUnknownExpansion) {
+		return nil, apierror.Invalid("expand", expandDescription)
+	}
+
+	byUser, err := s.service.GetOrdersByUsers(ctx, req.UserIds, int(req.Limit), int(req.Offset))
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to get user orders: %v", err))
+	}
+	// Expanded together, so all products cost one lookup
+	var orders []*models.Order
+	for _, page := range byUser {
+		orders = append(orders, page.Orders...)
+	}
+	if err := s.service.Expand(ctx, orders, expand); err != nil {
+		return nil, expansionError(err)
+	}
+
+	res := &order.GetOrdersByUsersResponse{Users: make([]*order.GetUserOrdersResponse, len(req.UserIds))}
+	for i, id := range req.UserIds {
+		entry := &order.GetUserOrdersResponse{}
+		if page, ok := byUser[id]; ok {
+			entry.Total = int32(page.Total)
+			for _, o := range page.Orders {
+				entry.Orders = append(entry.Orders, s.orderToResponse(o, expand))
+			}
+		}
+		res.Users[i] = entry
+	}
+	return res, nil
+}
+
+func (s *OrderServer) UpdateOrderStatus(ctx context.Context, req *order.UpdateOrderStatusRequest) (*order.OrderResponse, error) {
+	if req.Id == "" {
+		return nil, apierror.Invalid("id", "is required")
+	}
+	if req.Status == "" {
+		return nil, apierror.Invalid("status", "is required")
+	}
+
+	o, err := s.service.UpdateOrderStatus(ctx, req.Id, req.Status)
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			return nil, apierror.NotFound(apierror.ReasonOrderNotFound, "order", req.Id)
+		}
+		if errors.Is(err, service.ErrInvalidStatus) {
+			return nil, apierror.New(codes.FailedPrecondition, apierror.ReasonInvalidStatus, err.Error())
+		}
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to update order status: %v", err))
+	}
+
+	return s.orderToResponse(o, service.Expansion{}), nil
+}
+
+// expandDescription is the field violation of unknown expansions.
+const expandDescription = "must only name " + service.ExpandSnapshot + ", " + service.ExpandProducts + " or " + service.ExpandUser
+
+// expansionError reports a failed lookup of expanded data. The order itself
+// was found, so an unreachable user or product service is worth a retry.
+func expansionError(err error) error {
+	if code := status.Code(err); code == codes.Unavailable || code == codes.DeadlineExceeded {
+		return apierror.Unavailable("expanded order data could not be loaded, try again later", time.Second)
+	}
+	return status.Error(codes.Internal, fmt.Sprintf("failed to expand order: %v", err))
+}
+
+// orderToResponse converts o, including the related data e asks for.
+// Expanded products and users were attached to o by OrderService.Expand.
+func (s *OrderServer) orderToResponse(o *models.Order, e service.Expansion) *order.OrderResponse {
 	var items []*order.OrderItem
 	for _, item := range o.Items {
-		items = append(items, &order.OrderItem{
+		pbItem := &order.OrderItem{
 			ProductId: item.ProductID,
 			Quantity:  int32(item.Quantity),
 			Price:     float32(item.Price),
-		})
+			Weight:    float32(item.Weight),
+		}
+		// Orders placed before snapshots were taken have none
+		if e.Snapshot && item.ProductName != "" {
+			pbItem.Snapshot = &order.ProductSnapshot{Name: item.ProductName, Description: item.ProductDescription}
+		}
+		if p := item.Product; e.Products && p != nil {
+			pbItem.Product = &order.ProductSummary{
+				Id:          p.ID,
+				Name:        p.Name,
+				Description: p.Description,
+				Price:       float32(p.Price),
+				Stock:       int32(p.Stock),
+			}
+		}
+		items = append(items, pbItem)
 	}
 
-	return &order.OrderResponse{
-		Id:        o.ID,
-		UserId:    o.UserID,
-		Items:     items,
-		Total:     float32(o.Total),
-		Status:    o.Status,
-		CreatedAt: o.CreatedAt.Format(time.RFC3339),
+	var shipments []*order.Shipment
+	for i := range o.Shipments {
+		shipments = append(shipments, shipmentToResponse(&o.Shipments[i]))
+	}
+
+	res := &order.OrderResponse{
+		Id:               o.ID,
+		UserId:           o.UserID,
+		Items:            items,
+		Total:            float32(o.Total),
+		Status:           o.Status,
+		CreatedAt:        o.CreatedAt.Format(time.RFC3339),
+		Subtotal:         float32(o.Subtotal),
+		ShippingAddress:  addressToProto(o.ShippingAddress),
+		BillingAddress:   addressToProto(o.BillingAddress),
+		ShippingMethodId: o.ShippingMethodID,
+		ShippingCost:     float32(o.ShippingCost),
+		Shipments:        shipments,
+	}
+	if u := o.User; e.User && u != nil {
+		res.User = &order.UserSummary{Id: u.ID, Name: u.Name, Email: u.Email}
+	}
+	return res
+}
+
+func addressFromProto(a *order.Address) *models.Address {
+	if a == nil {
+		return nil
+	}
+	return &models.Address{
+		Recipient:  a.Recipient,
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		State:      a.State,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+		Phone:      a.Phone,
+	}
+}
+
+func addressToProto(a *models.Address) *order.Address {
+	if a == nil {
+		return nil
+	}
+	return &order.Address{
+		Recipient:  a.Recipient,
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		State:      a.State,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+		Phone:      a.Phone,
 	}
 }
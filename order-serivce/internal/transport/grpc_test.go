@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/best-microservice/common/apierror"
+	"github.com/best-microservice/common/protos/order"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/best-microservice/order-service/internal/models"
+	"github.com/best-microservice/order-service/internal/service"
+)
+
+// failingUsers answers every lookup with err, or with no user if err is nil.
+type failingUsers struct{ err error }
+
+func (f failingUsers) GetUser(context.Context, string) (*models.UserSummary, error) {
+	return nil, f.err
+}
+
+func TestGetUserOrdersUserLookup(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{"unknown user", nil, codes.NotFound, apierror.ReasonUserNotFound},
+		{"user service unavailable", status.Error(codes.Unavailable, "connection refused"), codes.Unavailable, apierror.ReasonUnavailable},
+		{"user service too slow", status.Error(codes.DeadlineExceeded, "context deadline exceeded"), codes.DeadlineExceeded, apierror.ReasonUnavailable},
+		{"unexpected error", errors.New("boom"), codes.Internal, ""},
+		{"unexpected status", status.Error(codes.PermissionDenied, "client not allowed"), codes.Internal, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := service.NewOrderService(nil, nil, nil, failingUsers{tt.err}, nil, 0, false)
+			_, err := NewOrderServer(svc, nil, nil).GetUserOrders(context.Background(), &order.GetUserOrdersRequest{UserId: "u1"})
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("code = %s, want %s (%v)", got, tt.wantCode, err)
+			}
+			if got := apierror.Reason(err); got != tt.wantReason {
+				t.Errorf("reason = %q, want %q", got, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestCreateOrderDuplicateProducts(t *testing.T) {
+	req := &order.CreateOrderRequest{
+		UserId: "u1",
+		Items: []*order.OrderItem{
+			{ProductId: "p1", Quantity: 1},
+			{ProductId: "p2", Quantity: 1},
+			{ProductId: "p1", Quantity: 2},
+		},
+	}
+	_, err := NewOrderServer(nil, nil, nil).CreateOrder(context.Background(), req)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("CreateOrder() = %v, want InvalidArgument", err)
+	}
+	for _, d := range status.Convert(err).Details() {
+		if d, ok := d.(*errdetails.BadRequest); ok && d.FieldViolations[0].Field == "items[2].product_id" {
+			return
+		}
+	}
+	t.Errorf("CreateOrder() = %v, want a violation of items[2].product_id", err)
+}
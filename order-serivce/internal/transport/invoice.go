@@ -0,0 +1,60 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/best-microservice/common/apierror"
+	"github.com/best-microservice/common/protos/order"
+	"github.com/best-microservice/order-service/internal/service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *OrderServer) GetInvoice(ctx context.Context, req *order.GetInvoiceRequest) (*order.Invoice, error) {
+	if req.OrderId == "" {
+		return nil, apierror.Invalid("order_id", "is required")
+	}
+	if req.Format == "" {
+		req.Format = "pdf"
+	}
+	if req.Format != "pdf" && req.Format != "html" {
+		return nil, apierror.Invalid("format", "must be pdf or html")
+	}
+
+	inv, err := s.invoiceService.GetInvoice(ctx, req.OrderId)
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			return nil, apierror.NotFound(apierror.ReasonOrderNotFound, "order", req.OrderId)
+		}
+		if errors.Is(err, service.ErrOrderNotInvoiceable) {
+			return nil, apierror.New(codes.FailedPrecondition, apierror.ReasonOrderNotInvoiceable, "order has not been paid")
+		}
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to get invoice: %v", err))
+	}
+
+	res := &order.Invoice{
+		Id:       inv.ID,
+		OrderId:  inv.OrderID,
+		Number:   inv.Number,
+		IssuedAt: inv.IssuedAt.Format(time.RFC3339),
+		Currency: inv.Currency,
+		Subtotal: float32(inv.Subtotal),
+		Discount: float32(inv.Discount),
+		Tax:      float32(inv.Tax),
+		Shipping: float32(inv.Shipping),
+		Total:    float32(inv.Total),
+	}
+	if req.Format == "html" {
+		res.ContentType = "text/html; charset=utf-8"
+		res.Content = inv.HTML
+	} else {
+		res.ContentType = "application/pdf"
+		res.Content = inv.PDF
+	}
+
+	return res, nil
+}
@@ -0,0 +1,143 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/best-microservice/common/apierror"
+	"github.com/best-microservice/common/protos/order"
+	"github.com/best-microservice/order-service/internal/models"
+	"github.com/best-microservice/order-service/internal/service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *OrderServer) ListShippingMethods(ctx context.Context, req *order.ListShippingMethodsRequest) (*order.ListShippingMethodsResponse, error) {
+	methods, err := s.shippingService.ListShippingMethods(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to list shipping methods: %v", err))
+	}
+
+	var pbMethods []*order.ShippingMethod
+	for _, m := range methods {
+		var tiers []*order.ShippingRateTier
+		for _, t := range m.Tiers {
+			tiers = append(tiers, &order.ShippingRateTier{Min: float32(t.Min), Cost: float32(t.Cost)})
+		}
+		pbMethods = append(pbMethods, &order.ShippingMethod{
+			Id:       m.ID,
+			Code:     m.Code,
+			Name:     m.Name,
+			Carrier:  m.Carrier,
+			RuleType: m.RuleType,
+			BaseCost: float32(m.BaseCost),
+			Tiers:    tiers,
+		})
+	}
+
+	return &order.ListShippingMethodsResponse{Methods: pbMethods}, nil
+}
+
+func (s *OrderServer) CreateShipment(ctx context.Context, req *order.CreateShipmentRequest) (*order.Shipment, error) {
+	if req.OrderId == "" {
+		return nil, apierror.Invalid("order_id", "is required")
+	}
+	if req.Carrier == "" {
+		return nil, apierror.Invalid("carrier", "is required")
+	}
+	if req.TrackingNumber == "" {
+		return nil, apierror.Invalid("tracking_number", "is required")
+	}
+
+	shipment := &models.Shipment{
+		OrderID:        req.OrderId,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+	}
+
+	err := s.shippingService.CreateShipment(ctx, shipment)
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			return nil, apierror.NotFound(apierror.ReasonOrderNotFound, "order", req.OrderId)
+		}
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to create shipment: %v", err))
+	}
+
+	return shipmentToResponse(shipment), nil
+}
+
+func (s *OrderServer) UpdateShipmentStatus(ctx context.Context, req *order.UpdateShipmentStatusRequest) (*order.Shipment, error) {
+	if req.OrderId == "" {
+		return nil, apierror.Invalid("order_id", "is required")
+	}
+	if req.ShipmentId == "" {
+		return nil, apierror.Invalid("shipment_id", "is required")
+	}
+
+	shipment, err := s.shippingService.UpdateShipmentStatus(ctx, req.OrderId, req.ShipmentId, req.Status, req.Note)
+	if err != nil {
+		if errors.Is(err, service.ErrShipmentNotFound) {
+			return nil, apierror.NotFound(apierror.ReasonShipmentNotFound, "shipment", req.ShipmentId)
+		}
+		if errors.Is(err, service.ErrInvalidShipmentStatus) {
+			return nil, apierror.Invalid("status", fmt.Sprintf("%q is not a shipment status", req.Status))
+		}
+		if errors.Is(err, service.ErrInvalidShipmentTransition) {
+			return nil, apierror.New(codes.FailedPrecondition, apierror.ReasonInvalidStatus, err.Error())
+		}
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to update shipment: %v", err))
+	}
+
+	return shipmentToResponse(shipment), nil
+}
+
+func (s *OrderServer) ListShipments(ctx context.Context, req *order.ListShipmentsRequest) (*order.ListShipmentsResponse, error) {
+	if req.OrderId == "" {
+		return nil, apierror.Invalid("order_id", "is required")
+	}
+
+	shipments, err := s.shippingService.ListShipments(ctx, req.OrderId)
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to list shipments: %v", err))
+	}
+
+	var pbShipments []*order.Shipment
+	for i := range shipments {
+		pbShipments = append(pbShipments, shipmentToResponse(&shipments[i]))
+	}
+
+	return &order.ListShipmentsResponse{Shipments: pbShipments}, nil
+}
+
+func shipmentToResponse(sh *models.Shipment) *order.Shipment {
+	var events []*order.ShipmentEvent
+	for _, e := range sh.Events {
+		events = append(events, &order.ShipmentEvent{
+			Status:     e.Status,
+			Note:       e.Note,
+			OccurredAt: e.OccurredAt.Format(time.RFC3339),
+		})
+	}
+
+	return &order.Shipment{
+		Id:             sh.ID,
+		OrderId:        sh.OrderID,
+		Carrier:        sh.Carrier,
+		TrackingNumber: sh.TrackingNumber,
+		Status:         sh.Status,
+		ShippedAt:      formatOptionalTime(sh.ShippedAt),
+		DeliveredAt:    formatOptionalTime(sh.DeliveredAt),
+		CreatedAt:      sh.CreatedAt.Format(time.RFC3339),
+		Events:         events,
+	}
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/best-microservice/common/apierror"
+	"github.com/best-microservice/common/protos/order"
+	"github.com/best-microservice/order-service/internal/models"
+	"github.com/best-microservice/order-service/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func (s *OrderServer) WatchOrder(req *order.WatchOrderRequest, stream grpc.ServerStreamingServer[order.OrderStatusEvent]) error {
+	if req.OrderId == "" {
+		return apierror.Invalid("order_id", "is required")
+	}
+	if req.UserId == "" {
+		return apierror.Invalid("user_id", "is required")
+	}
+	if req.AfterEventId < 0 {
+		return apierror.Invalid("after_event_id", "must not be negative")
+	}
+
+	ctx := stream.Context()
+	if err := s.service.CheckOrderOwner(ctx, req.OrderId, req.UserId); err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			return apierror.NotFound(apierror.ReasonOrderNotFound, "order", req.OrderId)
+		}
+		return status.Error(codes.Internal, fmt.Sprintf("failed to get order: %v", err))
+	}
+	// Headers tell the caller the watch was accepted before the first event,
+	// which may take long to come.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	err := s.service.WatchOrder(ctx, req.OrderId, req.AfterEventId, func(e models.OrderStatusEvent) error {
+		return stream.Send(statusEventToProto(e))
+	})
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		// The caller went away, failing the watch or a send
+		return status.FromContextError(ctx.Err()).Err()
+	case errors.Is(err, service.ErrOrderNotFound):
+		return apierror.NotFound(apierror.ReasonOrderNotFound, "order", req.OrderId)
+	}
+	return status.Error(codes.Internal, fmt.Sprintf("failed to watch order: %v", err))
+}
+
+func statusEventToProto(e models.OrderStatusEvent) *order.OrderStatusEvent {
+	return &order.OrderStatusEvent{
+		Id:             e.ID,
+		OrderId:        e.OrderID,
+		Status:         e.Status,
+		PreviousStatus: e.PreviousStatus,
+		OccurredAt:     e.OccurredAt.Format(time.RFC3339),
+	}
+}
@@ -2,68 +2,209 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/best-microservice/common/apierror"
+	"github.com/best-microservice/common/events"
+	"github.com/best-microservice/common/health"
+	"github.com/best-microservice/common/logging"
+	"github.com/best-microservice/common/metrics"
+	"github.com/best-microservice/common/migrate"
+	"github.com/best-microservice/common/mtls"
+	eventspb "github.com/best-microservice/common/protos/events"
 	"github.com/best-microservice/common/protos/order"
+	"github.com/best-microservice/common/tracing"
+	"github.com/best-microservice/order-service/internal/clients"
+	"github.com/best-microservice/order-service/internal/invoice"
 	"github.com/best-microservice/order-service/internal/repository"
 	"github.com/best-microservice/order-service/internal/service"
 	"github.com/best-microservice/order-service/internal/transport"
+	"github.com/best-microservice/order-service/migrations"
 	"github.com/jmoiron/sqlx"
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"google.golang.org/grpc"
 	// "github.com/joho/godotenv"
 )
 
 func main() {
-	// Load environment variables from .env
-	if err := godotenv.Load("../.env"); err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
-
-	// Connect to the database
-	db, err := sqlx.Connect("postgres", fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-	))
+	// Configuration: defaults, YAML file, environment, flags
+	cfg, args, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(os.Stderr, "\nCommands:\n%s\n", migrate.Usage)
+		return
+	}
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	migrateOnly := len(args) > 0 && args[0] == "migrate"
+	if len(args) > 0 && !migrateOnly {
+		log.Fatalf("unknown command %q\n%s", args[0], migrate.Usage)
+	}
+
+	// Structured logging
+	logging.Setup("order-service", cfg.Log.Level, cfg.Log.Format)
+
+	// Tracing
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Connect to the database; every query is recorded as a span
+	sqlDB, err := tracing.OpenDB("postgres", cfg.Database.DSN())
+	if err != nil {
+		log.Fatalf("failed to open database: %v", err)
+	}
+	cfg.Database.ConfigurePool(sqlDB)
+	db := sqlx.NewDb(sqlDB, "postgres")
+	err = db.Ping()
 	if err != nil {
 		log.Fatalf("failed to connect to database: %v", err)
 	}
 	defer db.Close()
 
-	// Load shared schema
-	if err := loadSharedSchema(db.DB); err != nil {
-		log.Fatalf("failed to load shared schema: %v", err)
+	// Schema migrations owned by this service; "migrate" runs them and exits
+	if cfg.Database.Schema != "" {
+		if err := migrate.EnsureSchema(context.Background(), db.DB, cfg.Database.Schema); err != nil {
+			log.Fatalf("failed to create schema: %v", err)
+		}
+	}
+	migrator, err := migrate.New(db.DB, "order-service", migrations.FS)
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
 	}
+	if migrateOnly {
+		if err := migrate.Run(context.Background(), migrator, args[1:], os.Stdout); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+	if cfg.MigrateOnStart {
+		n, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatalf("failed to apply migrations: %v", err)
+		}
+		slog.Info("database migrated", "applied", n, "version", migrator.Latest())
+	}
+
+	// TLS credentials for the server and outgoing connections, reloaded from disk
+	creds, err := mtls.New(cfg.TLS)
+	if err != nil {
+		log.Fatalf("failed to load TLS certificates: %v", err)
+	}
+	credsCtx, stopCreds := context.WithCancel(context.Background())
+	go creds.Run(credsCtx)
+
+	metrics.RegisterDB(db.DB, "order")
+
+	// Prometheus metrics
+	metricsServer := metrics.Serve(cfg.MetricsAddr)
 
 	// Initialize repository and service
 	orderRepo := repository.NewOrderRepository(db)
-	orderService := service.NewOrderService(orderRepo)
+	shippingRepo := repository.NewShippingRepository(db)
+	invoiceRepo := repository.NewInvoiceRepository(db)
+
+	renderer, err := invoice.NewRenderer(invoice.Seller{
+		Name:    cfg.Invoice.SellerName,
+		Address: splitNonEmpty(cfg.Invoice.SellerAddress, ";"),
+		TaxID:   cfg.Invoice.SellerTaxID,
+	})
+	if err != nil {
+		log.Fatalf("failed to create invoice renderer: %v", err)
+	}
+
+	invoiceService := service.NewInvoiceService(invoiceRepo, orderRepo, renderer, cfg.Invoice.TaxRate, cfg.Invoice.Currency)
+	// Users and products live in other services; orders validate their IDs there
+	dialOpts := []grpc.DialOption{
+		tracing.DialOption(),
+		grpc.WithChainUnaryInterceptor(logging.UnaryClientInterceptor(), metrics.UnaryClientInterceptor()),
+	}
+	userConn, err := grpc.Dial(cfg.UserServiceAddr, append(dialOpts, creds.DialOption("user-service"))...)
+	if err != nil {
+		log.Fatalf("did not connect to user service: %v", err)
+	}
+	defer userConn.Close()
+	productConn, err := grpc.Dial(cfg.ProductServiceAddr, append(dialOpts, creds.DialOption("product-service"))...)
+	if err != nil {
+		log.Fatalf("did not connect to product service: %v", err)
+	}
+	defer productConn.Close()
+
+	orderService := service.NewOrderService(orderRepo, shippingRepo, invoiceService,
+		clients.NewUsers(userConn), clients.NewProducts(productConn), cfg.WatchPollInterval, cfg.RequireVerifiedEmail)
+	shippingService := service.NewShippingService(shippingRepo, orderRepo)
+
+	// Domain events are dispatched through the in-process broker: our own
+	// events via the outbox relay, events of other services via Deliver.
+	broker := events.NewBroker()
+	broker.Subscribe("*", events.LogPublisher{}.Publish)
+	consumer := events.NewConsumer(db.DB, repository.EventSource, events.ConsumerConfig{})
+	orderService.RegisterEventHandlers(consumer)
+	consumer.Subscribe(broker)
+	broker.Subscribe(events.OrderStatusChanged, orderService.StatusChanged)
+
+	// Push our events to the services subscribed to them
+	var publisher events.Publisher = broker
+	if len(cfg.EventSubscribers) > 0 {
+		remote, err := events.DialPublisher(cfg.EventSubscribers,
+			creds.DialOption(""), tracing.DialOption())
+		if err != nil {
+			log.Fatalf("failed to connect to event subscribers: %v", err)
+		}
+		defer remote.Close()
+		publisher = events.FanOut{broker, remote}
+	}
+	relay := events.NewRelay(db.DB, publisher, events.RelayConfig{Source: repository.EventSource})
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	go relay.Run(relayCtx)
 
 	// Create gRPC server
-	grpcServer := grpc.NewServer()
-	orderServer := transport.NewOrderServer(orderService)
+	grpcServer := grpc.NewServer(
+		creds.ServerOption(),
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(
+			logging.UnaryServerInterceptor(),
+			creds.UnaryServerInterceptor(),
+			metrics.UnaryServerInterceptor(),
+			apierror.UnaryServerInterceptor("order-service"),
+		),
+		grpc.ChainStreamInterceptor(
+			logging.StreamServerInterceptor(),
+			creds.StreamServerInterceptor(),
+			metrics.StreamServerInterceptor(),
+			apierror.StreamServerInterceptor("order-service"),
+		),
+	)
+	orderServer := transport.NewOrderServer(orderService, shippingService, invoiceService)
 	order.RegisterOrderServiceServer(grpcServer, orderServer)
+	eventspb.RegisterEventServiceServer(grpcServer, events.NewServer(broker, consumer))
+
+	// grpc.health.v1, reporting database connectivity
+	healthChecker := health.Register(grpcServer, db.DB,
+		order.OrderService_ServiceDesc.ServiceName, eventspb.EventService_ServiceDesc.ServiceName)
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	go healthChecker.Run(healthCtx)
 
 	// Start gRPC server
-	lis, err := net.Listen("tcp", ":50053")
+	lis, err := net.Listen("tcp", cfg.ListenAddr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
 	go func() {
-		log.Println("Starting order service on :50053")
+		slog.Info("starting order service", "addr", cfg.ListenAddr)
 		if err := grpcServer.Serve(lis); err != nil {
 			log.Fatalf("failed to serve: %v", err)
 		}
@@ -74,29 +215,29 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down order service...")
+	slog.Info("shutting down order service")
+	healthChecker.Shutdown()
 	grpcServer.GracefulStop()
+	stopHealth()
+	stopCreds()
+	stopRelay()
+	metricsServer.Close()
 
 	_, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := db.Close(); err != nil {
-		log.Printf("error closing database connection: %v", err)
+		slog.Error("error closing database connection", "error", err)
 	}
-	log.Println("Order service stopped")
+	slog.Info("order service stopped")
 }
 
-// loadSharedSchema loads the shared SQL schema into the DB (for dev/local use)
-func loadSharedSchema(db *sql.DB) error {
-	schema, err := os.ReadFile("../shared/schema.sql")
-	if err != nil {
-		return fmt.Errorf("failed to read schema.sql: %w", err)
-	}
-
-	_, err = db.Exec(string(schema))
-	if err != nil {
-		return fmt.Errorf("failed to execute schema.sql: %w", err)
+// splitNonEmpty splits s by sep and drops empty parts.
+func splitNonEmpty(s, sep string) []string {
+	var parts []string
+	for _, p := range strings.Split(s, sep) {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
 	}
-
-	log.Println("Shared schema applied successfully.")
-	return nil
+	return parts
 }
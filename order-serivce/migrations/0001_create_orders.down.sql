@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS shipment_events;
+DROP TABLE IF EXISTS shipments;
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS shipping_methods;
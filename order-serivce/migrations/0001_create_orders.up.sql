@@ -0,0 +1,78 @@
+-- Orders, shipping methods and shipments. IF NOT EXISTS adopts databases
+-- created from the former shared schema.sql. Users and products belong to
+-- other services, so their IDs are stored without foreign keys.
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+-- rule_type is one of 'flat', 'weight' or 'total'; tiers is a JSON array of
+-- {"min": <kg or amount>, "cost": <amount>} used by the weight and total rules.
+CREATE TABLE IF NOT EXISTS shipping_methods (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    carrier VARCHAR(100) NOT NULL,
+    rule_type VARCHAR(20) NOT NULL CHECK (rule_type IN ('flat', 'weight', 'total')),
+    base_cost DECIMAL(10,2) NOT NULL DEFAULT 0,
+    tiers JSONB NOT NULL DEFAULT '[]',
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL,
+    subtotal DECIMAL(10,2) NOT NULL DEFAULT 0,
+    shipping_method_id UUID REFERENCES shipping_methods(id),
+    shipping_cost DECIMAL(10,2) NOT NULL DEFAULT 0,
+    shipping_address JSONB,
+    billing_address JSONB,
+    total DECIMAL(10,2) NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS subtotal DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_method_id UUID REFERENCES shipping_methods(id);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_cost DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_address JSONB;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS billing_address JSONB;
+
+CREATE TABLE IF NOT EXISTS order_items (
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL,
+    quantity INTEGER NOT NULL,
+    price DECIMAL(10,2) NOT NULL,
+    weight DECIMAL(10,3) NOT NULL DEFAULT 0,
+    PRIMARY KEY (order_id, product_id)
+);
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS weight DECIMAL(10,3) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS shipments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    carrier VARCHAR(100) NOT NULL,
+    tracking_number VARCHAR(100) NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'label_created',
+    shipped_at TIMESTAMP,
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS shipment_events (
+    id BIGSERIAL PRIMARY KEY,
+    shipment_id UUID NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+    status VARCHAR(50) NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO shipping_methods (code, name, carrier, rule_type, base_cost, tiers) VALUES
+    ('standard', 'Standard', 'postal', 'total', 4.99, '[{"min": 50, "cost": 0}]'),
+    ('express', 'Express', 'ups', 'weight', 9.99, '[{"min": 5, "cost": 14.99}, {"min": 20, "cost": 24.99}]'),
+    ('pickup', 'Store pickup', 'none', 'flat', 0, '[]')
+ON CONFLICT (code) DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders(user_id);
+CREATE INDEX IF NOT EXISTS idx_shipments_order_id ON shipments(order_id);
+CREATE INDEX IF NOT EXISTS idx_shipment_events_shipment_id ON shipment_events(shipment_id);
@@ -0,0 +1,2 @@
+-- The constraints belonged to the shared schema and are not restored.
+SELECT 1;
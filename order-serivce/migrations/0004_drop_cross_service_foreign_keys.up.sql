@@ -0,0 +1,5 @@
+-- Databases created from the former shared schema.sql reference the users and
+-- products tables of other services. Drop those constraints so each service
+-- owns its schema.
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_user_id_fkey;
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_product_id_fkey;
@@ -0,0 +1,4 @@
+-- Product details as they were when the order was placed. Orders placed
+-- before this migration have no snapshot.
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_name VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_description TEXT NOT NULL DEFAULT '';
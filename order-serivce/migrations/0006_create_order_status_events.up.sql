@@ -0,0 +1,17 @@
+-- Status history of orders, streamed by WatchOrder. The serial ID orders the
+-- events and lets clients resume after the last one they saw. Existing
+-- orders get a single event for their current status.
+CREATE TABLE IF NOT EXISTS order_status_events (
+    id BIGSERIAL PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    previous_status VARCHAR(50) NOT NULL DEFAULT '',
+    status VARCHAR(50) NOT NULL,
+    occurred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_events_order_id ON order_status_events(order_id, id);
+
+INSERT INTO order_status_events (order_id, status, occurred_at)
+SELECT o.id, o.status, o.created_at
+FROM orders o
+WHERE NOT EXISTS (SELECT 1 FROM order_status_events e WHERE e.order_id = o.id);
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/best-microservice/common/config"
+	"github.com/best-microservice/common/mtls"
+	"github.com/best-microservice/common/tracing"
+)
+
+// Config is the product service configuration. See the config package for the
+// sources and their precedence.
+type Config struct {
+	ListenAddr       string          `yaml:"listen_addr" env:"PRODUCT_LISTEN_ADDR" flag:"listen-addr" usage:"gRPC listen address"`
+	MetricsAddr      string          `yaml:"metrics_addr" env:"PRODUCT_METRICS_ADDR" flag:"metrics-addr" usage:"Prometheus /metrics listen address"`
+	EventSubscribers []string        `yaml:"event_subscribers" env:"PRODUCT_EVENT_SUBSCRIBERS" flag:"event-subscribers" usage:"comma separated gRPC addresses events are pushed to"`
+	MigrateOnStart   bool            `yaml:"migrate_on_start" env:"PRODUCT_MIGRATE_ON_START" flag:"migrate-on-start" usage:"apply pending migrations before serving"`
+	Database         config.Database `yaml:"database" env:"PRODUCT_DB_" flag:"db-"`
+	Log              config.Logging  `yaml:"log"`
+	TLS              mtls.Config     `yaml:"tls" env:"PRODUCT_TLS_" flag:"tls-"`
+	Tracing          tracing.Config  `yaml:"tracing"`
+}
+
+// loadConfig returns the configuration and the positional arguments, such as
+// the migrate subcommand.
+func loadConfig(args []string) (Config, []string, error) {
+	cfg := Config{
+		ListenAddr:     ":50052",
+		MetricsAddr:    ":9102",
+		MigrateOnStart: true,
+		Database:       config.DefaultDatabase(),
+		Log:            config.DefaultLogging(),
+		Tracing:        tracing.DefaultConfig("product-service"),
+	}
+	rest, err := config.Load("product-service", &cfg, args)
+	return cfg, rest, err
+}
+
+func (c Config) Validate() error {
+	return errors.Join(
+		config.ValidateAddr("listen_addr", c.ListenAddr),
+		config.ValidateAddr("metrics_addr", c.MetricsAddr),
+	)
+}
@@ -2,13 +2,22 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"time"
 
+	"github.com/best-microservice/common/events"
 	"github.com/best-microservice/product-service/internal/models"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// EventSource identifies this service in the events it writes to the outbox.
+const EventSource = "product-service"
+
+var ErrInsufficientStock = errors.New("insufficient stock")
+
 type ProductRepository struct {
 	db *sqlx.DB
 }
@@ -19,27 +28,112 @@ func NewProductRepository(db *sqlx.DB) *ProductRepository {
 
 func (r *ProductRepository) CreateProduct(ctx context.Context, product *models.Product) error {
 	query := `
-		INSERT INTO products (id, name, description, price, stock, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO products (id, name, description, price, stock, weight, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id
 	`
 
 	product.ID = uuid.New().String()
 	now := time.Now()
+	product.CreatedAt, product.UpdatedAt = now, now
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	err := r.db.QueryRowContext(ctx, query,
+	err = tx.QueryRowContext(ctx, query,
 		product.ID, product.Name, product.Description,
-		product.Price, product.Stock, now, now).Scan(&product.ID)
+		product.Price, product.Stock, product.Weight, now, now).Scan(&product.ID)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	evt, err := events.New(events.ProductCreated, EventSource, "product", product.ID, events.ProductCreatedPayload{
+		ProductID:   product.ID,
+		Name:        product.Name,
+		Description: product.Description,
+		Price:       product.Price,
+		Stock:       product.Stock,
+		Weight:      product.Weight,
+	})
+	if err != nil {
+		return err
+	}
+	if err := events.Write(ctx, tx, evt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AdjustStock changes the stock of a product by delta and records a
+// StockChanged event. It returns ErrInsufficientStock instead of letting the
+// stock drop below zero.
+func (r *ProductRepository) AdjustStock(ctx context.Context, id string, delta int, reason string) (*models.Product, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	product, err := r.AdjustStockTx(ctx, tx, id, delta, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// AdjustStockTx is AdjustStock within a caller-owned transaction, used by
+// event handlers that must apply the change together with their bookkeeping.
+func (r *ProductRepository) AdjustStockTx(ctx context.Context, tx *sql.Tx, id string, delta int, reason string) (*models.Product, error) {
+	var product models.Product
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, name, description, price, stock, weight, created_at, updated_at
+		FROM products
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Stock, &product.Weight, &product.CreatedAt, &product.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if product.Stock+delta < 0 {
+		return nil, ErrInsufficientStock
+	}
+	product.Stock += delta
+	product.UpdatedAt = time.Now()
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE products SET stock = $2, updated_at = $3 WHERE id = $1`, id, product.Stock, product.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	evt, err := events.New(events.StockChanged, EventSource, "product", id, events.StockChangedPayload{
+		ProductID: id,
+		Delta:     delta,
+		Stock:     product.Stock,
+		Reason:    reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := events.Write(ctx, tx, evt); err != nil {
+		return nil, err
+	}
+
+	return &product, nil
 }
 
 func (r *ProductRepository) GetProductByID(ctx context.Context, id string) (*models.Product, error) {
 	query := `
-		SELECT id, name, description, price, stock, created_at
+		SELECT id, name, description, price, stock, weight, created_at, updated_at
 		FROM products
 		WHERE id = $1
 	`
@@ -53,6 +147,24 @@ func (r *ProductRepository) GetProductByID(ctx context.Context, id string) (*mod
 	return &product, nil
 }
 
+// GetProductsByIDs returns the products with the given IDs, in no particular
+// order. Missing IDs are skipped.
+func (r *ProductRepository) GetProductsByIDs(ctx context.Context, ids []string) ([]*models.Product, error) {
+	query := `
+		SELECT id, name, description, price, stock, weight, created_at, updated_at
+		FROM products
+		WHERE id = ANY($1)
+	`
+
+	var products []*models.Product
+	err := r.db.SelectContext(ctx, &products, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
 func (r *ProductRepository) ListProducts(ctx context.Context, limit, offset int) ([]*models.Product, int, error) {
 	// Get total count
 	var total int
@@ -64,7 +176,7 @@ func (r *ProductRepository) ListProducts(ctx context.Context, limit, offset int)
 
 	// Get products
 	query := `
-		SELECT id, name, description, price, stock, created_at
+		SELECT id, name, description, price, stock, weight, created_at, updated_at
 		FROM products
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
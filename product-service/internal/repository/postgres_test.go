@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/best-microservice/product-service/internal/models"
+)
+
+// TestProductScan reads products the way each query returns them, so a
+// column without a matching field fails here rather than in production.
+func TestProductScan(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "description", "price", "stock", "weight", "created_at", "updated_at"}).
+			AddRow("p1", "Kettle", "1.7 l", 39.9, 3, 1.2, created, updated).
+			AddRow("p2", "Mug", "", 7.5, 40, 0.3, created, updated)
+	}
+	tests := []struct {
+		name      string
+		run       func(r *ProductRepository, mock sqlmock.Sqlmock) ([]*models.Product, error)
+		wantCount int
+	}{
+		{"by ID", func(r *ProductRepository, mock sqlmock.Sqlmock) ([]*models.Product, error) {
+			mock.ExpectQuery("FROM products").WithArgs("p1").WillReturnRows(rows())
+			p, err := r.GetProductByID(context.Background(), "p1")
+			return []*models.Product{p}, err
+		}, 1},
+		{"by IDs", func(r *ProductRepository, mock sqlmock.Sqlmock) ([]*models.Product, error) {
+			mock.ExpectQuery("FROM products").WillReturnRows(rows())
+			return r.GetProductsByIDs(context.Background(), []string{"p1", "p2"})
+		}, 2},
+		{"list", func(r *ProductRepository, mock sqlmock.Sqlmock) ([]*models.Product, error) {
+			mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+			mock.ExpectQuery("FROM products").WithArgs(10, 0).WillReturnRows(rows())
+			products, _, err := r.ListProducts(context.Background(), 10, 0)
+			return products, err
+		}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			products, err := tt.run(NewProductRepository(sqlx.NewDb(db, "postgres")), mock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(products) != tt.wantCount {
+				t.Fatalf("read %d products, want %d", len(products), tt.wantCount)
+			}
+			for _, p := range products {
+				if p.ID == "" || p.Price == 0 || !p.CreatedAt.Equal(created) || !p.UpdatedAt.Equal(updated) {
+					t.Errorf("product %+v was not read completely", p)
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
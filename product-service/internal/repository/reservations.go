@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/best-microservice/common/events"
+)
+
+// ReserveStockTx takes quantity units of a product out of stock for an order
+// and records the reservation in the same transaction.
+func (r *ProductRepository) ReserveStockTx(ctx context.Context, tx *sql.Tx, orderID, productID string, quantity int) error {
+	if _, err := r.AdjustStockTx(ctx, tx, productID, -quantity, "order "+orderID); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO stock_reservations (order_id, product_id, quantity)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (order_id, product_id) DO UPDATE
+		SET quantity = stock_reservations.quantity + EXCLUDED.quantity
+	`, orderID, productID, quantity)
+	return err
+}
+
+// ReleaseStockTx returns the stock reserved for an order and deletes its
+// reservations. Orders without reservations release nothing.
+func (r *ProductRepository) ReleaseStockTx(ctx context.Context, tx *sql.Tx, orderID, reason string) error {
+	reserved, err := r.deleteReservations(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+	for productID, quantity := range reserved {
+		if _, err := r.AdjustStockTx(ctx, tx, productID, quantity, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CommitStockTx deletes the reservations of an order whose stock has left
+// for good, e.g. because it was delivered.
+func (r *ProductRepository) CommitStockTx(ctx context.Context, tx *sql.Tx, orderID string) error {
+	_, err := r.deleteReservations(ctx, tx, orderID)
+	return err
+}
+
+func (r *ProductRepository) deleteReservations(ctx context.Context, tx *sql.Tx, orderID string) (map[string]int, error) {
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM stock_reservations
+		WHERE order_id = $1
+		RETURNING product_id, quantity
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reserved := map[string]int{}
+	for rows.Next() {
+		var productID string
+		var quantity int
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			return nil, err
+		}
+		reserved[productID] = quantity
+	}
+	return reserved, rows.Err()
+}
+
+// ReservationFailedTx records a StockReservationFailed event for an order.
+func (r *ProductRepository) ReservationFailedTx(ctx context.Context, tx *sql.Tx, orderID, productID, reason string) error {
+	evt, err := events.New(events.StockReservationFailed, EventSource, "order", orderID, events.StockReservationFailedPayload{
+		OrderID:   orderID,
+		ProductID: productID,
+		Reason:    reason,
+	})
+	if err != nil {
+		return err
+	}
+	return events.Write(ctx, tx, evt)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/best-microservice/common/events"
+	"github.com/best-microservice/product-service/internal/repository"
+)
+
+// RegisterEventHandlers subscribes the product service to order events:
+// stock is reserved when an order is created and released again when the
+// order is cancelled.
+func (p *ProductService) RegisterEventHandlers(c *events.Consumer) {
+	c.Handle(events.OrderCreated, p.reserveStock)
+	c.Handle(events.OrderStatusChanged, p.releaseStock)
+}
+
+// reserveStock reserves every item of a new order, or none of them. Unknown
+// products and missing stock won't fix themselves on retry, so instead of
+// failing the event they are reported back to the order service.
+func (p *ProductService) reserveStock(ctx context.Context, tx *sql.Tx, event events.Event) error {
+	var payload events.OrderCreatedPayload
+	if err := event.Decode(&payload); err != nil {
+		return events.Permanent(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT reserve_stock"); err != nil {
+		return err
+	}
+	for _, item := range payload.Items {
+		err := p.repo.ReserveStockTx(ctx, tx, payload.OrderID, item.ProductID, item.Quantity)
+		var reason error
+		switch {
+		case err == nil:
+			continue
+		case err == sql.ErrNoRows:
+			reason = ErrProductNotFound
+		case errors.Is(err, repository.ErrInsufficientStock):
+			stockOuts.WithLabelValues("order").Inc()
+			reason = ErrInsufficientStock
+		default:
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT reserve_stock"); err != nil {
+			return err
+		}
+		return p.repo.ReservationFailedTx(ctx, tx, payload.OrderID, item.ProductID, reason.Error())
+	}
+	return nil
+}
+
+// releaseStock returns the stock reserved for a cancelled order and forgets
+// the reservations of a delivered one.
+func (p *ProductService) releaseStock(ctx context.Context, tx *sql.Tx, event events.Event) error {
+	var payload events.OrderStatusChangedPayload
+	if err := event.Decode(&payload); err != nil {
+		return events.Permanent(err)
+	}
+
+	switch payload.NewStatus {
+	case "cancelled":
+		return p.repo.ReleaseStockTx(ctx, tx, payload.OrderID, "order "+payload.OrderID+" cancelled")
+	case "delivered":
+		return p.repo.CommitStockTx(ctx, tx, payload.OrderID)
+	}
+	return nil
+}
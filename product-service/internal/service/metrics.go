@@ -0,0 +1,13 @@
+package service
+
+import (
+	"github.com/best-microservice/common/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var stockOuts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Name:      "product_stock_outs_total",
+	Help:      "Stock changes rejected for lack of stock, by source.",
+}, []string{"source"})
@@ -7,6 +7,12 @@ import (
 
 	"github.com/best-microservice/product-service/internal/models"
 	"github.com/best-microservice/product-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrProductNotFound   = errors.New("product not found")
+	ErrInsufficientStock = repository.ErrInsufficientStock
 )
 
 type ProductService struct {
@@ -28,5 +34,42 @@ func (p *ProductService) CreatProduct(ctx context.Context, product *models.Produ
 }
 
 func (p *ProductService) GetProduct(ctx context.Context, id string) (*models.Product, error) {
-	return p.repo.GetProductByID(ctx, id)
+	// IDs are UUIDs; anything else cannot exist
+	if uuid.Validate(id) != nil {
+		return nil, ErrProductNotFound
+	}
+	product, err := p.repo.GetProductByID(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrProductNotFound
+	}
+	return product, err
+}
+
+// GetProducts returns the existing products among ids.
+func (p *ProductService) GetProducts(ctx context.Context, ids []string) ([]*models.Product, error) {
+	valid := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if uuid.Validate(id) == nil {
+			valid = append(valid, id)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, nil
+	}
+	return p.repo.GetProductsByIDs(ctx, valid)
+}
+
+func (p *ProductService) AdjustStock(ctx context.Context, id string, delta int, reason string) (*models.Product, error) {
+	if uuid.Validate(id) != nil {
+		return nil, ErrProductNotFound
+	}
+	product, err := p.repo.AdjustStock(ctx, id, delta, reason)
+	if err == sql.ErrNoRows {
+		return nil, ErrProductNotFound
+	}
+	if err == repository.ErrInsufficientStock {
+		stockOuts.WithLabelValues("adjustment").Inc()
+		return nil, ErrInsufficientStock
+	}
+	return product, err
 }
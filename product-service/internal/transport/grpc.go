@@ -1,8 +1,12 @@
 package transport
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/best-microservice/common/apierror"
 	productpb "github.com/best-microservice/common/protos/product"
 	"github.com/best-microservice/product-service/internal/models"
 	"github.com/best-microservice/product-service/internal/service"
@@ -21,38 +25,96 @@ func NewProductServer(service *service.ProductService) *ProductServer {
 }
 
 func (p *ProductServer) CreateProduct(ctx context.Context, req *productpb.CreateProductRequest) (*productpb.ProductResponse, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, apierror.Invalid("name", "is required")
+	}
+	if req.Price <= 0 {
+		return nil, apierror.Invalid("price", "must be greater than 0")
+	}
+	if req.Stock < 0 {
+		return nil, apierror.Invalid("stock", "must not be negative")
+	}
+	if req.Weight < 0 {
+		return nil, apierror.Invalid("weight", "must not be negative")
+	}
+
 	newProduct := &models.Product{
 		Name:        req.Name,
 		Description: req.Description,
 		Price:       float64(req.Price),
 		Stock:       int(req.Stock),
-		CreatedAt:   time.Now(),
+		Weight:      float64(req.Weight),
 	}
 	err := p.service.CreatProduct(ctx, newProduct)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "fail to creat product :%v", err)
 	}
-	return &productpb.ProductResponse{
-		Id:          newProduct.ID,
-		Name:        newProduct.Name,
-		Description: newProduct.Description,
-		Price:       float32(newProduct.Price),
-		Stock:       int32(newProduct.Stock),
-		// CreatedAt: newProduct.CreatedAt.String(),
-	}, nil
-
+	return productToResponse(newProduct), nil
 }
 
 func (p *ProductServer) GetProduct(ctx context.Context, req *productpb.GetProductRequest) (*productpb.ProductResponse, error) {
 	product, err := p.service.GetProduct(ctx, req.Id)
 	if err != nil {
-		return nil, status.Errorf(codes.NotFound, "user not found :%v", err)
+		if errors.Is(err, service.ErrProductNotFound) {
+			return nil, apierror.NotFound(apierror.ReasonProductNotFound, "product", req.Id)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to get product: %v", err)
+	}
+	return productToResponse(product), nil
+}
+
+// maxBatchSize bounds the number of IDs a single GetProducts call may ask for.
+const maxBatchSize = 500
+
+func (p *ProductServer) GetProducts(ctx context.Context, req *productpb.GetProductsRequest) (*productpb.GetProductsResponse, error) {
+	if len(req.Ids) > maxBatchSize {
+		return nil, apierror.Invalid("ids", fmt.Sprintf("must not contain more than %d IDs", maxBatchSize))
+	}
+
+	products, err := p.service.GetProducts(ctx, req.Ids)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get products: %v", err)
 	}
+
+	res := &productpb.GetProductsResponse{Products: make([]*productpb.ProductResponse, 0, len(products))}
+	for _, product := range products {
+		res.Products = append(res.Products, productToResponse(product))
+	}
+	return res, nil
+}
+
+func (p *ProductServer) AdjustStock(ctx context.Context, req *productpb.AdjustStockRequest) (*productpb.ProductResponse, error) {
+	if req.Id == "" {
+		return nil, apierror.Invalid("id", "is required")
+	}
+	if req.Delta == 0 {
+		return nil, apierror.Invalid("delta", "must not be zero")
+	}
+
+	product, err := p.service.AdjustStock(ctx, req.Id, int(req.Delta), req.Reason)
+	if err != nil {
+		if errors.Is(err, service.ErrProductNotFound) {
+			return nil, apierror.NotFound(apierror.ReasonProductNotFound, "product", req.Id)
+		}
+		if errors.Is(err, service.ErrInsufficientStock) {
+			return nil, apierror.New(codes.FailedPrecondition, apierror.ReasonInsufficientStock, "insufficient stock",
+				apierror.WithMetadata("id", req.Id))
+		}
+		return nil, status.Errorf(codes.Internal, "failed to adjust stock: %v", err)
+	}
+
+	return productToResponse(product), nil
+}
+
+func productToResponse(p *models.Product) *productpb.ProductResponse {
 	return &productpb.ProductResponse{
-		Id:          product.ID,
-		Name:        product.Name,
-		Description: product.Description,
-		Stock:       int32(product.Stock),
-		Price:       float32(product.Price),
-	}, nil
+		Id:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       float32(p.Price),
+		Stock:       int32(p.Stock),
+		Weight:      float32(p.Weight),
+		CreatedAt:   p.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   p.UpdatedAt.Format(time.RFC3339),
+	}
 }
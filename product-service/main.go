@@ -2,63 +2,175 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/best-microservice/common/apierror"
+	"github.com/best-microservice/common/events"
+	"github.com/best-microservice/common/health"
+	"github.com/best-microservice/common/logging"
+	"github.com/best-microservice/common/metrics"
+	"github.com/best-microservice/common/migrate"
+	"github.com/best-microservice/common/mtls"
+	eventspb "github.com/best-microservice/common/protos/events"
 	productpb "github.com/best-microservice/common/protos/product"
+	"github.com/best-microservice/common/tracing"
 	"github.com/best-microservice/product-service/internal/repository"
 	"github.com/best-microservice/product-service/internal/service"
 	"github.com/best-microservice/product-service/internal/transport"
+	"github.com/best-microservice/product-service/migrations"
 	"github.com/jmoiron/sqlx"
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"google.golang.org/grpc"
 )
 
 func main() {
+	// Configuration: defaults, YAML file, environment, flags
+	cfg, args, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(os.Stderr, "\nCommands:\n%s\n", migrate.Usage)
+		return
+	}
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	migrateOnly := len(args) > 0 && args[0] == "migrate"
+	if len(args) > 0 && !migrateOnly {
+		log.Fatalf("unknown command %q\n%s", args[0], migrate.Usage)
+	}
 
-	if err := godotenv.Load("../.env"); err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+	// Structured logging
+	logging.Setup("product-service", cfg.Log.Level, cfg.Log.Format)
+
+	// Tracing
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
 	}
+	defer shutdownTracing(context.Background())
 
-	// Connect to the database
-	db, err := sqlx.Connect("postgres", fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-	))
+	// Connect to the database; every query is recorded as a span
+	sqlDB, err := tracing.OpenDB("postgres", cfg.Database.DSN())
+	if err != nil {
+		log.Fatalf("failed to open database: %v", err)
+	}
+	cfg.Database.ConfigurePool(sqlDB)
+	db := sqlx.NewDb(sqlDB, "postgres")
+	err = db.Ping()
 	if err != nil {
 		log.Fatalf("failed to connect to database: %v", err)
 	}
 	defer db.Close()
 
+	// Schema migrations owned by this service; "migrate" runs them and exits
+	if cfg.Database.Schema != "" {
+		if err := migrate.EnsureSchema(context.Background(), db.DB, cfg.Database.Schema); err != nil {
+			log.Fatalf("failed to create schema: %v", err)
+		}
+	}
+	migrator, err := migrate.New(db.DB, "product-service", migrations.FS)
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+	if migrateOnly {
+		if err := migrate.Run(context.Background(), migrator, args[1:], os.Stdout); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+	if cfg.MigrateOnStart {
+		n, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatalf("failed to apply migrations: %v", err)
+		}
+		slog.Info("database migrated", "applied", n, "version", migrator.Latest())
+	}
+
+	// TLS credentials for the server and outgoing connections, reloaded from disk
+	creds, err := mtls.New(cfg.TLS)
+	if err != nil {
+		log.Fatalf("failed to load TLS certificates: %v", err)
+	}
+	credsCtx, stopCreds := context.WithCancel(context.Background())
+	go creds.Run(credsCtx)
+
+	metrics.RegisterDB(db.DB, "product")
+
+	// Prometheus metrics
+	metricsServer := metrics.Serve(cfg.MetricsAddr)
+
 	defer db.Close()
 
 	// Initialize repository and service
-	userRepo := repository.NewProductRepository(db)
-	userService := service.NewProductService(userRepo)
+	productRepo := repository.NewProductRepository(db)
+	productService := service.NewProductService(productRepo)
+
+	// Domain events are dispatched through the in-process broker: our own
+	// events via the outbox relay, events of other services via Deliver.
+	broker := events.NewBroker()
+	broker.Subscribe("*", events.LogPublisher{}.Publish)
+	consumer := events.NewConsumer(db.DB, repository.EventSource, events.ConsumerConfig{})
+	productService.RegisterEventHandlers(consumer)
+	consumer.Subscribe(broker)
+
+	// Push our events to the services subscribed to them
+	var publisher events.Publisher = broker
+	if len(cfg.EventSubscribers) > 0 {
+		remote, err := events.DialPublisher(cfg.EventSubscribers,
+			creds.DialOption(""), tracing.DialOption())
+		if err != nil {
+			log.Fatalf("failed to connect to event subscribers: %v", err)
+		}
+		defer remote.Close()
+		publisher = events.FanOut{broker, remote}
+	}
+	relay := events.NewRelay(db.DB, publisher, events.RelayConfig{Source: repository.EventSource})
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	go relay.Run(relayCtx)
 
 	// gRPC server
-	grpcServer := grpc.NewServer()
-	userServer := transport.NewProductServer(userService)
-	productpb.RegisterProductServiceServer(grpcServer, userServer)
+	grpcServer := grpc.NewServer(
+		creds.ServerOption(),
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(
+			logging.UnaryServerInterceptor(),
+			creds.UnaryServerInterceptor(),
+			metrics.UnaryServerInterceptor(),
+			apierror.UnaryServerInterceptor("product-service"),
+		),
+		grpc.ChainStreamInterceptor(
+			logging.StreamServerInterceptor(),
+			creds.StreamServerInterceptor(),
+			metrics.StreamServerInterceptor(),
+			apierror.StreamServerInterceptor("product-service"),
+		),
+	)
+	productServer := transport.NewProductServer(productService)
+	productpb.RegisterProductServiceServer(grpcServer, productServer)
+	eventspb.RegisterEventServiceServer(grpcServer, events.NewServer(broker, consumer))
+
+	// grpc.health.v1, reporting database connectivity
+	healthChecker := health.Register(grpcServer, db.DB,
+		productpb.ProductService_ServiceDesc.ServiceName, eventspb.EventService_ServiceDesc.ServiceName)
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	go healthChecker.Run(healthCtx)
 
 	// Start server
-	lis, err := net.Listen("tcp", ":50052")
+	lis, err := net.Listen("tcp", cfg.ListenAddr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
 	go func() {
-		log.Println("Starting user service on :50052")
+		slog.Info("starting product service", "addr", cfg.ListenAddr)
 		if err := grpcServer.Serve(lis); err != nil {
 			log.Fatalf("failed to serve: %v", err)
 		}
@@ -69,12 +181,17 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down user service...")
+	slog.Info("shutting down product service")
+	healthChecker.Shutdown()
 	grpcServer.GracefulStop()
+	stopHealth()
+	stopCreds()
+	stopRelay()
+	metricsServer.Close()
 
 	_, cancel := context.WithTimeout(context.Background(), 5*time.Second) //_ replace with ctx
 	defer cancel()
 	db.DB.Close()
 
-	log.Println("User service stopped")
+	slog.Info("product service stopped")
 }
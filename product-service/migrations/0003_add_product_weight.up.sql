@@ -0,0 +1,4 @@
+-- Weight of a single unit in kilograms. Orders take it from the catalog to
+-- price weight-based shipping; existing products count as weightless until
+-- it is set.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS weight DECIMAL(10,3) NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+-- Stock reserved by orders, written together with the stock change so that
+-- cancelling an order releases exactly what it reserved.
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    order_id VARCHAR(100) NOT NULL,
+    product_id UUID NOT NULL REFERENCES products(id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    reserved_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (order_id, product_id)
+);
@@ -0,0 +1,5 @@
+module github.com/best-microservice/tools/dbsplit
+
+go 1.24.2
+
+require github.com/lib/pq v1.10.9
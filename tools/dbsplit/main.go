@@ -0,0 +1,252 @@
+// Command dbsplit copies the data of a database shared by all services into
+// one database (or schema) per service. It is meant to be run once, while
+// the services are stopped:
+//
+//  1. create the target databases and run "<service> migrate up" against each
+//  2. dbsplit -source DSN -user DSN -product DSN -order DSN
+//  3. point each service at its own database and start it
+//
+// Every service is copied in a single transaction, and row counts are
+// checked against the source before committing. The shared database is left
+// untouched and can be dropped once the services run on their own databases.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lib/pq"
+)
+
+func main() {
+	source := flag.String("source", "", "DSN of the shared database")
+	targets := map[string]*string{
+		"user-service":    flag.String("user", "", "DSN of the user service database"),
+		"product-service": flag.String("product", "", "DSN of the product service database"),
+		"order-service":   flag.String("order", "", "DSN of the order service database"),
+	}
+	force := flag.Bool("force", false, "replace rows already present in the target tables")
+	dryRun := flag.Bool("dry-run", false, "count the rows to copy without writing anything")
+	flag.Parse()
+
+	if *source == "" {
+		log.Fatal("-source is required")
+	}
+	src, err := sql.Open("postgres", *source)
+	if err != nil {
+		log.Fatalf("open source: %v", err)
+	}
+	defer src.Close()
+
+	ctx := context.Background()
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "SERVICE\tTABLE\tROWS")
+
+	for _, o := range owners {
+		dsn := *targets[o.service]
+		if dsn == "" {
+			fmt.Fprintf(out, "%s\t-\tskipped, no target\n", o.service)
+			continue
+		}
+		if err := split(ctx, src, dsn, o, *force, *dryRun, out); err != nil {
+			out.Flush()
+			log.Fatalf("%s: %v", o.service, err)
+		}
+	}
+	out.Flush()
+}
+
+// split copies the tables of one owner in a single target transaction.
+func split(ctx context.Context, src *sql.DB, dsn string, o owner, force, dryRun bool, out *tabwriter.Writer) error {
+	dst, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	tx, err := dst.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Clear the targets in reverse foreign key order before copying
+	for i := len(o.tables) - 1; i >= 0; i-- {
+		t := o.tables[i]
+		n, err := count(ctx, tx, t, "")
+		if err != nil {
+			return fmt.Errorf("%s: %w (run migrate up against the target first)", t.name, err)
+		}
+		if n > 0 && !t.seeded && !force {
+			return fmt.Errorf("%s already holds %d rows; use -force to replace them", t.name, n)
+		}
+		if !dryRun {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+pq.QuoteIdentifier(t.name)+where(t, 1), args(t, o.service)...); err != nil {
+				return fmt.Errorf("%s: %w", t.name, err)
+			}
+		}
+	}
+
+	for _, t := range o.tables {
+		want, err := count(ctx, src, t, o.service)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.name, err)
+		}
+		if dryRun {
+			fmt.Fprintf(out, "%s\t%s\t%d (dry run)\n", o.service, t.name, want)
+			continue
+		}
+
+		copied, err := copyTable(ctx, src, tx, t, o.service)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.name, err)
+		}
+		if copied != want {
+			return fmt.Errorf("%s: copied %d rows, source has %d", t.name, copied, want)
+		}
+		if t.serial != "" {
+			if err := advanceSequence(ctx, tx, t); err != nil {
+				return fmt.Errorf("%s: %w", t.name, err)
+			}
+		}
+		fmt.Fprintf(out, "%s\t%s\t%d\n", o.service, t.name, copied)
+	}
+
+	if dryRun {
+		return nil
+	}
+	return tx.Commit()
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// count returns the rows of t, restricted to service when it is not "".
+func count(ctx context.Context, q queryer, t table, service string) (int64, error) {
+	query := "SELECT COUNT(*) FROM " + pq.QuoteIdentifier(t.name)
+	var params []interface{}
+	if service != "" {
+		query += where(t, 1)
+		params = args(t, service)
+	}
+	var n int64
+	err := q.QueryRowContext(ctx, query, params...).Scan(&n)
+	return n, err
+}
+
+// copyTable streams the rows of t into the target with COPY. Values travel
+// as text, so every column type round-trips through its text representation.
+func copyTable(ctx context.Context, src *sql.DB, tx *sql.Tx, t table, service string) (int64, error) {
+	cols, err := columns(ctx, tx, t.name)
+	if err != nil {
+		return 0, err
+	}
+
+	selects := make([]string, len(cols))
+	for i, c := range cols {
+		selects[i] = pq.QuoteIdentifier(c) + "::text"
+	}
+	rows, err := src.QueryContext(ctx,
+		"SELECT "+strings.Join(selects, ", ")+" FROM "+pq.QuoteIdentifier(t.name)+where(t, 1),
+		args(t, service)...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(t.name, cols...))
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	values := make([]sql.NullString, len(cols))
+	dest := make([]interface{}, len(cols))
+	row := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			stmt.Close()
+			return n, err
+		}
+		for i, v := range values {
+			row[i] = nil
+			if v.Valid {
+				row[i] = v.String
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			stmt.Close()
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		stmt.Close()
+		return n, err
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return n, err
+	}
+	return n, stmt.Close()
+}
+
+// columns lists the columns of name in the target, which is authoritative
+// since it was created by the owner's migrations.
+func columns(ctx context.Context, q queryer, name string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil, err
+		}
+		cols = append(cols, c)
+	}
+	if len(cols) == 0 {
+		return nil, errors.New("table not found in target")
+	}
+	return cols, rows.Err()
+}
+
+func advanceSequence(ctx context.Context, tx *sql.Tx, t table) error {
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(
+		`SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX(%s), 0) + 1, false) FROM %s`,
+		t.name, t.serial, pq.QuoteIdentifier(t.serial), pq.QuoteIdentifier(t.name)))
+	return err
+}
+
+// where returns the filter clause of t using placeholder $n, or "".
+func where(t table, n int) string {
+	if t.filter == "" {
+		return ""
+	}
+	return fmt.Sprintf(" WHERE %s = $%d", pq.QuoteIdentifier(t.filter), n)
+}
+
+func args(t table, service string) []interface{} {
+	if t.filter == "" {
+		return nil
+	}
+	return []interface{}{service}
+}
@@ -0,0 +1,67 @@
+package main
+
+// table is copied from the shared database to the database of its owner.
+type table struct {
+	name string
+	// filter restricts the copied rows of tables shared by all services; it
+	// is a column holding the service name.
+	filter string
+	// serial names a BIGSERIAL column whose sequence must be advanced past
+	// the copied rows.
+	serial string
+	// seeded tables are filled by the owner's migrations; their rows are
+	// replaced by those of the shared database.
+	seeded bool
+}
+
+// owner lists the tables of a service in foreign key order.
+type owner struct {
+	service string
+	tables  []table
+}
+
+// eventTables are shared by all services and split by source and consumer,
+// which hold the service name.
+func eventTables() []table {
+	return []table{
+		{name: "outbox", filter: "source"},
+		{name: "processed_events", filter: "consumer"},
+		{name: "dead_letters", filter: "consumer", serial: "id"},
+	}
+}
+
+var owners = []owner{
+	{
+		service: "user-service",
+		tables: append([]table{
+			{name: "users"},
+			{name: "addresses"},
+			{name: "email_verification_tokens"},
+			{name: "sessions"},
+			{name: "password_reset_tokens"},
+			{name: "totp_credentials"},
+			{name: "recovery_codes"},
+			{name: "mfa_challenges"},
+		}, eventTables()...),
+	},
+	{
+		service: "product-service",
+		tables: append([]table{
+			{name: "products"},
+			{name: "stock_reservations"},
+		}, eventTables()...),
+	},
+	{
+		service: "order-service",
+		tables: append([]table{
+			{name: "shipping_methods", seeded: true},
+			{name: "orders"},
+			{name: "order_items"},
+			{name: "order_status_events", serial: "id"},
+			{name: "shipments"},
+			{name: "shipment_events", serial: "id"},
+			{name: "invoice_sequences"},
+			{name: "invoices"},
+		}, eventTables()...),
+	},
+}
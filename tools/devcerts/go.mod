@@ -0,0 +1,3 @@
+module github.com/best-microservice/tools/devcerts
+
+go 1.24.2
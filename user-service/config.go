@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/best-microservice/common/config"
+	"github.com/best-microservice/common/mtls"
+	"github.com/best-microservice/common/tracing"
+	"github.com/best-microservice/user-service/internal/mail"
+)
+
+// Config is the user service configuration. See the config package for the
+// sources and their precedence.
+type Config struct {
+	ListenAddr        string             `yaml:"listen_addr" env:"USER_LISTEN_ADDR" flag:"listen-addr" usage:"gRPC listen address"`
+	MetricsAddr       string             `yaml:"metrics_addr" env:"USER_METRICS_ADDR" flag:"metrics-addr" usage:"Prometheus /metrics listen address"`
+	EventSubscribers  []string           `yaml:"event_subscribers" env:"USER_EVENT_SUBSCRIBERS" flag:"event-subscribers" usage:"comma separated gRPC addresses events are pushed to"`
+	MigrateOnStart    bool               `yaml:"migrate_on_start" env:"USER_MIGRATE_ON_START" flag:"migrate-on-start" usage:"apply pending migrations before serving"`
+	SessionTTL        time.Duration      `yaml:"session_ttl" env:"USER_SESSION_TTL" flag:"session-ttl" usage:"how long a login stays valid; changing the password ends it sooner"`
+	Database          config.Database    `yaml:"database" env:"USER_DB_" flag:"db-"`
+	Mail              mail.Config        `yaml:"mail" env:"MAIL_" flag:"mail-"`
+	EmailVerification VerificationConfig `yaml:"email_verification" env:"EMAIL_VERIFICATION_" flag:"email-verification-"`
+	PasswordReset     ResetConfig        `yaml:"password_reset" env:"PASSWORD_RESET_" flag:"password-reset-"`
+	PasswordPolicy    PasswordConfig     `yaml:"password_policy" env:"PASSWORD_POLICY_" flag:"password-policy-"`
+	MFA               MFAConfig          `yaml:"mfa" env:"MFA_" flag:"mfa-"`
+	Log               config.Logging     `yaml:"log"`
+	TLS               mtls.Config        `yaml:"tls" env:"USER_TLS_" flag:"tls-"`
+	Tracing           tracing.Config     `yaml:"tracing"`
+}
+
+// VerificationConfig sets up the verification of new users' email
+// addresses. Ordering before verification is blocked by the order service.
+type VerificationConfig struct {
+	TokenTTL         time.Duration `yaml:"token_ttl" env:"TOKEN_TTL" flag:"token-ttl" usage:"how long verification links stay valid"`
+	LinkURL          string        `yaml:"link_url" env:"LINK_URL" flag:"link-url" usage:"page verification links open; the token is added as ?token="`
+	RequiredForLogin bool          `yaml:"required_for_login" env:"REQUIRED_FOR_LOGIN" flag:"required-for-login" usage:"reject logins until the email address is verified"`
+}
+
+func (c VerificationConfig) Validate() error {
+	return validateLink("email_verification", c.TokenTTL, c.LinkURL)
+}
+
+// ResetConfig sets up the links sent to users who forgot their password.
+type ResetConfig struct {
+	TokenTTL time.Duration `yaml:"token_ttl" env:"TOKEN_TTL" flag:"token-ttl" usage:"how long password reset links stay valid"`
+	LinkURL  string        `yaml:"link_url" env:"LINK_URL" flag:"link-url" usage:"page password reset links open; the token is added as ?token="`
+}
+
+func (c ResetConfig) Validate() error {
+	return validateLink("password_reset", c.TokenTTL, c.LinkURL)
+}
+
+// PasswordConfig is the strength required of new passwords. Common
+// passwords and ones containing the user's name or email are always
+// rejected.
+type PasswordConfig struct {
+	MinLength  int `yaml:"min_length" env:"MIN_LENGTH" flag:"min-length" usage:"minimum number of characters"`
+	MinClasses int `yaml:"min_classes" env:"MIN_CLASSES" flag:"min-classes" usage:"how many of lowercase, uppercase, digits and symbols must be used"`
+}
+
+func (c PasswordConfig) Validate() error {
+	var errs []error
+	if c.MinLength < 8 {
+		errs = append(errs, fmt.Errorf("password_policy.min_length %d must be at least 8", c.MinLength))
+	}
+	if c.MinClasses < 1 || c.MinClasses > 4 {
+		errs = append(errs, fmt.Errorf("password_policy.min_classes %d must be between 1 and 4", c.MinClasses))
+	}
+	return errors.Join(errs...)
+}
+
+// MFAConfig sets up two-factor authentication with authenticator apps.
+type MFAConfig struct {
+	Issuer        string        `yaml:"issuer" env:"ISSUER" flag:"issuer" usage:"service name shown in authenticator apps"`
+	EncryptionKey string        `yaml:"encryption_key" env:"ENCRYPTION_KEY" flag:"encryption-key" usage:"base64 AES-256 key TOTP secrets are encrypted with" secret:"true"`
+	ChallengeTTL  time.Duration `yaml:"challenge_ttl" env:"CHALLENGE_TTL" flag:"challenge-ttl" usage:"how long a login waits for the second factor"`
+	MaxAttempts   int           `yaml:"max_attempts" env:"MAX_ATTEMPTS" flag:"max-attempts" usage:"wrong codes after which a login has to start over"`
+}
+
+// Key returns the decoded encryption key, which serving requires. There is
+// no default: a key shared between deployments would not protect the
+// secrets.
+func (c MFAConfig) Key() ([]byte, error) {
+	if c.EncryptionKey == "" {
+		return nil, errors.New("mfa.encryption_key is required; generate one with: head -c32 /dev/urandom | base64")
+	}
+	key, err := base64.StdEncoding.DecodeString(c.EncryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("mfa.encryption_key must be 32 bytes encoded as base64")
+	}
+	return key, nil
+}
+
+// Validate checks the key only when one is set, so that migrations run
+// without it; serving fails on a missing key through Key.
+func (c MFAConfig) Validate() error {
+	var errs []error
+	if c.EncryptionKey != "" {
+		if _, err := c.Key(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.Issuer == "" {
+		errs = append(errs, errors.New("mfa.issuer is required"))
+	}
+	if c.ChallengeTTL <= 0 {
+		errs = append(errs, errors.New("mfa.challenge_ttl must be positive"))
+	}
+	if c.MaxAttempts <= 0 {
+		errs = append(errs, errors.New("mfa.max_attempts must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// validateLink checks the settings of links emailed to users.
+func validateLink(prefix string, ttl time.Duration, link string) error {
+	var errs []error
+	if ttl <= 0 {
+		errs = append(errs, fmt.Errorf("%s.token_ttl must be positive", prefix))
+	}
+	if u, err := url.Parse(link); err != nil || !u.IsAbs() {
+		errs = append(errs, fmt.Errorf("%s.link_url %q must be an absolute URL", prefix, link))
+	}
+	return errors.Join(errs...)
+}
+
+// loadConfig returns the configuration and the positional arguments, such as
+// the migrate subcommand.
+func loadConfig(args []string) (Config, []string, error) {
+	cfg := Config{
+		ListenAddr:     ":50051",
+		MetricsAddr:    ":9101",
+		MigrateOnStart: true,
+		SessionTTL:     30 * 24 * time.Hour,
+		Database:       config.DefaultDatabase(),
+		Mail:           mail.DefaultConfig(),
+		EmailVerification: VerificationConfig{
+			TokenTTL: 24 * time.Hour,
+			LinkURL:  "http://localhost:8080/verify-email",
+		},
+		PasswordReset: ResetConfig{
+			TokenTTL: time.Hour,
+			LinkURL:  "http://localhost:8080/reset-password",
+		},
+		PasswordPolicy: PasswordConfig{MinLength: 10, MinClasses: 2},
+		MFA: MFAConfig{
+			Issuer:       "Best Microservice",
+			ChallengeTTL: 5 * time.Minute,
+			MaxAttempts:  5,
+		},
+		Log:     config.DefaultLogging(),
+		Tracing: tracing.DefaultConfig("user-service"),
+	}
+	rest, err := config.Load("user-service", &cfg, args)
+	return cfg, rest, err
+}
+
+func (c Config) Validate() error {
+	return errors.Join(
+		config.ValidateAddr("listen_addr", c.ListenAddr),
+		config.ValidateAddr("metrics_addr", c.MetricsAddr),
+		validateSessionTTL(c.SessionTTL),
+	)
+}
+
+func validateSessionTTL(ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("session_ttl %v must be positive", ttl)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMFAConfigValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		wantErr    string
+		wantKeyErr string
+	}{
+		{"32 byte key", "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=", "", ""},
+		// migrations run without a key, serving does not
+		{"missing key", "", "", "mfa.encryption_key is required"},
+		{"16 byte key", "AAAAAAAAAAAAAAAAAAAAAA==", "mfa.encryption_key must be 32 bytes", ""},
+		{"not base64", "not a key", "mfa.encryption_key must be 32 bytes", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := MFAConfig{Issuer: "Test", EncryptionKey: tt.key, ChallengeTTL: 1, MaxAttempts: 1}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v", err)
+				}
+				key, err := cfg.Key()
+				if tt.wantKeyErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantKeyErr) {
+						t.Fatalf("Key() = %v, want it to contain %q", err, tt.wantKeyErr)
+					}
+					return
+				}
+				if len(key) != 32 {
+					t.Fatalf("Key() has %d bytes, want 32", len(key))
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
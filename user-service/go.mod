@@ -3,23 +3,79 @@ module github.com/best-microservice/user-service
 go 1.24.2
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/best-microservice/common/apierror v0.0.0
+	github.com/best-microservice/common/config v0.0.0
+	github.com/best-microservice/common/events v0.0.0
+	github.com/best-microservice/common/health v0.0.0
+	github.com/best-microservice/common/logging v0.0.0
+	github.com/best-microservice/common/metrics v0.0.0
+	github.com/best-microservice/common/migrate v0.0.0
+	github.com/best-microservice/common/mtls v0.0.0
+	github.com/best-microservice/common/protos/events v0.0.0
 	github.com/best-microservice/common/protos/user v0.0.0-20241002120000-abcdef123456
+	github.com/best-microservice/common/tracing v0.0.0
 	github.com/google/uuid v1.6.0
 	github.com/jmoiron/sqlx v1.4.0
-	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
+	github.com/prometheus/client_golang v1.22.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	golang.org/x/crypto v0.40.0
 	google.golang.org/grpc v1.74.2
 )
 
 require (
+	github.com/XSAM/otelsql v0.39.0 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cenkalti/backoff/v5 v5.0.2 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/go-logr/logr v1.4.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.27.1 // indirect
+	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.62.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc v0.62.0 // indirect
+	go.opentelemetry.io/otel v1.37.0 // indirect
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace v1.37.0 // indirect
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc v1.37.0 // indirect
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.37.0 // indirect
+	go.opentelemetry.io/otel/metric v1.37.0 // indirect
+	go.opentelemetry.io/otel/sdk v1.37.0 // indirect
+	go.opentelemetry.io/otel/trace v1.37.0 // indirect
+	go.opentelemetry.io/proto/otlp v1.7.0 // indirect
 	golang.org/x/net v0.41.0 // indirect
 	golang.org/x/sys v0.34.0 // indirect
 	golang.org/x/text v0.27.0 // indirect
-	google.golang.org/genproto/googleapis/rpc v0.0.0-20250528174236-200df99c418a // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20250603155806-513f23925822 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250603155806-513f23925822 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 replace github.com/best-microservice/common/protos => ../common/protos
 
 replace github.com/best-microservice/common/protos/user => ../common/protos/user
+
+replace github.com/best-microservice/common/events => ../common/events
+
+replace github.com/best-microservice/common/protos/events => ../common/protos/events
+
+replace github.com/best-microservice/common/tracing => ../common/tracing
+
+replace github.com/best-microservice/common/metrics => ../common/metrics
+
+replace github.com/best-microservice/common/logging => ../common/logging
+
+replace github.com/best-microservice/common/health => ../common/health
+
+replace github.com/best-microservice/common/config => ../common/config
+
+replace github.com/best-microservice/common/migrate => ../common/migrate
+
+replace github.com/best-microservice/common/mtls => ../common/mtls
+
+replace github.com/best-microservice/common/apierror => ../common/apierror
@@ -0,0 +1,121 @@
+// Package mail sends the emails of the user service, such as verification
+// links. Messages are rendered from the embedded templates and sent through
+// a Mailer: SMTP in production, or a sink keeping them on disk or in memory
+// for local use.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"time"
+)
+
+// Message is an email with a plain text and an HTML body.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config selects and configures the Mailer.
+type Config struct {
+	Transport string     `yaml:"transport" env:"TRANSPORT" flag:"transport" usage:"how emails are sent: smtp, file or memory"`
+	From      string     `yaml:"from" env:"FROM" flag:"from" usage:"sender address, e.g. Shop <no-reply@example.com>"`
+	Dir       string     `yaml:"dir" env:"DIR" flag:"dir" usage:"directory the file transport writes .eml files to"`
+	SMTP      SMTPConfig `yaml:"smtp" env:"SMTP_" flag:"smtp-"`
+}
+
+// SMTPConfig is the relay of the smtp transport. Credentials are optional;
+// they are only sent over TLS.
+type SMTPConfig struct {
+	Addr     string `yaml:"addr" env:"ADDR" flag:"addr" usage:"SMTP relay host:port"`
+	Username string `yaml:"username" env:"USERNAME" flag:"username" usage:"SMTP user"`
+	Password string `yaml:"password" env:"PASSWORD" flag:"password" usage:"SMTP password" secret:"true"`
+}
+
+// DefaultConfig writes emails to ./mail, so registering locally needs no
+// mail server.
+func DefaultConfig() Config {
+	return Config{Transport: "file", From: "Best Microservice <no-reply@localhost>", Dir: "mail"}
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		errs = append(errs, fmt.Errorf("mail.from %q is not an email address", c.From))
+	}
+	switch c.Transport {
+	case "smtp":
+		if c.SMTP.Addr == "" {
+			errs = append(errs, fmt.Errorf("mail.smtp.addr is required for the smtp transport"))
+		}
+	case "file":
+		if c.Dir == "" {
+			errs = append(errs, fmt.Errorf("mail.dir is required for the file transport"))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("mail.transport %q must be smtp, file or memory", c.Transport))
+	}
+	return errors.Join(errs...)
+}
+
+// New returns the Mailer of cfg.
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Transport {
+	case "smtp":
+		return NewSMTP(cfg.SMTP, cfg.From), nil
+	case "file":
+		return NewFileSink(cfg.Dir, cfg.From)
+	case "memory":
+		return NewMemorySink(), nil
+	}
+	return nil, fmt.Errorf("unknown mail transport %q", cfg.Transport)
+}
+
+// encode formats msg as a MIME message with both bodies as alternatives.
+func encode(from string, msg Message, date time.Time) ([]byte, error) {
+	var boundary [12]byte
+	if _, err := rand.Read(boundary[:]); err != nil {
+		return nil, err
+	}
+	b := hex.EncodeToString(boundary[:])
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n", b)
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		fmt.Fprintf(&buf, "\r\n--%s\r\n", b)
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=utf-8\r\n", part.contentType)
+		fmt.Fprintf(&buf, "Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		w := quotedprintable.NewWriter(&buf)
+		if _, err := w.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Fprintf(&buf, "\r\n--%s--\r\n", b)
+	return buf.Bytes(), nil
+}
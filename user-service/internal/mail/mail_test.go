@@ -0,0 +1,162 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	expires := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	link := struct {
+		Name      string
+		Link      string
+		ExpiresAt time.Time
+	}{"Ada <Admin>", "https://shop.test/verify?token=abc&lang=en", expires}
+	tests := []struct {
+		name        string
+		data        any
+		wantSubject string
+		wantText    []string
+		wantHTML    []string
+	}{
+		{
+			name:        VerifyEmail,
+			data:        link,
+			wantSubject: "Confirm your email address",
+			wantText:    []string{"Hello Ada <Admin>,", link.Link, "1 March 2026 12:30 UTC"},
+			wantHTML:    []string{"Hello Ada &lt;Admin&gt;,", `href="https://shop.test/verify?token=abc&amp;lang=en"`},
+		},
+		{
+			name:     ResetPassword,
+			data:     link,
+			wantText: []string{link.Link, "1 March 2026 12:30 UTC"},
+			wantHTML: []string{"Ada &lt;Admin&gt;"},
+		},
+		{
+			name: PasswordChanged,
+			data: struct {
+				Name      string
+				ChangedAt time.Time
+			}{"Ada", expires},
+			wantText: []string{"Hello Ada,"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Render(tt.name, "ada@example.com", tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.To != "ada@example.com" || msg.Subject == "" || strings.Contains(msg.Subject, "\n") {
+				t.Errorf("To %q, Subject %q", msg.To, msg.Subject)
+			}
+			if tt.wantSubject != "" && msg.Subject != tt.wantSubject {
+				t.Errorf("Subject %q, want %q", msg.Subject, tt.wantSubject)
+			}
+			for _, want := range tt.wantText {
+				if !strings.Contains(msg.Text, want) {
+					t.Errorf("text does not contain %q:\n%s", want, msg.Text)
+				}
+			}
+			for _, want := range tt.wantHTML {
+				if !strings.Contains(msg.HTML, want) {
+					t.Errorf("HTML does not contain %q:\n%s", want, msg.HTML)
+				}
+			}
+		})
+	}
+
+	if _, err := Render("welcome", "ada@example.com", nil); err == nil {
+		t.Error("Render of an unknown email succeeded")
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewFileSink(filepath.Join(dir, "mail"), "Shop <no-reply@shop.test>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := Message{To: "ada@example.com", Subject: "Grüße", Text: "Hello Ada\n", HTML: "<p>Hello Ada</p>"}
+	if err := sink.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "mail", "*-ada_at_example.com.eml"))
+	if len(files) != 1 {
+		t.Fatalf("files %v, want one .eml for the recipient", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Header.Get("From"); got != "Shop <no-reply@shop.test>" {
+		t.Errorf("From %q", got)
+	}
+	if got, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject")); got != msg.Subject {
+		t.Errorf("Subject %q, want %q", got, msg.Subject)
+	}
+
+	_, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(part)
+		// Lines end in CRLF on the wire
+		got := strings.ReplaceAll(string(body), "\r\n", "\n")
+		if part.Header.Get("Content-Type") != want.contentType || got != want.body {
+			t.Errorf("part %s: %q, want %s: %q", part.Header.Get("Content-Type"), got, want.contentType, want.body)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"default", DefaultConfig(), ""},
+		{"memory", Config{Transport: "memory", From: "no-reply@shop.test"}, ""},
+		{"smtp without relay", Config{Transport: "smtp", From: "no-reply@shop.test"}, "mail.smtp.addr is required"},
+		{"file without dir", Config{Transport: "file", From: "no-reply@shop.test"}, "mail.dir is required"},
+		{"unknown transport", Config{Transport: "pigeon", From: "no-reply@shop.test"}, `mail.transport "pigeon"`},
+		{"invalid sender", Config{Transport: "memory", From: "nobody"}, `mail.from "nobody"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileSink writes each message to a .eml file in a directory instead of
+// sending it; mail clients open them as they would have been received.
+type FileSink struct {
+	dir  string
+	from string
+}
+
+// NewFileSink creates dir if needed.
+func NewFileSink(dir, from string) (*FileSink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	return &FileSink{dir: dir, from: from}, nil
+}
+
+func (s *FileSink) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := encode(s.from, msg, now)
+	if err != nil {
+		return err
+	}
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := filepath.Join(s.dir, fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), recipient))
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	slog.InfoContext(ctx, "email written", "to", msg.To, "subject", msg.Subject, "file", name)
+	return nil
+}
+
+// MemorySink keeps sent messages in memory, where they can be read back
+// with Messages.
+type MemorySink struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Send(ctx context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg)
+	slog.InfoContext(ctx, "email kept in memory", "to", msg.To, "subject", msg.Subject)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (s *MemorySink) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
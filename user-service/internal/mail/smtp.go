@@ -0,0 +1,55 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTP sends messages through a relay, upgrading to TLS when the relay
+// supports STARTTLS.
+type SMTP struct {
+	cfg  SMTPConfig
+	from string
+}
+
+func NewSMTP(cfg SMTPConfig, from string) *SMTP {
+	return &SMTP{cfg: cfg, from: from}
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	data, err := encode(s.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	sender, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender: %w", err)
+	}
+
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		host, _, _ := net.SplitHostPort(s.cfg.Addr)
+		// PlainAuth refuses to send credentials without TLS
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, host)
+	}
+
+	// smtp.SendMail does not take a context; the call runs until the relay
+	// answers, and ctx only bounds how long the caller waits for it
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.cfg.Addr, auth, sender.Address, []string{msg.To}, data)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type Address struct {
+	ID         string    `json:"id" db:"id"`
+	UserID     string    `json:"user_id" db:"user_id"`
+	Label      string    `json:"label" db:"label"`
+	Recipient  string    `json:"recipient" db:"recipient"`
+	Line1      string    `json:"line1" db:"line1"`
+	Line2      string    `json:"line2" db:"line2"`
+	City       string    `json:"city" db:"city"`
+	State      string    `json:"state" db:"state"`
+	PostalCode string    `json:"postal_code" db:"postal_code"`
+	Country    string    `json:"country" db:"country"`
+	Phone      string    `json:"phone" db:"phone"`
+	IsDefault  bool      `json:"is_default" db:"is_default"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
@@ -7,5 +7,51 @@ type User struct {
 	Name      string    `json:"name"`
 	Email     string    `json:"email"`
 	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	// EmailVerifiedAt is nil until the user opened their verification link.
+	EmailVerifiedAt *time.Time `json:"email_verified_at" db:"email_verified_at"`
+	// MFAEnabled is set once the user confirmed a TOTP enrollment.
+	MFAEnabled bool `json:"mfa_enabled" db:"mfa_enabled"`
+}
+
+// VerificationToken is a pending email verification. Only the hash of the
+// token is stored.
+type VerificationToken struct {
+	Hash      string    `db:"token_hash"`
+	UserID    string    `db:"user_id"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// PasswordResetToken is a pending password reset. Only the hash of the
+// token is stored.
+type PasswordResetToken struct {
+	Hash      string    `db:"token_hash"`
+	UserID    string    `db:"user_id"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// Session is a signed in client. Only the hash of its token is stored.
+type Session struct {
+	Hash      string    `db:"token_hash"`
+	UserID    string    `db:"user_id"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// TOTPCredential is the TOTP secret of a user, encrypted. It is pending
+// until ConfirmedAt is set.
+type TOTPCredential struct {
+	UserID      string     `db:"user_id"`
+	Secret      []byte     `db:"secret"`
+	ConfirmedAt *time.Time `db:"confirmed_at"`
+	// LastStep is the time step of the last code accepted.
+	LastStep int64 `db:"last_step"`
+}
+
+// MFAChallenge is a login waiting for a second factor. Only the hash of its
+// token is stored.
+type MFAChallenge struct {
+	Hash      string    `db:"token_hash"`
+	UserID    string    `db:"user_id"`
+	Attempts  int       `db:"attempts"`
+	ExpiresAt time.Time `db:"expires_at"`
 }
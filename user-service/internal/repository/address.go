@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/best-microservice/user-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type AddressRepository struct {
+	db *sqlx.DB
+}
+
+func NewAddressRepository(db *sqlx.DB) *AddressRepository {
+	return &AddressRepository{db: db}
+}
+
+// CreateAddress stores a new address. When the address is marked as default,
+// any previous default of the same user is cleared in the same transaction.
+func (r *AddressRepository) CreateAddress(ctx context.Context, address *models.Address) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if address.IsDefault {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE addresses SET is_default = FALSE WHERE user_id = $1`, address.UserID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO addresses (id, user_id, label, recipient, line1, line2, city, state,
+			postal_code, country, phone, is_default, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+
+	address.ID = uuid.New().String()
+	address.CreatedAt = time.Now()
+
+	_, err = tx.ExecContext(ctx, query,
+		address.ID, address.UserID, address.Label, address.Recipient, address.Line1, address.Line2,
+		address.City, address.State, address.PostalCode, address.Country, address.Phone,
+		address.IsDefault, address.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *AddressRepository) GetAddress(ctx context.Context, userID, id string) (*models.Address, error) {
+	query := `
+		SELECT id, user_id, label, recipient, line1, line2, city, state,
+			postal_code, country, phone, is_default, created_at
+		FROM addresses
+		WHERE id = $1 AND user_id = $2
+	`
+
+	var address models.Address
+	err := r.db.GetContext(ctx, &address, query, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &address, nil
+}
+
+func (r *AddressRepository) ListAddresses(ctx context.Context, userID string) ([]*models.Address, error) {
+	query := `
+		SELECT id, user_id, label, recipient, line1, line2, city, state,
+			postal_code, country, phone, is_default, created_at
+		FROM addresses
+		WHERE user_id = $1
+		ORDER BY is_default DESC, created_at ASC
+	`
+
+	var addresses []*models.Address
+	err := r.db.SelectContext(ctx, &addresses, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+func (r *AddressRepository) DeleteAddress(ctx context.Context, userID, id string) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM addresses WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
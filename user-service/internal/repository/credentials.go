@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/best-microservice/user-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CreateSession stores a new session of the user and drops their expired
+// ones.
+func (r *UserRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1 AND expires_at < $2`,
+		session.UserID, time.Now()); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO sessions (token_hash, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, session.Hash, session.UserID, session.ExpiresAt, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetSession returns the session with the given token hash, or
+// sql.ErrNoRows.
+func (r *UserRepository) GetSession(ctx context.Context, hash string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.GetContext(ctx, &session, `
+		SELECT token_hash, user_id, expires_at
+		FROM sessions
+		WHERE token_hash = $1
+	`, hash)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetPasswordHash returns the password hash of the user, or sql.ErrNoRows.
+func (r *UserRepository) GetPasswordHash(ctx context.Context, userID string) (string, error) {
+	var hash string
+	err := r.db.GetContext(ctx, &hash, `SELECT password FROM users WHERE id = $1`, userID)
+	return hash, err
+}
+
+// ReplacePasswordResetToken stores the token of a new reset link for the
+// user, invalidating the links sent before.
+func (r *UserRepository) ReplacePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1`, token.UserID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO password_reset_tokens (token_hash, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, token.Hash, token.UserID, token.ExpiresAt, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPasswordResetToken returns the reset token with the given hash, or
+// sql.ErrNoRows.
+func (r *UserRepository) GetPasswordResetToken(ctx context.Context, hash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.db.GetContext(ctx, &token, `
+		SELECT token_hash, user_id, expires_at
+		FROM password_reset_tokens
+		WHERE token_hash = $1
+	`, hash)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ResetPassword redeems the reset token and sets the user's password hash,
+// see UpdatePassword. It returns sql.ErrNoRows if the token was redeemed
+// concurrently. Since the link was emailed to the user, their address
+// counts as verified afterwards.
+func (r *UserRepository) ResetPassword(ctx context.Context, token *models.PasswordResetToken, passwordHash string, at time.Time) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1 AND token_hash = $2`,
+		token.UserID, token.Hash)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := setPassword(ctx, tx, token.UserID, passwordHash, at); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET email_verified_at = COALESCE(email_verified_at, $2) WHERE id = $1`,
+		token.UserID, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdatePassword sets the user's password hash. Their sessions, pending
+// reset links and logins waiting for a second factor are revoked along
+// with the old password.
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID, passwordHash string, at time.Time) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setPassword(ctx, tx, userID, passwordHash, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setPassword(ctx context.Context, tx *sqlx.Tx, userID, passwordHash string, at time.Time) error {
+	res, err := tx.ExecContext(ctx, `UPDATE users SET password = $2, updated_at = $3 WHERE id = $1`,
+		userID, passwordHash, at)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	for _, query := range []string{
+		`DELETE FROM sessions WHERE user_id = $1`,
+		`DELETE FROM password_reset_tokens WHERE user_id = $1`,
+		`DELETE FROM mfa_challenges WHERE user_id = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/best-microservice/user-service/internal/models"
+)
+
+// GetTOTPCredential returns the TOTP credential of the user, pending or
+// confirmed, or sql.ErrNoRows.
+func (r *UserRepository) GetTOTPCredential(ctx context.Context, userID string) (*models.TOTPCredential, error) {
+	var cred models.TOTPCredential
+	err := r.db.GetContext(ctx, &cred, `
+		SELECT user_id, secret, confirmed_at, last_step
+		FROM totp_credentials
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// SavePendingTOTP stores a new pending TOTP secret for the user, replacing a
+// pending one. It returns sql.ErrNoRows if the user has a confirmed one.
+func (r *UserRepository) SavePendingTOTP(ctx context.Context, userID string, secret []byte) error {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO totp_credentials (user_id, secret, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_step = 0, created_at = EXCLUDED.created_at
+		WHERE totp_credentials.confirmed_at IS NULL
+	`, userID, secret, time.Now())
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+// ConfirmTOTP enables the pending TOTP credential of the user, recording
+// the step of the code that confirmed it, and replaces their recovery
+// codes. It returns sql.ErrNoRows if there is no pending credential.
+func (r *UserRepository) ConfirmTOTP(ctx context.Context, userID string, step int64, codeHashes []string, at time.Time) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE totp_credentials SET confirmed_at = $2, last_step = $3
+		WHERE user_id = $1 AND confirmed_at IS NULL
+	`, userID, at, step)
+	if err != nil {
+		return err
+	}
+	if err := expectRow(res); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`,
+			userID, hash, at); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records that the code of a time step was accepted. It returns
+// sql.ErrNoRows if a code of that or a later step was accepted already.
+func (r *UserRepository) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE totp_credentials SET last_step = $2 WHERE user_id = $1 AND last_step < $2`, userID, step)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+// UseRecoveryCode deletes the recovery code of the user with the given
+// hash. It returns sql.ErrNoRows if there is none.
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM recovery_codes WHERE user_id = $1 AND code_hash = $2`, userID, codeHash)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+// DeleteTOTP turns off two-factor authentication for the user, deleting
+// their credential, recovery codes and open challenges.
+func (r *UserRepository) DeleteTOTP(ctx context.Context, userID string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM totp_credentials WHERE user_id = $1`,
+		`DELETE FROM recovery_codes WHERE user_id = $1`,
+		`DELETE FROM mfa_challenges WHERE user_id = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CreateMFAChallenge stores a new challenge and drops the user's expired
+// ones.
+func (r *UserRepository) CreateMFAChallenge(ctx context.Context, challenge *models.MFAChallenge) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE user_id = $1 AND expires_at < $2`,
+		challenge.UserID, time.Now()); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO mfa_challenges (token_hash, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, challenge.Hash, challenge.UserID, challenge.ExpiresAt, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetMFAChallenge returns the challenge with the given hash, or
+// sql.ErrNoRows.
+func (r *UserRepository) GetMFAChallenge(ctx context.Context, hash string) (*models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+	err := r.db.GetContext(ctx, &challenge, `
+		SELECT token_hash, user_id, attempts, expires_at
+		FROM mfa_challenges
+		WHERE token_hash = $1
+	`, hash)
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// CountMFAFailure records a wrong code for the challenge and returns how
+// many there were.
+func (r *UserRepository) CountMFAFailure(ctx context.Context, hash string) (int, error) {
+	var attempts int
+	err := r.db.GetContext(ctx, &attempts,
+		`UPDATE mfa_challenges SET attempts = attempts + 1 WHERE token_hash = $1 RETURNING attempts`, hash)
+	return attempts, err
+}
+
+// DeleteMFAChallenge deletes the challenge with the given hash. It returns
+// sql.ErrNoRows if it was deleted already, e.g. by a concurrent login.
+func (r *UserRepository) DeleteMFAChallenge(ctx context.Context, hash string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE token_hash = $1`, hash)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+// expectRow returns sql.ErrNoRows if res affected no rows.
+func expectRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -2,14 +2,19 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
+	"github.com/best-microservice/common/events"
 	"github.com/best-microservice/user-service/internal/models"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
+// EventSource identifies this service in the events it writes to the outbox.
+const EventSource = "user-service"
+
 type UserRepository struct {
 	db *sqlx.DB
 }
@@ -28,18 +33,38 @@ func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) erro
 	user.ID = uuid.New().String()
 	now := time.Now()
 
-	err := r.db.QueryRowContext(ctx, query,
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query,
 		user.ID, user.Name, user.Email, user.Password, now, now).Scan(&user.ID)
 	if err != nil {
 		return err
 	}
+	user.CreatedAt = now
+
+	evt, err := events.New(events.UserRegistered, EventSource, "user", user.ID, events.UserRegisteredPayload{
+		UserID: user.ID,
+		Name:   user.Name,
+		Email:  user.Email,
+	})
+	if err != nil {
+		return err
+	}
+	if err := events.Write(ctx, tx, evt); err != nil {
+		return err
+	}
 
-	return nil
+	return tx.Commit()
 }
 
 func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.User, error) {
 	query := `
-		SELECT id, name, email, created_at
+		SELECT id, name, email, created_at, email_verified_at,
+			EXISTS (SELECT 1 FROM totp_credentials t WHERE t.user_id = users.id AND t.confirmed_at IS NOT NULL) AS mfa_enabled
 		FROM users
 		WHERE id = $1
 	`
@@ -55,7 +80,8 @@ func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.Us
 
 func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
-		SELECT id, name, email, password, created_at
+		SELECT id, name, email, password, created_at, email_verified_at,
+			EXISTS (SELECT 1 FROM totp_credentials t WHERE t.user_id = users.id AND t.confirmed_at IS NOT NULL) AS mfa_enabled
 		FROM users
 		WHERE email = $1
 	`
@@ -68,3 +94,70 @@ func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*mod
 
 	return &user, nil
 }
+
+// ReplaceVerificationToken stores the token of a new verification link for
+// the user, invalidating the links sent before.
+func (r *UserRepository) ReplaceVerificationToken(ctx context.Context, token *models.VerificationToken) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM email_verification_tokens WHERE user_id = $1`, token.UserID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO email_verification_tokens (token_hash, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, token.Hash, token.UserID, token.ExpiresAt, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetVerificationToken returns the verification token with the given hash,
+// or sql.ErrNoRows.
+func (r *UserRepository) GetVerificationToken(ctx context.Context, hash string) (*models.VerificationToken, error) {
+	var token models.VerificationToken
+	err := r.db.GetContext(ctx, &token, `
+		SELECT token_hash, user_id, expires_at
+		FROM email_verification_tokens
+		WHERE token_hash = $1
+	`, hash)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkEmailVerified records that the user verified their email at the given
+// time and deletes their verification tokens. It returns sql.ErrNoRows if
+// the token was redeemed concurrently.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, token *models.VerificationToken, at time.Time) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM email_verification_tokens WHERE user_id = $1 AND token_hash = $2`,
+		token.UserID, token.Hash)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM email_verification_tokens WHERE user_id = $1`, token.UserID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET email_verified_at = COALESCE(email_verified_at, $2), updated_at = $2 WHERE id = $1`,
+		token.UserID, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
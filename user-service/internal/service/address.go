@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/best-microservice/user-service/internal/models"
+	"github.com/best-microservice/user-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAddressNotFound = errors.New("address not found")
+	ErrInvalidAddress  = errors.New("invalid address")
+)
+
+type AddressService struct {
+	repo     *repository.AddressRepository
+	userRepo *repository.UserRepository
+}
+
+func NewAddressService(repo *repository.AddressRepository, userRepo *repository.UserRepository) *AddressService {
+	return &AddressService{repo: repo, userRepo: userRepo}
+}
+
+func (s *AddressService) AddAddress(ctx context.Context, address *models.Address) error {
+	if err := validateAddress(address); err != nil {
+		return err
+	}
+
+	// Make sure the owner exists
+	if uuid.Validate(address.UserID) != nil {
+		return ErrUserNotFound
+	}
+	if _, err := s.userRepo.GetUserByID(ctx, address.UserID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	// The first address in a book always becomes the default one
+	existing, err := s.repo.ListAddresses(ctx, address.UserID)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		address.IsDefault = true
+	}
+
+	return s.repo.CreateAddress(ctx, address)
+}
+
+func (s *AddressService) GetAddress(ctx context.Context, userID, id string) (*models.Address, error) {
+	if uuid.Validate(userID) != nil || uuid.Validate(id) != nil {
+		return nil, ErrAddressNotFound
+	}
+	address, err := s.repo.GetAddress(ctx, userID, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrAddressNotFound
+	}
+	return address, err
+}
+
+func (s *AddressService) ListAddresses(ctx context.Context, userID string) ([]*models.Address, error) {
+	return s.repo.ListAddresses(ctx, userID)
+}
+
+func (s *AddressService) DeleteAddress(ctx context.Context, userID, id string) error {
+	if uuid.Validate(userID) != nil || uuid.Validate(id) != nil {
+		return ErrAddressNotFound
+	}
+	err := s.repo.DeleteAddress(ctx, userID, id)
+	if err == sql.ErrNoRows {
+		return ErrAddressNotFound
+	}
+	return err
+}
+
+// AddressFieldError is an ErrInvalidAddress naming the offending field.
+type AddressFieldError struct {
+	Field       string
+	Description string
+}
+
+func (e *AddressFieldError) Error() string {
+	return fmt.Sprintf("%v: %s %s", ErrInvalidAddress, e.Field, e.Description)
+}
+
+func (e *AddressFieldError) Unwrap() error {
+	return ErrInvalidAddress
+}
+
+func validateAddress(a *models.Address) error {
+	required := []struct{ field, value string }{
+		{"recipient", a.Recipient},
+		{"line1", a.Line1},
+		{"city", a.City},
+		{"postal_code", a.PostalCode},
+		{"country", a.Country},
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			return &AddressFieldError{Field: r.field, Description: "is required"}
+		}
+	}
+	if len(a.Country) != 2 {
+		return &AddressFieldError{Field: "country", Description: "must be an ISO 3166-1 alpha-2 code"}
+	}
+	a.Country = strings.ToUpper(a.Country)
+	return nil
+}
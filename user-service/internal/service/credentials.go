@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/best-microservice/user-service/internal/mail"
+	"github.com/best-microservice/user-service/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordResetPolicy configures the links sent to users who forgot their
+// password.
+type PasswordResetPolicy struct {
+	// TokenTTL is how long a reset link stays valid.
+	TokenTTL time.Duration
+	// LinkURL is the page reset links open, with the token added as the
+	// token query parameter. The page redeems it with ResetPassword.
+	LinkURL string
+}
+
+// StartSession opens a session for the authenticated user and returns its
+// token, which is valid until it expires or the password changes.
+func (s *UserService) StartSession(ctx context.Context, user *models.User) (token string, expiresAt time.Time, err error) {
+	token, hash, err := newToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt = time.Now().Add(s.sessionTTL)
+	if err := s.repo.CreateSession(ctx, &models.Session{Hash: hash, UserID: user.ID, ExpiresAt: expiresAt}); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to store session: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// ValidateSession returns the session of the token, or ErrInvalidToken if
+// it is unknown, has expired or was ended by a password change.
+func (s *UserService) ValidateSession(ctx context.Context, token string) (*models.Session, error) {
+	session, err := s.repo.GetSession(ctx, hashToken(token))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	return session, nil
+}
+
+// RequestPasswordReset emails a reset link to the user with the given
+// email, invalidating the ones sent before. Unknown addresses are ignored,
+// so callers cannot tell them apart.
+func (s *UserService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(s.reset.TokenTTL)
+	if err := s.repo.ReplacePasswordResetToken(ctx, &models.PasswordResetToken{
+		Hash: hash, UserID: user.ID, ExpiresAt: expiresAt,
+	}); err != nil {
+		return fmt.Errorf("failed to store password reset token: %w", err)
+	}
+
+	link, err := tokenLink(s.reset.LinkURL, token)
+	if err != nil {
+		return err
+	}
+	msg, err := mail.Render(mail.ResetPassword, user.Email, struct {
+		Name      string
+		Link      string
+		ExpiresAt time.Time
+	}{user.Name, link, expiresAt})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, msg)
+}
+
+// ResetPassword redeems the token of a reset link and sets the new
+// password, ending the user's sessions.
+func (s *UserService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	stored, err := s.repo.GetPasswordResetToken(ctx, hashToken(token))
+	if err == sql.ErrNoRows {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return ErrInvalidToken
+	}
+	user, err := s.repo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.passwords.Check(newPassword, user); err != nil {
+		return err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := s.repo.ResetPassword(ctx, stored, string(hashed), now); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInvalidToken
+		}
+		return err
+	}
+	s.notifyPasswordChanged(ctx, user, now)
+	return nil
+}
+
+// ChangePassword replaces the password of a user who knows the current
+// one, ending their sessions. Users with two-factor authentication also
+// prove the second factor with code like at login.
+func (s *UserService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword, code string) error {
+	if err := s.checkPassword(ctx, userID, currentPassword); err != nil {
+		return err
+	}
+	if currentPassword == newPassword {
+		return &WeakPasswordError{"must differ from the current password"}
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.passwords.Check(newPassword, user); err != nil {
+		return err
+	}
+	if user.MFAEnabled {
+		ok, err := s.checkSecondFactor(ctx, userID, normalizeCode(code))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			failedLogins.WithLabelValues("invalid_mfa_code").Inc()
+			return ErrInvalidMFACode
+		}
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := s.repo.UpdatePassword(ctx, userID, string(hashed), now); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	s.notifyPasswordChanged(ctx, user, now)
+	return nil
+}
+
+// notifyPasswordChanged tells the user their password changed, so they
+// notice if someone else changed it. The change stands if the email
+// cannot be sent.
+func (s *UserService) notifyPasswordChanged(ctx context.Context, user *models.User, at time.Time) {
+	msg, err := mail.Render(mail.PasswordChanged, user.Email, struct {
+		Name      string
+		ChangedAt time.Time
+	}{user.Name, at})
+	if err == nil {
+		err = s.mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to send password changed email", "user_id", user.ID, "error", err)
+	}
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/best-microservice/user-service/internal/mail"
+	"github.com/best-microservice/user-service/internal/totp"
+)
+
+func TestResetPassword(t *testing.T) {
+	const userID = "6f1c7a52-3a0b-4f5e-9d7e-2b8a4c1d0e9f"
+	validToken := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery("FROM password_reset_tokens").WithArgs(hashToken("tok")).
+			WillReturnRows(tokenRows("tok", userID, time.Now().Add(time.Minute)))
+		mock.ExpectQuery("FROM users").WithArgs(userID).WillReturnRows(userRows(userID, "Ada Lovelace", "ada@example.com"))
+	}
+	tests := []struct {
+		name      string
+		password  string
+		expect    func(mock sqlmock.Sqlmock)
+		wantErr   error
+		wantWeak  bool
+		wantEmail bool
+	}{
+		{
+			name:     "valid link",
+			password: "correct-horse-battery",
+			expect: func(mock sqlmock.Sqlmock) {
+				validToken(mock)
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM password_reset_tokens WHERE user_id = $1 AND token_hash = $2")).
+					WithArgs(userID, hashToken("tok")).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE users SET password").WillReturnResult(sqlmock.NewResult(0, 1))
+				// The user is signed out everywhere
+				mock.ExpectExec("DELETE FROM sessions").WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec("DELETE FROM password_reset_tokens").WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("DELETE FROM mfa_challenges").WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("UPDATE users SET email_verified_at").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantEmail: true,
+		},
+		{
+			name:     "unknown link",
+			password: "correct-horse-battery",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("FROM password_reset_tokens").WillReturnRows(sqlmock.NewRows([]string{"token_hash", "user_id", "expires_at"}))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:     "expired link",
+			password: "correct-horse-battery",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("FROM password_reset_tokens").
+					WillReturnRows(tokenRows("tok", userID, time.Now().Add(-time.Second)))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:     "weak password",
+			password: "lovelace12345",
+			expect:   validToken,
+			wantWeak: true,
+		},
+		{
+			name:     "link redeemed concurrently",
+			password: "correct-horse-battery",
+			expect: func(mock sqlmock.Sqlmock) {
+				validToken(mock)
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM password_reset_tokens").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			wantErr: ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, sink := newTestService(t)
+			tt.expect(mock)
+
+			err := s.ResetPassword(context.Background(), "tok", tt.password)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ResetPassword() = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantWeak:
+				var weak *WeakPasswordError
+				if !errors.As(err, &weak) {
+					t.Fatalf("ResetPassword() = %v, want a weak password", err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			sent := sink.Messages()
+			if tt.wantEmail {
+				want, _ := mail.Render(mail.PasswordChanged, "ada@example.com", struct {
+					Name      string
+					ChangedAt time.Time
+				}{"Ada Lovelace", time.Now()})
+				if len(sent) != 1 || sent[0].To != "ada@example.com" || sent[0].Subject != want.Subject {
+					t.Errorf("sent %+v, want the password changed email", sent)
+				}
+			} else if len(sent) != 0 {
+				t.Errorf("sent %+v, want no email", sent)
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	const userID = "6f1c7a52-3a0b-4f5e-9d7e-2b8a4c1d0e9f"
+	secret := []byte("12345678901234567890")
+	step := totp.Step(time.Now())
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct-horse-battery"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := func(mock sqlmock.Sqlmock, mfaEnabled bool) {
+		mock.ExpectQuery("SELECT password FROM users").WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(string(hash)))
+		mock.ExpectQuery("FROM users").WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "created_at", "email_verified_at", "mfa_enabled"}).
+				AddRow(userID, "Ada Lovelace", "ada@example.com", time.Now(), time.Now(), mfaEnabled))
+	}
+	credential := func(mock sqlmock.Sqlmock, sealed []byte) {
+		mock.ExpectQuery("FROM totp_credentials").WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "confirmed_at", "last_step"}).
+				AddRow(userID, sealed, time.Now(), 0))
+	}
+	changed := func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE users SET password").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM sessions").WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("DELETE FROM password_reset_tokens").WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("DELETE FROM mfa_challenges").WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+	}
+
+	tests := []struct {
+		name    string
+		code    string
+		expect  func(mock sqlmock.Sqlmock, sealed []byte)
+		wantErr error
+	}{
+		{
+			name: "without two-factor authentication",
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				user(mock, false)
+				changed(mock)
+			},
+		},
+		{
+			name: "with an app code",
+			code: totp.Code(secret, step),
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				user(mock, true)
+				credential(mock, sealed)
+				mock.ExpectExec("UPDATE totp_credentials SET last_step").WithArgs(userID, step).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				changed(mock)
+			},
+		},
+		{
+			name: "two-factor authentication without a code",
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				user(mock, true)
+				credential(mock, sealed)
+				mock.ExpectExec("DELETE FROM recovery_codes").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantErr: ErrInvalidMFACode,
+		},
+		{
+			name: "wrong app code",
+			code: "000000",
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				user(mock, true)
+				credential(mock, sealed)
+			},
+			wantErr: ErrInvalidMFACode,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, sink := newTestService(t)
+			sealed, err := s.sealSecret(userID, secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.expect(mock, sealed)
+
+			err = s.ChangePassword(context.Background(), userID, "correct-horse-battery", "tangerine-squid-42", tt.code)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ChangePassword() = %v, want %v", err, tt.wantErr)
+				}
+				if sent := sink.Messages(); len(sent) != 0 {
+					t.Errorf("sent %+v, want no email", sent)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent := sink.Messages(); len(sent) != 1 {
+				t.Errorf("sent %+v, want the password changed email", sent)
+			}
+		})
+	}
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	const userID = "6f1c7a52-3a0b-4f5e-9d7e-2b8a4c1d0e9f"
+	userByEmail := []string{"id", "name", "email", "password", "created_at", "email_verified_at", "mfa_enabled"}
+	tests := []struct {
+		name      string
+		expect    func(mock sqlmock.Sqlmock)
+		wantEmail bool
+	}{
+		{
+			name: "known address",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("FROM users").WithArgs("ada@example.com").WillReturnRows(sqlmock.NewRows(userByEmail).
+					AddRow(userID, "Ada", "ada@example.com", "hash", time.Now(), nil, false))
+				mock.ExpectBegin()
+				// Earlier links stop working
+				mock.ExpectExec("DELETE FROM password_reset_tokens").WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO password_reset_tokens").
+					WithArgs(sqlmock.AnyArg(), userID, expiresWithin{time.Hour}, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantEmail: true,
+		},
+		{
+			name: "unknown address",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("FROM users").WillReturnRows(sqlmock.NewRows(userByEmail))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, sink := newTestService(t)
+			tt.expect(mock)
+
+			if err := s.RequestPasswordReset(context.Background(), "ada@example.com"); err != nil {
+				t.Fatal(err)
+			}
+			sent := sink.Messages()
+			if !tt.wantEmail {
+				if len(sent) != 0 {
+					t.Errorf("sent %+v to an unknown address", sent)
+				}
+				return
+			}
+			if len(sent) != 1 || !regexp.MustCompile(`https://shop\.test/reset\?token=[\w-]{43}`).MatchString(sent[0].Text) {
+				t.Errorf("sent %+v, want a reset link", sent)
+			}
+		})
+	}
+}
+
+// expiresWithin matches an expiry time ttl from now, give or take a few
+// seconds.
+type expiresWithin struct{ ttl time.Duration }
+
+func (e expiresWithin) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	d := time.Until(t) - e.ttl
+	return d > -5*time.Second && d <= 0
+}
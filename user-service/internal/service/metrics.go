@@ -0,0 +1,13 @@
+package service
+
+import (
+	"github.com/best-microservice/common/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var failedLogins = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Name:      "user_failed_logins_total",
+	Help:      "Rejected authentication attempts, by reason.",
+}, []string{"reason"})
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/best-microservice/user-service/internal/models"
+	"github.com/best-microservice/user-service/internal/totp"
+	"github.com/google/uuid"
+	qrcode "github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("no pending two-factor enrollment")
+	ErrInvalidMFACode    = errors.New("invalid two-factor code")
+)
+
+// recoveryCodeCount is how many recovery codes a confirmed enrollment gets.
+const recoveryCodeCount = 10
+
+// qrCodeSize is the width and height of enrollment QR codes in pixels.
+const qrCodeSize = 256
+
+// MFAPolicy configures two-factor authentication.
+type MFAPolicy struct {
+	// Issuer names the service in authenticator apps.
+	Issuer string
+	// Key is the AES-256 key TOTP secrets are encrypted with. The service
+	// does not start without one.
+	Key []byte
+	// ChallengeTTL is how long a login may wait for the second factor.
+	ChallengeTTL time.Duration
+	// MaxAttempts is how many wrong codes end a challenge.
+	MaxAttempts int
+}
+
+// TOTPEnrollment is what an authenticator app needs to generate codes.
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+	// QRCode is a PNG encoding URI.
+	QRCode []byte
+}
+
+// EnrollTOTP starts enrolling the user in two-factor authentication with a
+// new secret, replacing a pending enrollment. The secret is only used once
+// ConfirmTOTP checks a code generated from it.
+func (s *UserService) EnrollTOTP(ctx context.Context, userID, password string) (*TOTPEnrollment, error) {
+	if err := s.checkPassword(ctx, userID, password); err != nil {
+		return nil, err
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.sealSecret(userID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SavePendingTOTP(ctx, userID, sealed); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, fmt.Errorf("failed to store TOTP secret: %w", err)
+	}
+
+	uri := totp.URI(s.mfa.Issuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return &TOTPEnrollment{Secret: totp.Encode(secret), URI: uri, QRCode: png}, nil
+}
+
+// ConfirmTOTP enables the pending enrollment of the user if code was
+// generated from its secret, and returns new recovery codes.
+func (s *UserService) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	if uuid.Validate(userID) != nil {
+		return nil, ErrUserNotFound
+	}
+	cred, err := s.repo.GetTOTPCredential(ctx, userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrMFANotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cred.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	secret, err := s.openSecret(userID, cred.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := totp.Verify(secret, normalizeCode(code), time.Now(), cred.LastStep)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = newRecoveryCode(); err != nil {
+			return nil, err
+		}
+		hashes[i] = hashToken(normalizeCode(codes[i]))
+	}
+	if err := s.repo.ConfirmTOTP(ctx, userID, step, hashes, time.Now()); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns off two-factor authentication for a user who knows
+// their password and, once it is enabled, proves the second factor with
+// code like at login. A pending enrollment is dropped without a code.
+func (s *UserService) DisableTOTP(ctx context.Context, userID, password, code string) error {
+	if err := s.checkPassword(ctx, userID, password); err != nil {
+		return err
+	}
+	cred, err := s.repo.GetTOTPCredential(ctx, userID)
+	if err == sql.ErrNoRows {
+		return ErrMFANotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	if cred.ConfirmedAt != nil {
+		ok, err := s.checkSecondFactor(ctx, userID, normalizeCode(code))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			failedLogins.WithLabelValues("invalid_mfa_code").Inc()
+			return ErrInvalidMFACode
+		}
+	}
+	return s.repo.DeleteTOTP(ctx, userID)
+}
+
+// StartMFAChallenge holds the login of an authenticated user with
+// two-factor authentication until CompleteMFAChallenge, and returns the
+// challenge token.
+func (s *UserService) StartMFAChallenge(ctx context.Context, user *models.User) (token string, expiresAt time.Time, err error) {
+	token, hash, err := newToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt = time.Now().Add(s.mfa.ChallengeTTL)
+	if err := s.repo.CreateMFAChallenge(ctx, &models.MFAChallenge{Hash: hash, UserID: user.ID, ExpiresAt: expiresAt}); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to store MFA challenge: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// CompleteMFAChallenge checks code, from the authenticator app or a recovery
+// code, against the challenge and returns the user whose login it
+// completes. Each challenge, app code and recovery code is accepted once;
+// after MaxAttempts wrong codes the challenge ends and the user has to log
+// in again.
+func (s *UserService) CompleteMFAChallenge(ctx context.Context, token, code string) (*models.User, error) {
+	hash := hashToken(token)
+	challenge, err := s.repo.GetMFAChallenge(ctx, hash)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= s.mfa.MaxAttempts {
+		return nil, ErrInvalidToken
+	}
+
+	ok, err := s.checkSecondFactor(ctx, challenge.UserID, normalizeCode(code))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		failedLogins.WithLabelValues("invalid_mfa_code").Inc()
+		attempts, err := s.repo.CountMFAFailure(ctx, hash)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if attempts >= s.mfa.MaxAttempts {
+			if err := s.repo.DeleteMFAChallenge(ctx, hash); err != nil && err != sql.ErrNoRows {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidMFACode
+	}
+
+	if err := s.repo.DeleteMFAChallenge(ctx, hash); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	return s.repo.GetUserByID(ctx, challenge.UserID)
+}
+
+// checkSecondFactor reports whether code is a current app code or an unused
+// recovery code of the user, and uses it up.
+func (s *UserService) checkSecondFactor(ctx context.Context, userID, code string) (bool, error) {
+	cred, err := s.repo.GetTOTPCredential(ctx, userID)
+	if err == sql.ErrNoRows {
+		// Two-factor authentication was turned off after the challenge
+		// started; the challenge was deleted with it.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if cred.ConfirmedAt == nil {
+		return false, nil
+	}
+
+	if len(code) == totp.Digits {
+		secret, err := s.openSecret(userID, cred.Secret)
+		if err != nil {
+			return false, err
+		}
+		step, ok := totp.Verify(secret, code, time.Now(), cred.LastStep)
+		if !ok {
+			return false, nil
+		}
+		// Another login may have used the same code in the meantime
+		if err := s.repo.UseTOTPStep(ctx, userID, step); err != nil {
+			if err == sql.ErrNoRows {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	if err := s.repo.UseRecoveryCode(ctx, userID, hashToken(code)); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// checkPassword returns ErrInvalidCredentials unless password is the
+// password of the user.
+func (s *UserService) checkPassword(ctx context.Context, userID, password string) error {
+	if uuid.Validate(userID) != nil {
+		return ErrUserNotFound
+	}
+	hash, err := s.repo.GetPasswordHash(ctx, userID)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		failedLogins.WithLabelValues("wrong_current_password").Inc()
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// newRecoveryCode returns a random code of 80 bits, written as four groups
+// of four characters such as 7kq2-m4xd-a9rt-c3pw.
+func newRecoveryCode() (string, error) {
+	var b [10]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b[:]))
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16], nil
+}
+
+// normalizeCode drops the separators users may type in codes and ignores
+// case.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+// sealSecret encrypts a TOTP secret for storage. The user ID is
+// authenticated with it, so a secret copied to another user's row does not
+// decrypt.
+func (s *UserService) sealSecret(userID string, secret []byte) ([]byte, error) {
+	aead, err := s.secretCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, secret, []byte(userID)), nil
+}
+
+func (s *UserService) openSecret(userID string, sealed []byte) ([]byte, error) {
+	aead, err := s.secretCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("stored TOTP secret is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	secret, err := aead.Open(nil, nonce, ciphertext, []byte(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+	return secret, nil
+}
+
+func (s *UserService) secretCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.mfa.Key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
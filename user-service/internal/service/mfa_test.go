@@ -0,0 +1,356 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/best-microservice/user-service/internal/totp"
+)
+
+func TestCompleteMFAChallenge(t *testing.T) {
+	const userID = "6f1c7a52-3a0b-4f5e-9d7e-2b8a4c1d0e9f"
+	secret := []byte("12345678901234567890")
+	step := totp.Step(time.Now())
+	appCode := totp.Code(secret, step)
+	const recoveryCode = "abcd-efgh-jkmn-pqrs"
+
+	challenge := func(mock sqlmock.Sqlmock, attempts int, expiresAt time.Time) {
+		mock.ExpectQuery("FROM mfa_challenges").WithArgs(hashToken("challenge")).
+			WillReturnRows(sqlmock.NewRows([]string{"token_hash", "user_id", "attempts", "expires_at"}).
+				AddRow(hashToken("challenge"), userID, attempts, expiresAt))
+	}
+	credential := func(mock sqlmock.Sqlmock, sealed []byte, lastStep int64) {
+		mock.ExpectQuery("FROM totp_credentials").WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "confirmed_at", "last_step"}).
+				AddRow(userID, sealed, time.Now(), lastStep))
+	}
+	completed := func(mock sqlmock.Sqlmock) {
+		mock.ExpectExec("DELETE FROM mfa_challenges").WithArgs(hashToken("challenge")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("FROM users").WithArgs(userID).WillReturnRows(userRows(userID, "Ada", "ada@example.com"))
+	}
+	failed := func(mock sqlmock.Sqlmock, attempts int) {
+		mock.ExpectQuery("UPDATE mfa_challenges SET attempts").
+			WillReturnRows(sqlmock.NewRows([]string{"attempts"}).AddRow(attempts))
+	}
+
+	tests := []struct {
+		name    string
+		code    string
+		expect  func(mock sqlmock.Sqlmock, sealed []byte)
+		wantErr error
+	}{
+		{
+			name: "app code",
+			code: appCode,
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				challenge(mock, 0, time.Now().Add(time.Minute))
+				credential(mock, sealed, 0)
+				mock.ExpectExec("UPDATE totp_credentials SET last_step").WithArgs(userID, step).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				completed(mock)
+			},
+		},
+		{
+			name: "app code used by a concurrent login",
+			code: appCode,
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				challenge(mock, 0, time.Now().Add(time.Minute))
+				credential(mock, sealed, 0)
+				mock.ExpectExec("UPDATE totp_credentials SET last_step").WillReturnResult(sqlmock.NewResult(0, 0))
+				failed(mock, 1)
+			},
+			wantErr: ErrInvalidMFACode,
+		},
+		{
+			name: "replayed app code",
+			code: appCode,
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				challenge(mock, 0, time.Now().Add(time.Minute))
+				credential(mock, sealed, step)
+				failed(mock, 1)
+			},
+			wantErr: ErrInvalidMFACode,
+		},
+		{
+			name: "recovery code as typed",
+			code: " ABCD efgh-JKMN pqrs",
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				challenge(mock, 0, time.Now().Add(time.Minute))
+				credential(mock, sealed, 0)
+				mock.ExpectExec("DELETE FROM recovery_codes").WithArgs(userID, hashToken(normalizeCode(recoveryCode))).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				completed(mock)
+			},
+		},
+		{
+			name: "used recovery code",
+			code: recoveryCode,
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				challenge(mock, 0, time.Now().Add(time.Minute))
+				credential(mock, sealed, 0)
+				mock.ExpectExec("DELETE FROM recovery_codes").WillReturnResult(sqlmock.NewResult(0, 0))
+				failed(mock, 1)
+			},
+			wantErr: ErrInvalidMFACode,
+		},
+		{
+			name: "last wrong code ends the challenge",
+			code: "000000",
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				challenge(mock, 2, time.Now().Add(time.Minute))
+				credential(mock, sealed, 0)
+				failed(mock, 3)
+				mock.ExpectExec("DELETE FROM mfa_challenges").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantErr: ErrInvalidMFACode,
+		},
+		{
+			name: "too many attempts",
+			code: appCode,
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				challenge(mock, 3, time.Now().Add(time.Minute))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "expired challenge",
+			code: appCode,
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				challenge(mock, 0, time.Now().Add(-time.Second))
+			},
+			wantErr: ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, _ := newTestService(t)
+			sealed, err := s.sealSecret(userID, secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.expect(mock, sealed)
+
+			user, err := s.CompleteMFAChallenge(context.Background(), "challenge", tt.code)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CompleteMFAChallenge() = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.ID != userID {
+				t.Errorf("logged in %s, want %s", user.ID, userID)
+			}
+		})
+	}
+}
+
+func TestDisableTOTP(t *testing.T) {
+	const userID = "6f1c7a52-3a0b-4f5e-9d7e-2b8a4c1d0e9f"
+	secret := []byte("12345678901234567890")
+	step := totp.Step(time.Now())
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct-horse-battery"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	password := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery("SELECT password FROM users").WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(string(hash)))
+	}
+	credential := func(mock sqlmock.Sqlmock, sealed []byte, confirmedAt any) {
+		mock.ExpectQuery("FROM totp_credentials").WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "confirmed_at", "last_step"}).
+				AddRow(userID, sealed, confirmedAt, 0))
+	}
+	deleted := func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM totp_credentials").WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM recovery_codes").WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 10))
+		mock.ExpectExec("DELETE FROM mfa_challenges").WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		code     string
+		expect   func(mock sqlmock.Sqlmock, sealed []byte)
+		wantErr  error
+	}{
+		{
+			name:     "password and app code",
+			password: "correct-horse-battery",
+			code:     totp.Code(secret, step),
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				password(mock)
+				credential(mock, sealed, time.Now())
+				credential(mock, sealed, time.Now())
+				mock.ExpectExec("UPDATE totp_credentials SET last_step").WithArgs(userID, step).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				deleted(mock)
+			},
+		},
+		{
+			name:     "password and recovery code",
+			password: "correct-horse-battery",
+			code:     "abcd-efgh-jkmn-pqrs",
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				password(mock)
+				credential(mock, sealed, time.Now())
+				credential(mock, sealed, time.Now())
+				mock.ExpectExec("DELETE FROM recovery_codes").WithArgs(userID, hashToken("abcdefghjkmnpqrs")).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				deleted(mock)
+			},
+		},
+		{
+			name:     "password without a code",
+			password: "correct-horse-battery",
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				password(mock)
+				credential(mock, sealed, time.Now())
+				credential(mock, sealed, time.Now())
+				mock.ExpectExec("DELETE FROM recovery_codes").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantErr: ErrInvalidMFACode,
+		},
+		{
+			name:     "wrong app code",
+			password: "correct-horse-battery",
+			code:     "000000",
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				password(mock)
+				credential(mock, sealed, time.Now())
+				credential(mock, sealed, time.Now())
+			},
+			wantErr: ErrInvalidMFACode,
+		},
+		{
+			name:     "pending enrollment needs no code",
+			password: "correct-horse-battery",
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				password(mock)
+				credential(mock, sealed, nil)
+				deleted(mock)
+			},
+		},
+		{
+			name:     "wrong password",
+			password: "incorrect-horse",
+			code:     totp.Code(secret, step),
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				password(mock)
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:     "not enrolled",
+			password: "correct-horse-battery",
+			code:     totp.Code(secret, step),
+			expect: func(mock sqlmock.Sqlmock, sealed []byte) {
+				password(mock)
+				mock.ExpectQuery("FROM totp_credentials").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "confirmed_at", "last_step"}))
+			},
+			wantErr: ErrMFANotEnrolled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, _ := newTestService(t)
+			sealed, err := s.sealSecret(userID, secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.expect(mock, sealed)
+
+			err = s.DisableTOTP(context.Background(), userID, tt.password, tt.code)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("DisableTOTP() = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	format := regexp.MustCompile(`^[a-z2-7]{4}(-[a-z2-7]{4}){3}$`)
+	seen := map[string]bool{}
+	for range 100 {
+		code, err := newRecoveryCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !format.MatchString(code) {
+			t.Fatalf("recovery code %q, want four groups of four base32 characters", code)
+		}
+		if seen[code] {
+			t.Fatalf("recovery code %q generated twice", code)
+		}
+		seen[code] = true
+
+		// Codes are stored as ConfirmTOTP hashes them and looked up as
+		// CompleteMFAChallenge hashes what the user typed
+		typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+		if hashToken(normalizeCode(typed)) != hashToken(normalizeCode(code)) {
+			t.Fatalf("%q typed as %q does not match", code, typed)
+		}
+	}
+}
+
+func TestSealSecret(t *testing.T) {
+	s, _, _ := newTestService(t)
+	secret := []byte("12345678901234567890")
+	sealed, err := s.sealSecret("u1", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sealed), string(secret)) {
+		t.Fatal("sealed secret contains the plain secret")
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		sealed []byte
+		key    []byte
+		wantOK bool
+	}{
+		{name: "owner", userID: "u1", sealed: sealed, key: s.mfa.Key, wantOK: true},
+		{name: "copied to another user", userID: "u2", sealed: sealed, key: s.mfa.Key},
+		{name: "other key", userID: "u1", sealed: sealed, key: []byte(strings.Repeat("k", 32))},
+		{name: "truncated", userID: "u1", sealed: sealed[:4], key: s.mfa.Key},
+		{name: "short key", userID: "u1", sealed: sealed, key: []byte("k")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opener := *s
+			opener.mfa.Key = tt.key
+			got, err := opener.openSecret(tt.userID, tt.sealed)
+			if tt.wantOK {
+				if err != nil || string(got) != string(secret) {
+					t.Fatalf("openSecret() = %q, %v; want the secret", got, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("openSecret() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/best-microservice/user-service/internal/models"
+)
+
+// maxPasswordBytes is the longest password bcrypt accepts.
+const maxPasswordBytes = 72
+
+// commonPasswordList holds, one per line, passwords rejected whatever the
+// policy. They are compared ignoring case.
+//
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = func() map[string]bool {
+	m := map[string]bool{}
+	for _, p := range strings.Fields(commonPasswordList) {
+		m[p] = true
+	}
+	return m
+}()
+
+// PasswordPolicy is the strength required of new passwords.
+type PasswordPolicy struct {
+	MinLength int
+	// MinClasses is how many of lowercase letters, uppercase letters,
+	// digits and other characters a password must use.
+	MinClasses int
+}
+
+// WeakPasswordError is returned for passwords the policy rejects. Reason
+// completes a sentence starting with the password field, e.g. "is too
+// common".
+type WeakPasswordError struct {
+	Reason string
+}
+
+func (e *WeakPasswordError) Error() string {
+	return "weak password: password " + e.Reason
+}
+
+// Check returns a *WeakPasswordError if password is not strong enough for
+// user, whose name and email it must not contain.
+func (p PasswordPolicy) Check(password string, user *models.User) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return &WeakPasswordError{fmt.Sprintf("must be at least %d characters", p.MinLength)}
+	}
+	if len(password) > maxPasswordBytes {
+		return &WeakPasswordError{fmt.Sprintf("must be at most %d bytes", maxPasswordBytes)}
+	}
+	if n := characterClasses(password); n < p.MinClasses {
+		return &WeakPasswordError{fmt.Sprintf("must use at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses)}
+	}
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] {
+		return &WeakPasswordError{"is too common"}
+	}
+	for _, part := range personalParts(user) {
+		if strings.Contains(lower, part) {
+			return &WeakPasswordError{"must not contain your name or email address"}
+		}
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
+
+// personalParts returns the lowercased words of the user's name and the
+// local part of their email, leaving out ones too short to matter.
+func personalParts(user *models.User) []string {
+	local, _, _ := strings.Cut(user.Email, "@")
+	var parts []string
+	for _, s := range append(strings.Fields(user.Name), local) {
+		if utf8.RuneCountInString(s) >= 4 {
+			parts = append(parts, strings.ToLower(s))
+		}
+	}
+	return parts
+}
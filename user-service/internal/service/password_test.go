@@ -0,0 +1,44 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/best-microservice/user-service/internal/models"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 10, MinClasses: 2}
+	user := &models.User{Name: "Ada Lovelace", Email: "countess.ada@example.com"}
+	tests := []struct {
+		name       string
+		password   string
+		wantReason string
+	}{
+		{"strong", "correct-horse-battery", ""},
+		{"letters and digits", "tangerine42x", ""},
+		{"too short", "Sh0rt!", "must be at least 10 characters"},
+		{"length counts characters, not bytes", "ÄÖÜäöüßéè1", ""},
+		{"too long for bcrypt", strings.Repeat("aB1", 25), "must be at most 72 bytes"},
+		{"one class", "onlylowercaseletters", "must use at least 2 of"},
+		{"common", "Football123", "is too common"},
+		{"name", "xx-Lovelace-99", "must not contain your name or email address"},
+		{"email local part", "9countess.ada9", "must not contain your name or email address"},
+		{"short name parts are allowed", "Ada-is-here-42", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.password, user)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Fatalf("Check() = %v, want nil", err)
+				}
+				return
+			}
+			weak, ok := err.(*WeakPasswordError)
+			if !ok || !strings.Contains(weak.Reason, tt.wantReason) {
+				t.Fatalf("Check() = %v, want a weak password that %s", err, tt.wantReason)
+			}
+		})
+	}
+}
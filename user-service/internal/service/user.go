@@ -4,28 +4,52 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"log/slog"
+	"time"
 
+	"github.com/best-microservice/user-service/internal/mail"
 	"github.com/best-microservice/user-service/internal/models"
 	"github.com/best-microservice/user-service/internal/repository"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrEmailTaken         = errors.New("email already exists")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
 type UserService struct {
-	repo *repository.UserRepository
+	repo         *repository.UserRepository
+	mailer       mail.Mailer
+	passwords    PasswordPolicy
+	verification VerificationPolicy
+	reset        PasswordResetPolicy
+	mfa          MFAPolicy
+	// sessionTTL is how long the sessions opened by StartSession last.
+	sessionTTL time.Duration
 }
 
-func NewUserService(repo *repository.UserRepository) *UserService {
-	return &UserService{repo: repo}
+func NewUserService(repo *repository.UserRepository, mailer mail.Mailer, passwords PasswordPolicy,
+	verification VerificationPolicy, reset PasswordResetPolicy, mfa MFAPolicy, sessionTTL time.Duration) *UserService {
+	return &UserService{repo: repo, mailer: mailer, passwords: passwords, verification: verification,
+		reset: reset, mfa: mfa, sessionTTL: sessionTTL}
 }
 
+// CreateUser registers a user and sends them a verification link. If the
+// email cannot be sent the user is still created and may ask for a new link.
 func (s *UserService) CreateUser(ctx context.Context, user *models.User) error {
 	// Check if email already exists
 	_, err := s.repo.GetUserByEmail(ctx, user.Email)
 	if err == nil {
-		return errors.New("email already exists")
+		return ErrEmailTaken
 	} else if err != sql.ErrNoRows {
 		return err
 	}
+	if err := s.passwords.Check(user.Password, user); err != nil {
+		return err
+	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
@@ -34,22 +58,45 @@ func (s *UserService) CreateUser(ctx context.Context, user *models.User) error {
 	}
 	user.Password = string(hashedPassword)
 
-	return s.repo.CreateUser(ctx, user)
+	if err := s.repo.CreateUser(ctx, user); err != nil {
+		return err
+	}
+	if err := s.SendVerification(ctx, user); err != nil {
+		slog.ErrorContext(ctx, "failed to send verification email", "user_id", user.ID, "error", err)
+	}
+	return nil
 }
 
 func (s *UserService) GetUser(ctx context.Context, id string) (*models.User, error) {
-	return s.repo.GetUserByID(ctx, id)
+	// IDs are UUIDs; anything else cannot exist
+	if uuid.Validate(id) != nil {
+		return nil, ErrUserNotFound
+	}
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	return user, err
 }
 
 func (s *UserService) Authenticate(ctx context.Context, email, password string) (*models.User, error) {
 	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err == sql.ErrNoRows {
+		failedLogins.WithLabelValues("unknown_user").Inc()
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		failedLogins.WithLabelValues("wrong_password").Inc()
+		return nil, ErrInvalidCredentials
+	}
+	if s.verification.RequiredForLogin && user.EmailVerifiedAt == nil {
+		failedLogins.WithLabelValues("email_not_verified").Inc()
+		return nil, ErrEmailNotVerified
 	}
 
 	return user, nil
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/best-microservice/user-service/internal/mail"
+	"github.com/best-microservice/user-service/internal/models"
+)
+
+var (
+	ErrInvalidToken     = errors.New("invalid or expired token")
+	ErrEmailNotVerified = errors.New("email address not verified")
+)
+
+// VerificationPolicy configures email verification.
+type VerificationPolicy struct {
+	// TokenTTL is how long a verification link stays valid.
+	TokenTTL time.Duration
+	// LinkURL is the page verification links open, with the token added
+	// as the token query parameter. The page redeems it with VerifyEmail.
+	LinkURL string
+	// RequiredForLogin rejects logins of unverified users.
+	RequiredForLogin bool
+}
+
+// SendVerification emails the user a new verification link, invalidating
+// the ones sent before.
+func (s *UserService) SendVerification(ctx context.Context, user *models.User) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(s.verification.TokenTTL)
+	if err := s.repo.ReplaceVerificationToken(ctx, &models.VerificationToken{
+		Hash: hash, UserID: user.ID, ExpiresAt: expiresAt,
+	}); err != nil {
+		return fmt.Errorf("failed to store verification token: %w", err)
+	}
+
+	link, err := tokenLink(s.verification.LinkURL, token)
+	if err != nil {
+		return err
+	}
+	msg, err := mail.Render(mail.VerifyEmail, user.Email, struct {
+		Name      string
+		Link      string
+		ExpiresAt time.Time
+	}{user.Name, link, expiresAt})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, msg)
+}
+
+// ResendVerification sends a new verification link to the user with the
+// given email. Unknown and verified addresses are ignored, so callers
+// cannot tell them apart.
+func (s *UserService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	return s.SendVerification(ctx, user)
+}
+
+// VerifyEmail redeems the token of a verification link and returns the
+// verified user.
+func (s *UserService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	stored, err := s.repo.GetVerificationToken(ctx, hashToken(token))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.repo.MarkEmailVerified(ctx, stored, time.Now()); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	return s.repo.GetUserByID(ctx, stored.UserID)
+}
+
+// newToken returns a random token for a link and the hash it is stored as.
+func newToken() (token, hash string, err error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b[:])
+	return token, hashToken(token), nil
+}
+
+// hashToken returns the stored form of token. Tokens are random and long
+// enough that a fast hash does not make them guessable.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenLink adds token to the query of the page at base.
+func tokenLink(base, token string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid link URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/best-microservice/user-service/internal/mail"
+	"github.com/best-microservice/user-service/internal/repository"
+)
+
+// newTestService returns a service whose repository runs on a mock
+// database; tests list the queries they expect on mock.
+func newTestService(t *testing.T) (s *UserService, mock sqlmock.Sqlmock, sink *mail.MemorySink) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	sink = mail.NewMemorySink()
+	s = NewUserService(repository.NewUserRepository(sqlx.NewDb(db, "postgres")), sink,
+		PasswordPolicy{MinLength: 10, MinClasses: 2},
+		VerificationPolicy{TokenTTL: time.Hour, LinkURL: "https://shop.test/verify"},
+		PasswordResetPolicy{TokenTTL: time.Hour, LinkURL: "https://shop.test/reset"},
+		MFAPolicy{Issuer: "Test", Key: make([]byte, 32), ChallengeTTL: time.Minute, MaxAttempts: 3},
+		time.Hour)
+	return s, mock, sink
+}
+
+// tokenRows is the row of a stored verification, reset or session token.
+func tokenRows(token, userID string, expiresAt time.Time) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"token_hash", "user_id", "expires_at"}).
+		AddRow(hashToken(token), userID, expiresAt)
+}
+
+// userRows is the row GetUserByID reads.
+func userRows(id, name, email string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "email", "created_at", "email_verified_at", "mfa_enabled"}).
+		AddRow(id, name, email, time.Now(), nil, false)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	const userID = "6f1c7a52-3a0b-4f5e-9d7e-2b8a4c1d0e9f"
+	tests := []struct {
+		name    string
+		expect  func(mock sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "valid link",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("FROM email_verification_tokens").WithArgs(hashToken("tok")).
+					WillReturnRows(tokenRows("tok", userID, time.Now().Add(time.Minute)))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM email_verification_tokens WHERE user_id = $1 AND token_hash = $2")).
+					WithArgs(userID, hashToken("tok")).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM email_verification_tokens WHERE user_id = $1")).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("UPDATE users SET email_verified_at").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectQuery("FROM users").WithArgs(userID).WillReturnRows(userRows(userID, "Ada", "ada@example.com"))
+			},
+		},
+		{
+			name: "unknown link",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("FROM email_verification_tokens").WillReturnRows(sqlmock.NewRows([]string{"token_hash", "user_id", "expires_at"}))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "expired link",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("FROM email_verification_tokens").
+					WillReturnRows(tokenRows("tok", userID, time.Now().Add(-time.Second)))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "link redeemed concurrently",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("FROM email_verification_tokens").
+					WillReturnRows(tokenRows("tok", userID, time.Now().Add(time.Minute)))
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM email_verification_tokens").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			wantErr: ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock, _ := newTestService(t)
+			tt.expect(mock)
+
+			user, err := s.VerifyEmail(context.Background(), "tok")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("VerifyEmail() = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.ID != userID {
+				t.Errorf("verified user %s, want %s", user.ID, userID)
+			}
+		})
+	}
+}
+
+func TestTokenLink(t *testing.T) {
+	tests := []struct {
+		base    string
+		want    string
+		wantErr bool
+	}{
+		{"https://shop.test/verify", "https://shop.test/verify?token=a%2Bb", false},
+		{"https://shop.test/verify?lang=de", "https://shop.test/verify?lang=de&token=a%2Bb", false},
+		{"https://shop.test/verify?token=stale", "https://shop.test/verify?token=a%2Bb", false},
+		{"://missing-scheme", "", true},
+	}
+	for _, tt := range tests {
+		got, err := tokenLink(tt.base, "a+b")
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("tokenLink(%q) = %q, %v; want %q", tt.base, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 secret of the RFC 6238 test vectors.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		if got := Code(rfcSecret, Step(time.Unix(tt.unix, 0))); got != tt.want {
+			t.Errorf("code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	code := func(offset int64) string { return Code(rfcSecret, step+offset) }
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantOK   bool
+		wantStep int64
+	}{
+		{"current code", code(0), 0, true, step},
+		{"previous code within skew", code(-1), 0, true, step - 1},
+		{"next code within skew", code(1), 0, true, step + 1},
+		{"too old", code(-2), 0, false, 0},
+		{"too new", code(2), 0, false, 0},
+		{"replayed code", code(0), step, false, 0},
+		{"code older than the last one used", code(-1), step, false, 0},
+		{"later code after a used one", code(1), step, true, step + 1},
+		{"wrong code", "000000", 0, false, 0},
+		{"too short", code(0)[:5], 0, false, 0},
+		{"too long", code(0) + "0", 0, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Verify(rfcSecret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK || got != tt.wantStep {
+				t.Fatalf("Verify(%s) = %d, %v; want %d, %v", tt.code, got, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("Best Shop", "ada@example.com", rfcSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Best Shop:ada@example.com" {
+		t.Errorf("URI %s, want otpauth://totp/Best Shop:ada@example.com", u)
+	}
+	q := u.Query()
+	if q.Get("secret") != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" || q.Get("issuer") != "Best Shop" ||
+		q.Get("digits") != "6" || q.Get("period") != "30" || q.Get("algorithm") != "SHA1" {
+		t.Errorf("URI parameters %v", q)
+	}
+}
@@ -0,0 +1,126 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/best-microservice/common/apierror"
+	userpb "github.com/best-microservice/common/protos/user"
+	"github.com/best-microservice/user-service/internal/models"
+	"github.com/best-microservice/user-service/internal/service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *UserServer) AddAddress(ctx context.Context, req *userpb.AddAddressRequest) (*userpb.Address, error) {
+	if req.UserId == "" {
+		return nil, apierror.Invalid("user_id", "is required")
+	}
+	if req.Address == nil {
+		return nil, apierror.Invalid("address", "is required")
+	}
+
+	address := &models.Address{
+		UserID:     req.UserId,
+		Label:      req.Address.Label,
+		Recipient:  req.Address.Recipient,
+		Line1:      req.Address.Line1,
+		Line2:      req.Address.Line2,
+		City:       req.Address.City,
+		State:      req.Address.State,
+		PostalCode: req.Address.PostalCode,
+		Country:    req.Address.Country,
+		Phone:      req.Address.Phone,
+		IsDefault:  req.Address.IsDefault,
+	}
+
+	err := s.addressService.AddAddress(ctx, address)
+	if err != nil {
+		var fieldErr *service.AddressFieldError
+		if errors.As(err, &fieldErr) {
+			return nil, apierror.Invalid("address."+fieldErr.Field, fieldErr.Description)
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			return nil, apierror.NotFound(apierror.ReasonUserNotFound, "user", req.UserId)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to add address: %v", err)
+	}
+
+	return addressToResponse(address), nil
+}
+
+func (s *UserServer) GetAddress(ctx context.Context, req *userpb.GetAddressRequest) (*userpb.Address, error) {
+	if req.UserId == "" {
+		return nil, apierror.Invalid("user_id", "is required")
+	}
+	if req.Id == "" {
+		return nil, apierror.Invalid("id", "is required")
+	}
+
+	address, err := s.addressService.GetAddress(ctx, req.UserId, req.Id)
+	if err != nil {
+		if errors.Is(err, service.ErrAddressNotFound) {
+			return nil, apierror.NotFound(apierror.ReasonAddressNotFound, "address", req.Id)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to get address: %v", err)
+	}
+
+	return addressToResponse(address), nil
+}
+
+func (s *UserServer) ListAddresses(ctx context.Context, req *userpb.ListAddressesRequest) (*userpb.ListAddressesResponse, error) {
+	if req.UserId == "" {
+		return nil, apierror.Invalid("user_id", "is required")
+	}
+
+	addresses, err := s.addressService.ListAddresses(ctx, req.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list addresses: %v", err)
+	}
+
+	var pbAddresses []*userpb.Address
+	for _, a := range addresses {
+		pbAddresses = append(pbAddresses, addressToResponse(a))
+	}
+
+	return &userpb.ListAddressesResponse{Addresses: pbAddresses}, nil
+}
+
+func (s *UserServer) DeleteAddress(ctx context.Context, req *userpb.DeleteAddressRequest) (*userpb.DeleteAddressResponse, error) {
+	if req.UserId == "" {
+		return nil, apierror.Invalid("user_id", "is required")
+	}
+	if req.Id == "" {
+		return nil, apierror.Invalid("id", "is required")
+	}
+
+	err := s.addressService.DeleteAddress(ctx, req.UserId, req.Id)
+	if err != nil {
+		if errors.Is(err, service.ErrAddressNotFound) {
+			return nil, apierror.NotFound(apierror.ReasonAddressNotFound, "address", req.Id)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to delete address: %v", err)
+	}
+
+	return &userpb.DeleteAddressResponse{}, nil
+}
+
+func addressToResponse(a *models.Address) *userpb.Address {
+	return &userpb.Address{
+		Id:         a.ID,
+		UserId:     a.UserID,
+		Label:      a.Label,
+		Recipient:  a.Recipient,
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		State:      a.State,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+		Phone:      a.Phone,
+		IsDefault:  a.IsDefault,
+		CreatedAt:  a.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -2,8 +2,12 @@ package transport
 
 import (
 	"context"
+	"errors"
+	"net/mail"
+	"strings"
 	"time"
 
+	"github.com/best-microservice/common/apierror"
 	userpb "github.com/best-microservice/common/protos/user"
 	"github.com/best-microservice/user-service/internal/models"
 	"github.com/best-microservice/user-service/internal/service"
@@ -14,14 +18,25 @@ import (
 
 type UserServer struct {
 	userpb.UnimplementedUserServiceServer
-	service *service.UserService
+	service        *service.UserService
+	addressService *service.AddressService
 }
 
-func NewUserServer(service *service.UserService) *UserServer {
-	return &UserServer{service: service}
+func NewUserServer(service *service.UserService, addressService *service.AddressService) *UserServer {
+	return &UserServer{service: service, addressService: addressService}
 }
 
 func (s *UserServer) CreateUser(ctx context.Context, req *userpb.CreateUserRequest) (*userpb.UserResponse, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, apierror.Invalid("name", "is required")
+	}
+	if !validEmail(req.Email) {
+		return nil, apierror.Invalid("email", "must be a valid email address")
+	}
+	if req.Password == "" {
+		return nil, apierror.Invalid("password", "is required")
+	}
+
 	newUser := &models.User{
 		Name:     req.Name,
 		Email:    req.Email,
@@ -30,47 +45,293 @@ func (s *UserServer) CreateUser(ctx context.Context, req *userpb.CreateUserReque
 
 	err := s.service.CreateUser(ctx, newUser)
 	if err != nil {
+		if errors.Is(err, service.ErrEmailTaken) {
+			return nil, apierror.New(codes.AlreadyExists, apierror.ReasonEmailTaken, "a user with this email already exists",
+				apierror.WithFieldViolation("email", "is already registered"))
+		}
+		var weak *service.WeakPasswordError
+		if errors.As(err, &weak) {
+			return nil, apierror.Invalid("password", weak.Reason)
+		}
 		return nil, status.Errorf(codes.Internal, "failed to create user: %v", err)
 	}
 
-	return &userpb.UserResponse{
-		Id:        newUser.ID,
-		Name:      newUser.Name,
-		Email:     newUser.Email,
-		CreatedAt: newUser.CreatedAt.Format(time.RFC3339),
-	}, nil
+	return userToResponse(newUser), nil
 }
 
 func (s *UserServer) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.UserResponse, error) {
 	user, err := s.service.GetUser(ctx, req.Id)
 	if err != nil {
-		return nil, status.Errorf(codes.NotFound, "user not found: %v", err)
+		if errors.Is(err, service.ErrUserNotFound) {
+			return nil, apierror.NotFound(apierror.ReasonUserNotFound, "user", req.Id)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to get user: %v", err)
 	}
 
-	return &userpb.UserResponse{
-		Id:        user.ID,
-		Name:      user.Name,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt.Format(time.RFC3339),
-	}, nil
+	return userToResponse(user), nil
 }
 
 func (s *UserServer) AuthenticateUser(ctx context.Context, req *userpb.AuthRequest) (*userpb.AuthResponse, error) {
+	if req.Email == "" {
+		return nil, apierror.Invalid("email", "is required")
+	}
+	if req.Password == "" {
+		return nil, apierror.Invalid("password", "is required")
+	}
+
 	user, err := s.service.Authenticate(ctx, req.Email, req.Password)
 	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, "authentication failed: %v", err)
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			return nil, apierror.New(codes.Unauthenticated, apierror.ReasonInvalidCredentials, "invalid email or password")
+		}
+		if errors.Is(err, service.ErrEmailNotVerified) {
+			return nil, apierror.New(codes.PermissionDenied, apierror.ReasonEmailNotVerified, "the email address has not been verified yet")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to authenticate user: %v", err)
 	}
 
-	// In a real implementation, generate a proper JWT token
-	token := "generated-jwt-token"
+	// No session until the second factor is checked
+	if user.MFAEnabled {
+		token, expiresAt, err := s.service.StartMFAChallenge(ctx, user)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to start MFA challenge: %v", err)
+		}
+		return &userpb.AuthResponse{
+			MfaChallenge: &userpb.MfaChallenge{Token: token, ExpiresAt: expiresAt.Format(time.RFC3339)},
+		}, nil
+	}
+	return s.startSession(ctx, user)
+}
+
+func (s *UserServer) CompleteMfaLogin(ctx context.Context, req *userpb.CompleteMfaLoginRequest) (*userpb.AuthResponse, error) {
+	if req.MfaToken == "" {
+		return nil, apierror.Invalid("mfa_token", "is required")
+	}
+	if req.Code == "" {
+		return nil, apierror.Invalid("code", "is required")
+	}
+
+	user, err := s.service.CompleteMFAChallenge(ctx, req.MfaToken, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidToken):
+			return nil, apierror.New(codes.Unauthenticated, apierror.ReasonInvalidToken, "the login has expired, log in again",
+				apierror.WithFieldViolation("mfa_token", "is invalid or has expired"))
+		case errors.Is(err, service.ErrInvalidMFACode):
+			return nil, invalidMFACode()
+		}
+		return nil, status.Errorf(codes.Internal, "failed to complete login: %v", err)
+	}
+	return s.startSession(ctx, user)
+}
+
+func (s *UserServer) startSession(ctx context.Context, user *models.User) (*userpb.AuthResponse, error) {
+	token, expiresAt, err := s.service.StartSession(ctx, user)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to start session: %v", err)
+	}
 
 	return &userpb.AuthResponse{
-		Token: token,
-		User: &userpb.UserResponse{
-			Id:        user.ID,
-			Name:      user.Name,
-			Email:     user.Email,
-			CreatedAt: user.CreatedAt.Format(time.RFC3339),
-		},
+		Token:     token,
+		User:      userToResponse(user),
+		ExpiresAt: expiresAt.Format(time.RFC3339),
 	}, nil
 }
+
+func (s *UserServer) ValidateSession(ctx context.Context, req *userpb.ValidateSessionRequest) (*userpb.ValidateSessionResponse, error) {
+	if req.Token == "" {
+		return nil, apierror.New(codes.Unauthenticated, apierror.ReasonUnauthenticated, "a session token is required")
+	}
+
+	session, err := s.service.ValidateSession(ctx, req.Token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			return nil, apierror.New(codes.Unauthenticated, apierror.ReasonInvalidToken, "the session is invalid or has expired, log in again")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to validate session: %v", err)
+	}
+	return &userpb.ValidateSessionResponse{
+		UserId:    session.UserID,
+		ExpiresAt: session.ExpiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+func (s *UserServer) VerifyEmail(ctx context.Context, req *userpb.VerifyEmailRequest) (*userpb.UserResponse, error) {
+	if req.Token == "" {
+		return nil, apierror.Invalid("token", "is required")
+	}
+
+	user, err := s.service.VerifyEmail(ctx, req.Token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			return nil, apierror.New(codes.InvalidArgument, apierror.ReasonInvalidToken, "the verification link is invalid or has expired",
+				apierror.WithFieldViolation("token", "is invalid or has expired"))
+		}
+		return nil, status.Errorf(codes.Internal, "failed to verify email: %v", err)
+	}
+	return userToResponse(user), nil
+}
+
+// ResendVerificationEmail answers the same whether or not the address
+// belongs to an unverified user, so it cannot be used to find registered
+// addresses.
+func (s *UserServer) ResendVerificationEmail(ctx context.Context, req *userpb.ResendVerificationEmailRequest) (*userpb.ResendVerificationEmailResponse, error) {
+	if !validEmail(req.Email) {
+		return nil, apierror.Invalid("email", "must be a valid email address")
+	}
+	if err := s.service.ResendVerification(ctx, req.Email); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to resend verification email: %v", err)
+	}
+	return &userpb.ResendVerificationEmailResponse{}, nil
+}
+
+// RequestPasswordReset answers the same whether or not the address is
+// registered.
+func (s *UserServer) RequestPasswordReset(ctx context.Context, req *userpb.RequestPasswordResetRequest) (*userpb.RequestPasswordResetResponse, error) {
+	if !validEmail(req.Email) {
+		return nil, apierror.Invalid("email", "must be a valid email address")
+	}
+	if err := s.service.RequestPasswordReset(ctx, req.Email); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to request password reset: %v", err)
+	}
+	return &userpb.RequestPasswordResetResponse{}, nil
+}
+
+func (s *UserServer) ResetPassword(ctx context.Context, req *userpb.ResetPasswordRequest) (*userpb.ResetPasswordResponse, error) {
+	if req.Token == "" {
+		return nil, apierror.Invalid("token", "is required")
+	}
+	if req.NewPassword == "" {
+		return nil, apierror.Invalid("new_password", "is required")
+	}
+
+	err := s.service.ResetPassword(ctx, req.Token, req.NewPassword)
+	if err != nil {
+		var weak *service.WeakPasswordError
+		switch {
+		case errors.Is(err, service.ErrInvalidToken):
+			return nil, apierror.New(codes.InvalidArgument, apierror.ReasonInvalidToken, "the password reset link is invalid or has expired",
+				apierror.WithFieldViolation("token", "is invalid or has expired"))
+		case errors.As(err, &weak):
+			return nil, apierror.Invalid("new_password", weak.Reason)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to reset password: %v", err)
+	}
+	return &userpb.ResetPasswordResponse{}, nil
+}
+
+func (s *UserServer) ChangePassword(ctx context.Context, req *userpb.ChangePasswordRequest) (*userpb.ChangePasswordResponse, error) {
+	if req.CurrentPassword == "" {
+		return nil, apierror.Invalid("current_password", "is required")
+	}
+	if req.NewPassword == "" {
+		return nil, apierror.Invalid("new_password", "is required")
+	}
+
+	err := s.service.ChangePassword(ctx, req.UserId, req.CurrentPassword, req.NewPassword, req.Code)
+	if err != nil {
+		var weak *service.WeakPasswordError
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			return nil, apierror.NotFound(apierror.ReasonUserNotFound, "user", req.UserId)
+		case errors.Is(err, service.ErrInvalidCredentials):
+			return nil, apierror.New(codes.Unauthenticated, apierror.ReasonInvalidCredentials, "the current password is incorrect",
+				apierror.WithFieldViolation("current_password", "is incorrect"))
+		case errors.Is(err, service.ErrInvalidMFACode):
+			return nil, invalidMFACode()
+		case errors.As(err, &weak):
+			return nil, apierror.Invalid("new_password", weak.Reason)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to change password: %v", err)
+	}
+	return &userpb.ChangePasswordResponse{}, nil
+}
+
+func (s *UserServer) EnrollTotp(ctx context.Context, req *userpb.EnrollTotpRequest) (*userpb.EnrollTotpResponse, error) {
+	if req.Password == "" {
+		return nil, apierror.Invalid("password", "is required")
+	}
+
+	enrollment, err := s.service.EnrollTOTP(ctx, req.UserId, req.Password)
+	if err != nil {
+		if st := mfaError(err, req.UserId); st != nil {
+			return nil, st
+		}
+		return nil, status.Errorf(codes.Internal, "failed to enroll TOTP: %v", err)
+	}
+	return &userpb.EnrollTotpResponse{
+		Secret:     enrollment.Secret,
+		OtpauthUri: enrollment.URI,
+		QrCodePng:  enrollment.QRCode,
+	}, nil
+}
+
+func (s *UserServer) ConfirmTotp(ctx context.Context, req *userpb.ConfirmTotpRequest) (*userpb.ConfirmTotpResponse, error) {
+	if req.Code == "" {
+		return nil, apierror.Invalid("code", "is required")
+	}
+
+	recoveryCodes, err := s.service.ConfirmTOTP(ctx, req.UserId, req.Code)
+	if err != nil {
+		if st := mfaError(err, req.UserId); st != nil {
+			return nil, st
+		}
+		return nil, status.Errorf(codes.Internal, "failed to confirm TOTP: %v", err)
+	}
+	return &userpb.ConfirmTotpResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+func (s *UserServer) DisableTotp(ctx context.Context, req *userpb.DisableTotpRequest) (*userpb.DisableTotpResponse, error) {
+	if req.Password == "" {
+		return nil, apierror.Invalid("password", "is required")
+	}
+
+	if err := s.service.DisableTOTP(ctx, req.UserId, req.Password, req.Code); err != nil {
+		if st := mfaError(err, req.UserId); st != nil {
+			return nil, st
+		}
+		return nil, status.Errorf(codes.Internal, "failed to disable TOTP: %v", err)
+	}
+	return &userpb.DisableTotpResponse{}, nil
+}
+
+// mfaError maps the errors of the TOTP enrollment methods, or returns nil
+// for unexpected ones.
+func mfaError(err error, userID string) error {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return apierror.NotFound(apierror.ReasonUserNotFound, "user", userID)
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return apierror.New(codes.Unauthenticated, apierror.ReasonInvalidCredentials, "the password is incorrect",
+			apierror.WithFieldViolation("password", "is incorrect"))
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		return apierror.New(codes.FailedPrecondition, apierror.ReasonMFAAlreadyEnabled, "two-factor authentication is already enabled")
+	case errors.Is(err, service.ErrMFANotEnrolled):
+		return apierror.New(codes.FailedPrecondition, apierror.ReasonMFANotEnrolled, "two-factor authentication has not been set up")
+	case errors.Is(err, service.ErrInvalidMFACode):
+		return invalidMFACode()
+	}
+	return nil
+}
+
+func invalidMFACode() error {
+	return apierror.New(codes.InvalidArgument, apierror.ReasonInvalidMFACode, "the code is invalid or was already used",
+		apierror.WithFieldViolation("code", "is invalid or was already used"))
+}
+
+func userToResponse(user *models.User) *userpb.UserResponse {
+	return &userpb.UserResponse{
+		Id:            user.ID,
+		Name:          user.Name,
+		Email:         user.Email,
+		CreatedAt:     user.CreatedAt.Format(time.RFC3339),
+		EmailVerified: user.EmailVerifiedAt != nil,
+		MfaEnabled:    user.MFAEnabled,
+	}
+}
+
+// validEmail reports whether s is a bare address such as ann@example.com.
+func validEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
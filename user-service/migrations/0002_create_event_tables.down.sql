@@ -0,0 +1,5 @@
+-- The event tables may be shared with other services, so only the rows of
+-- this service are removed; the tables stay for the others.
+DELETE FROM dead_letters WHERE consumer = 'user-service';
+DELETE FROM processed_events WHERE consumer = 'user-service';
+DELETE FROM outbox WHERE source = 'user-service';